@@ -0,0 +1,367 @@
+/*
+Package cgroup collects cpu, memory, and io accounting for the control
+group of the current process from the cgroup filesystem, which makes
+it possible to track the resource usage of individual containers
+rather than the host as a whole.
+
+Both the legacy (v1) hierarchy, with one directory per controller, and
+the unified (v2) hierarchy are supported. All values are normalized to
+bytes and nanoseconds, regardless of the hierarchy version.
+*/
+package cgroup
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRoot is the mount point of the cgroup filesystem on most
+// systems.
+const DefaultRoot = "/sys/fs/cgroup"
+
+// procSelfCgroup lists the control groups of the current process.
+var procSelfCgroup = "/proc/self/cgroup"
+
+// Stats holds a single sample of a control group's resource
+// accounting. Errors holds problems reading individual accounting
+// files; accounting that the kernel does not provide is left empty.
+type Stats struct {
+	Version int         `json:"version" bson:"version"`
+	Path    string      `json:"path,omitempty" bson:"path,omitempty"`
+	CPU     CPUStats    `json:"cpu" bson:"cpu"`
+	Memory  MemoryStats `json:"memory" bson:"memory"`
+	IO      IOStats     `json:"io" bson:"io"`
+	Errors  []string    `json:"errors,omitempty" bson:"errors,omitempty"`
+}
+
+// CPUStats holds cumulative cpu time and cpu quota throttling.
+type CPUStats struct {
+	UsageNanos       uint64 `json:"usage_ns" bson:"usage_ns"`
+	UserNanos        uint64 `json:"user_ns" bson:"user_ns"`
+	SystemNanos      uint64 `json:"system_ns" bson:"system_ns"`
+	Periods          uint64 `json:"periods" bson:"periods"`
+	ThrottledPeriods uint64 `json:"throttled_periods" bson:"throttled_periods"`
+	ThrottledNanos   uint64 `json:"throttled_ns" bson:"throttled_ns"`
+}
+
+// MemoryStats holds memory usage in bytes, and counts of the times the
+// group reached its limit. A Limit of zero means that the group has
+// no memory limit.
+type MemoryStats struct {
+	Usage     uint64 `json:"usage" bson:"usage"`
+	Limit     uint64 `json:"limit" bson:"limit"`
+	Peak      uint64 `json:"peak" bson:"peak"`
+	Cache     uint64 `json:"cache" bson:"cache"`
+	RSS       uint64 `json:"rss" bson:"rss"`
+	FailCount uint64 `json:"failcnt" bson:"failcnt"`
+	OOMEvents uint64 `json:"oom_events" bson:"oom_events"`
+	OOMKills  uint64 `json:"oom_kills" bson:"oom_kills"`
+}
+
+// IOStats holds cumulative block io, summed over all devices.
+type IOStats struct {
+	ReadBytes  uint64 `json:"read_bytes" bson:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes" bson:"write_bytes"`
+	ReadOps    uint64 `json:"read_ops" bson:"read_ops"`
+	WriteOps   uint64 `json:"write_ops" bson:"write_ops"`
+}
+
+// Collect reads the accounting for the control group mounted at the
+// root, typically DefaultRoot. Problems reading individual accounting
+// files are recorded in the Errors field of the result; Collect only
+// returns an error if the root does not contain a cgroup hierarchy.
+func Collect(root string) (*Stats, error) {
+	stats := &Stats{}
+
+	if exists(filepath.Join(root, "cgroup.controllers")) {
+		stats.Version = 2
+		stats.collectV2(root)
+	} else if exists(filepath.Join(root, "memory")) || exists(filepath.Join(root, "cpuacct")) {
+		stats.Version = 1
+		stats.collectV1(root)
+	} else {
+		return nil, errors.Errorf("'%s' does not contain a cgroup hierarchy", root)
+	}
+
+	path, err := currentPath(procSelfCgroup, stats.Version)
+	stats.addError(err)
+	stats.Path = path
+
+	return stats, nil
+}
+
+// addError records the error, ignoring errors from accounting files
+// that do not exist, as the available files depend on the kernel
+// version and on which controllers are enabled.
+func (s *Stats) addError(err error) {
+	if err == nil || os.IsNotExist(errors.Cause(err)) {
+		return
+	}
+
+	s.Errors = append(s.Errors, err.Error())
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// unified hierarchy
+
+func (s *Stats) collectV2(root string) {
+	cpu, err := readKeyValues(filepath.Join(root, "cpu.stat"))
+	s.addError(err)
+	s.CPU.UsageNanos = cpu["usage_usec"] * 1000
+	s.CPU.UserNanos = cpu["user_usec"] * 1000
+	s.CPU.SystemNanos = cpu["system_usec"] * 1000
+	s.CPU.Periods = cpu["nr_periods"]
+	s.CPU.ThrottledPeriods = cpu["nr_throttled"]
+	s.CPU.ThrottledNanos = cpu["throttled_usec"] * 1000
+
+	s.Memory.Usage, err = readUint(filepath.Join(root, "memory.current"))
+	s.addError(err)
+	s.Memory.Limit, err = readUint(filepath.Join(root, "memory.max"))
+	s.addError(err)
+	s.Memory.Peak, err = readUint(filepath.Join(root, "memory.peak"))
+	s.addError(err)
+
+	mem, err := readKeyValues(filepath.Join(root, "memory.stat"))
+	s.addError(err)
+	s.Memory.Cache = mem["file"]
+	s.Memory.RSS = mem["anon"]
+
+	events, err := readKeyValues(filepath.Join(root, "memory.events"))
+	s.addError(err)
+	s.Memory.FailCount = events["max"]
+	s.Memory.OOMEvents = events["oom"]
+	s.Memory.OOMKills = events["oom_kill"]
+
+	s.addError(s.IO.readV2(filepath.Join(root, "io.stat")))
+}
+
+// readV2 sums io.stat, which has one line per device of the form
+// "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func (io *IOStats) readV2(fn string) error {
+	return readLines(fn, func(fields []string) error {
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "problem parsing '%s' in '%s'", field, fn)
+			}
+
+			switch kv[0] {
+			case "rbytes":
+				io.ReadBytes += value
+			case "wbytes":
+				io.WriteBytes += value
+			case "rios":
+				io.ReadOps += value
+			case "wios":
+				io.WriteOps += value
+			}
+		}
+
+		return nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// legacy hierarchy
+
+// v1ClockTicks is the USER_HZ value used by cpuacct.stat, which is 100
+// on all supported architectures.
+const v1ClockTicks = 100
+
+// v1UnlimitedThreshold is the smallest value treated as "no limit"
+// for memory.limit_in_bytes, which reports a page-aligned maximum
+// integer rather than a sentinel when unset.
+const v1UnlimitedThreshold = 1 << 62
+
+func (s *Stats) collectV1(root string) {
+	cpuacct := filepath.Join(root, "cpuacct")
+	cpu := filepath.Join(root, "cpu")
+	memory := filepath.Join(root, "memory")
+	blkio := filepath.Join(root, "blkio")
+
+	var err error
+	s.CPU.UsageNanos, err = readUint(filepath.Join(cpuacct, "cpuacct.usage"))
+	s.addError(err)
+
+	ticks, err := readKeyValues(filepath.Join(cpuacct, "cpuacct.stat"))
+	s.addError(err)
+	s.CPU.UserNanos = ticks["user"] * (1e9 / v1ClockTicks)
+	s.CPU.SystemNanos = ticks["system"] * (1e9 / v1ClockTicks)
+
+	throttling, err := readKeyValues(filepath.Join(cpu, "cpu.stat"))
+	s.addError(err)
+	s.CPU.Periods = throttling["nr_periods"]
+	s.CPU.ThrottledPeriods = throttling["nr_throttled"]
+	s.CPU.ThrottledNanos = throttling["throttled_time"]
+
+	s.Memory.Usage, err = readUint(filepath.Join(memory, "memory.usage_in_bytes"))
+	s.addError(err)
+	s.Memory.Limit, err = readUint(filepath.Join(memory, "memory.limit_in_bytes"))
+	s.addError(err)
+	if s.Memory.Limit >= v1UnlimitedThreshold {
+		s.Memory.Limit = 0
+	}
+	s.Memory.Peak, err = readUint(filepath.Join(memory, "memory.max_usage_in_bytes"))
+	s.addError(err)
+	s.Memory.FailCount, err = readUint(filepath.Join(memory, "memory.failcnt"))
+	s.addError(err)
+
+	mem, err := readKeyValues(filepath.Join(memory, "memory.stat"))
+	s.addError(err)
+	s.Memory.Cache = mem["total_cache"]
+	s.Memory.RSS = mem["total_rss"]
+
+	oom, err := readKeyValues(filepath.Join(memory, "memory.oom_control"))
+	s.addError(err)
+	s.Memory.OOMEvents = oom["under_oom"]
+	s.Memory.OOMKills = oom["oom_kill"]
+
+	s.addError(readV1Blkio(filepath.Join(blkio, "blkio.throttle.io_service_bytes"),
+		&s.IO.ReadBytes, &s.IO.WriteBytes))
+	s.addError(readV1Blkio(filepath.Join(blkio, "blkio.throttle.io_serviced"),
+		&s.IO.ReadOps, &s.IO.WriteOps))
+}
+
+// readV1Blkio sums the read and write columns of a blkio accounting
+// file, which has lines of the form "8:0 Read 1234" for each device
+// and a "Total 1234" line.
+func readV1Blkio(fn string, read, write *uint64) error {
+	return readLines(fn, func(fields []string) error {
+		if len(fields) != 3 {
+			return nil
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "problem parsing '%s' in '%s'", fields[2], fn)
+		}
+
+		switch fields[1] {
+		case "Read":
+			*read += value
+		case "Write":
+			*write += value
+		}
+
+		return nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// helpers
+
+// currentPath returns the path of the process's control group, from a
+// file in the format of /proc/self/cgroup. For the legacy hierarchy,
+// the path in the memory controller is used.
+func currentPath(fn string, version int) (string, error) {
+	var path string
+
+	err := readLines(fn, func(fields []string) error {
+		parts := strings.SplitN(strings.Join(fields, " "), ":", 3)
+		if len(parts) != 3 {
+			return nil
+		}
+
+		if version == 2 && parts[0] == "0" && parts[1] == "" {
+			path = parts[2]
+		}
+
+		if version == 1 {
+			for _, controller := range strings.Split(parts[1], ",") {
+				if controller == "memory" {
+					path = parts[2]
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return path, err
+}
+
+func exists(fn string) bool {
+	_, err := os.Stat(fn)
+	return err == nil
+}
+
+// readUint reads a file that contains a single integer. The value
+// "max", which the unified hierarchy uses for unset limits, is
+// returned as zero.
+func readUint(fn string) (uint64, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+
+	out, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "problem parsing '%s'", fn)
+	}
+
+	return out, nil
+}
+
+// readKeyValues reads a file with one "key value" pair per line.
+func readKeyValues(fn string) (map[string]uint64, error) {
+	out := map[string]uint64{}
+
+	err := readLines(fn, func(fields []string) error {
+		if len(fields) != 2 {
+			return nil
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "problem parsing '%s' in '%s'", fields[0], fn)
+		}
+		out[fields[0]] = value
+
+		return nil
+	})
+
+	return out, err
+}
+
+// readLines calls the function with the whitespace separated fields of
+// every non-empty line in the file.
+func readLines(path string, fn func([]string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err = fn(fields); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(scanner.Err())
+}
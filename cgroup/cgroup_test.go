@@ -0,0 +1,149 @@
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFixtures(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		fn := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0755))
+		require.NoError(t, ioutil.WriteFile(fn, []byte(content), 0644))
+	}
+}
+
+func withProcSelfCgroup(t *testing.T, content string) func() {
+	f, err := ioutil.TempFile("", "cgroup")
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	orig := procSelfCgroup
+	procSelfCgroup = f.Name()
+
+	return func() {
+		procSelfCgroup = orig
+		os.Remove(f.Name())
+	}
+}
+
+func TestCollectUnifiedHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	root, err := ioutil.TempDir("", "cgroup-v2")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	defer withProcSelfCgroup(t, "0::/docker/abc123\n")()
+
+	writeFixtures(t, root, map[string]string{
+		"cgroup.controllers": "cpu io memory\n",
+		"cpu.stat":           "usage_usec 2000\nuser_usec 1500\nsystem_usec 500\nnr_periods 10\nnr_throttled 2\nthrottled_usec 30\n",
+		"memory.current":     "4096\n",
+		"memory.max":         "max\n",
+		"memory.stat":        "anon 1024\nfile 2048\n",
+		"memory.events":      "low 0\nhigh 0\nmax 3\noom 2\noom_kill 1\n",
+		"io.stat":            "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1 wbytes=2 rios=3 wios=4\n",
+	})
+
+	stats, err := Collect(root)
+	require.NoError(t, err)
+
+	assert.Equal(2, stats.Version)
+	assert.Equal("/docker/abc123", stats.Path)
+	assert.Empty(stats.Errors)
+	assert.Equal(CPUStats{
+		UsageNanos:       2000000,
+		UserNanos:        1500000,
+		SystemNanos:      500000,
+		Periods:          10,
+		ThrottledPeriods: 2,
+		ThrottledNanos:   30000,
+	}, stats.CPU)
+	assert.Equal(MemoryStats{
+		Usage:     4096,
+		Cache:     2048,
+		RSS:       1024,
+		FailCount: 3,
+		OOMEvents: 2,
+		OOMKills:  1,
+	}, stats.Memory)
+	assert.Equal(IOStats{ReadBytes: 101, WriteBytes: 202, ReadOps: 4, WriteOps: 6}, stats.IO)
+}
+
+func TestCollectLegacyHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	root, err := ioutil.TempDir("", "cgroup-v1")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	defer withProcSelfCgroup(t, "5:cpu,cpuacct:/docker/abc123\n4:memory:/docker/def456\n0::/\n")()
+
+	writeFixtures(t, root, map[string]string{
+		"cpuacct/cpuacct.usage":                 "123456789\n",
+		"cpuacct/cpuacct.stat":                  "user 10\nsystem 5\n",
+		"cpu/cpu.stat":                          "nr_periods 10\nnr_throttled 2\nthrottled_time 30\n",
+		"memory/memory.usage_in_bytes":          "4096\n",
+		"memory/memory.limit_in_bytes":          "9223372036854771712\n",
+		"memory/memory.max_usage_in_bytes":      "8192\n",
+		"memory/memory.failcnt":                 "3\n",
+		"memory/memory.stat":                    "cache 1\nrss 2\ntotal_cache 2048\ntotal_rss 1024\n",
+		"memory/memory.oom_control":             "oom_kill_disable 0\nunder_oom 0\noom_kill 1\n",
+		"blkio/blkio.throttle.io_service_bytes": "8:0 Read 100\n8:0 Write 200\n8:0 Total 300\nTotal 300\n",
+		"blkio/blkio.throttle.io_serviced":      "8:0 Read 1\n8:0 Write 2\n8:0 Total 3\nTotal 3\n",
+	})
+
+	stats, err := Collect(root)
+	require.NoError(t, err)
+
+	assert.Equal(1, stats.Version)
+	assert.Equal("/docker/def456", stats.Path)
+	assert.Empty(stats.Errors)
+	assert.Equal(CPUStats{
+		UsageNanos:       123456789,
+		UserNanos:        100000000,
+		SystemNanos:      50000000,
+		Periods:          10,
+		ThrottledPeriods: 2,
+		ThrottledNanos:   30,
+	}, stats.CPU)
+	assert.Equal(MemoryStats{
+		Usage:     4096,
+		Peak:      8192,
+		Cache:     2048,
+		RSS:       1024,
+		FailCount: 3,
+		OOMKills:  1,
+	}, stats.Memory)
+	assert.Equal(IOStats{ReadBytes: 100, WriteBytes: 200, ReadOps: 1, WriteOps: 2}, stats.IO)
+}
+
+func TestCollectReportsMalformedFiles(t *testing.T) {
+	assert := assert.New(t)
+	root, err := ioutil.TempDir("", "cgroup-v2")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	defer withProcSelfCgroup(t, "0::/\n")()
+
+	writeFixtures(t, root, map[string]string{
+		"cgroup.controllers": "memory\n",
+		"memory.current":     "lots\n",
+	})
+
+	stats, err := Collect(root)
+	require.NoError(t, err)
+	assert.Len(stats.Errors, 1)
+}
+
+func TestCollectRequiresHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	_, err = Collect(root)
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"github.com/evergreen-ci/sink"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// BulkUpdate is one update in a bulk write. Multi updates every
+// matching document; Upsert creates a document when none match.
+type BulkUpdate struct {
+	Query  interface{}
+	Update interface{}
+	Multi  bool
+	Upsert bool
+}
+
+// RunBulk applies the updates to the collection in order, in one
+// round trip, stopping at the first update that fails. It returns the
+// number of updates applied: on error, the updates before that count
+// have been applied, and the remaining updates have not. When the
+// error does not identify the failed update, as when the connection
+// fails, RunBulk reports that none were applied, although some may
+// have been, so callers that retry the remaining updates apply them
+// at least once rather than exactly once.
+func RunBulk(collection string, updates []BulkUpdate) (int, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return 0, errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	bulk := db.C(collection).Bulk()
+	for _, u := range updates {
+		switch {
+		case u.Upsert:
+			bulk.Upsert(u.Query, u.Update)
+		case u.Multi:
+			bulk.UpdateAll(u.Query, u.Update)
+		default:
+			bulk.Update(u.Query, u.Update)
+		}
+	}
+
+	if _, err = bulk.Run(); err != nil {
+		applied := 0
+		if berr, ok := err.(*mgo.BulkError); ok {
+			if cases := berr.Cases(); len(cases) > 0 && cases[0].Index > 0 {
+				applied = cases[0].Index
+			}
+		}
+
+		return applied, errors.WithStack(err)
+	}
+
+	return len(updates), nil
+}
@@ -48,6 +48,45 @@ func UpdateID(collection string, id, update interface{}) error {
 	return errors.WithStack(db.C(collection).UpdateId(id, update))
 }
 
+// UpsertID updates one _id-matching document in the collection,
+// creating the document if it does not exist.
+func UpsertID(collection string, id, update interface{}) error {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	_, err = db.C(collection).UpsertId(id, update)
+	return errors.WithStack(err)
+}
+
+// Upsert updates one document matching the query in the collection,
+// creating the document if none match.
+func Upsert(collection string, query, update interface{}) error {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	_, err = db.C(collection).Upsert(query, update)
+	return errors.WithStack(err)
+}
+
+// Aggregate runs an aggregation pipeline against the collection and
+// unmarshals the results into the provided interface, which must be a
+// pointer to a slice.
+func Aggregate(collection string, pipeline interface{}, out interface{}) error {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	return errors.WithStack(db.C(collection).Pipe(pipeline).AllowDiskUse().All(out))
+}
+
 // findOne finds one item from the specified collection and unmarshals it into the
 // provided interface, which must be a pointer.
 func findOne(coll string, query, proj interface{}, sort []string, out interface{}) error {
@@ -76,6 +115,23 @@ func runUpdate(collection string, query, update interface{}) error {
 	return errors.WithStack(db.C(collection).Update(query, update))
 }
 
+// runUpdateAll updates all matching documents in the collection,
+// returning the number of documents modified.
+func runUpdateAll(collection string, query, update interface{}) (int, error) {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return 0, errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	info, err := db.C(collection).UpdateAll(query, update)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return info.Updated, nil
+}
+
 // findAll finds the items from the specified collection and unmarshals them into the
 // provided interface, which must be a slice.
 func findAll(coll string, query, proj interface{}, sort []string, skip, limit int, out interface{}) error {
@@ -104,6 +160,18 @@ func removeOne(coll string, query interface{}) error {
 	return errors.WithStack(db.C(coll).Remove(query))
 }
 
+// removeAll removes all matching documents from a collection.
+func removeAll(coll string, query interface{}) error {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	_, err = db.C(coll).RemoveAll(query)
+	return errors.WithStack(err)
+}
+
 // count run a count command with the specified query against the collection.f
 func count(collection string, query interface{}) (int, error) {
 	session, db, err := sink.GetMgoSession()
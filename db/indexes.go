@@ -0,0 +1,39 @@
+package db
+
+import (
+	"github.com/evergreen-ci/sink"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// mongodb's error code for operations on collections that do not exist.
+const namespaceNotFoundCode = 26
+
+// EnsureIndex creates the index on the collection if it does not
+// already exist.
+func EnsureIndex(collection string, index mgo.Index) error {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	return errors.WithStack(db.C(collection).EnsureIndex(index))
+}
+
+// Indexes returns the indexes that exist on the collection, which is
+// empty if the collection does not exist.
+func Indexes(collection string) ([]mgo.Index, error) {
+	session, db, err := sink.GetMgoSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "problem getting session")
+	}
+	defer session.Close()
+
+	indexes, err := db.C(collection).Indexes()
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == namespaceNotFoundCode {
+		return []mgo.Index{}, nil
+	}
+
+	return indexes, errors.WithStack(err)
+}
@@ -1,6 +1,10 @@
 package db
 
-import "github.com/evergreen-ci/sink"
+import (
+	"github.com/evergreen-ci/sink"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+)
 
 // ResultsIterator
 type ResultsIterator interface {
@@ -10,12 +14,31 @@ type ResultsIterator interface {
 	Err() error
 }
 
+// sessionIterator wraps an mgo iterator to hold the session open for
+// the lifetime of the iterator, and closes the session along with the
+// iterator.
+type sessionIterator struct {
+	*mgo.Iter
+	session *mgo.Session
+}
+
+func (i *sessionIterator) All(result interface{}) error {
+	defer i.session.Close()
+
+	return errors.WithStack(i.Iter.All(result))
+}
+
+func (i *sessionIterator) Close() error {
+	defer i.session.Close()
+
+	return errors.WithStack(i.Iter.Close())
+}
+
 func iter(collection string, query interface{}, project interface{}, sort []string, skip, limit int) ResultsIterator {
 	session, db, err := sink.GetMgoSession()
 	if err != nil {
 		return nil
 	}
-	defer session.Close()
 
 	q := db.C(collection).Find(query).Select(project)
 
@@ -31,5 +54,5 @@ func iter(collection string, query interface{}, project interface{}, sort []stri
 		q = q.Limit(limit)
 	}
 
-	return q.Iter()
+	return &sessionIterator{Iter: q.Iter(), session: session}
 }
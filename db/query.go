@@ -89,6 +89,15 @@ func (q *Q) Update(coll string, update interface{}) error {
 	return errors.WithStack(runUpdate(coll, q.filter, update))
 }
 
+// UpdateAll applies the update to all documents matching the query,
+// and returns the number of documents modified.
+func (q *Q) UpdateAll(coll string, update interface{}) (int, error) {
+	count, err := runUpdateAll(coll, q.filter, update)
+	err = errors.WithStack(err)
+
+	return count, err
+}
+
 // Count runs a Q count query against the given collection.
 func (q *Q) Count(collection string) (int, error) {
 	count, err := count(collection, q.filter)
@@ -101,6 +110,10 @@ func (q *Q) RemoveOne(collection string) error {
 	return errors.WithStack(removeOne(collection, q.filter))
 }
 
+func (q *Q) RemoveAll(collection string) error {
+	return errors.WithStack(removeAll(collection, q.filter))
+}
+
 func (q *Q) Iter(collection string) ResultsIterator {
 	return iter(collection, q.filter, q.projection, q.sort, q.skip, q.limit)
 }
@@ -33,6 +33,7 @@ func buildApp() *cli.App {
 		operations.Worker(),
 		operations.Deps(),
 		operations.Spend(),
+		operations.Admin(),
 	}
 
 	// These are global options. Use this to configure logging or
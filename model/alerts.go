@@ -0,0 +1,472 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/mongodb/grip/send"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	alertRuleCollection  = "sysinfo.alerts.rules"
+	alertStateCollection = "sysinfo.alerts.state"
+)
+
+func init() {
+	registerIndexes(alertRuleCollection, mgo.Index{Key: []string{alertRuleHostnameKey}})
+	registerIndexes(alertStateCollection,
+		mgo.Index{Key: []string{alertStateRuleKey}},
+		mgo.Index{Key: []string{alertStateFiringKey}})
+}
+
+// Metrics that alert rules can evaluate. Disk metrics are evaluated
+// for every partition in a sample, and the rule matches if any
+// partition meets the condition.
+const (
+	AlertMetricMemoryAvailable   = "memory.available"
+	AlertMetricMemoryUsedPercent = "memory.used_percent"
+	AlertMetricDiskUsedPercent   = "disk.used_percent"
+	AlertMetricDiskFree          = "disk.free"
+	AlertMetricInodesUsedPercent = "disk.inodes_used_percent"
+)
+
+// Operators for comparing a metric to an alert rule's threshold.
+const (
+	AlertOperatorGreaterThan = "gt"
+	AlertOperatorLessThan    = "lt"
+)
+
+const (
+	alertRuleDefaultLevel = "warning"
+	alertStateFiring      = "firing"
+	alertStateResolved    = "resolved"
+
+	alertEventPayloadRuleField    = "rule"
+	alertEventPayloadHostField    = "host"
+	alertEventPayloadStateField   = "state"
+	alertEventPayloadValueField   = "value"
+	alertEventPayloadSubjectField = "subject"
+)
+
+// AlertRule describes a threshold condition on system information
+// samples. When the condition holds for at least the rule's
+// duration, the rule fires and records an event using the system
+// event sender; when the condition clears the rule resolves.
+type AlertRule struct {
+	ID        string        `bson:"_id" json:"id"`
+	Metric    string        `bson:"metric" json:"metric"`
+	Operator  string        `bson:"op" json:"op"`
+	Threshold float64       `bson:"threshold" json:"threshold"`
+	Duration  time.Duration `bson:"dur" json:"duration"`
+	Level     string        `bson:"level" json:"level"`
+	Hostname  string        `bson:"hn,omitempty" json:"host,omitempty"`
+
+	populated bool
+}
+
+var (
+	alertRuleIDKey       = bsonutil.MustHaveTag(AlertRule{}, "ID")
+	alertRuleHostnameKey = bsonutil.MustHaveTag(AlertRule{}, "Hostname")
+)
+
+func (r *AlertRule) IsNil() bool { return !r.populated }
+
+// alertRuleJSON is the JSON form of an alert rule, which renders the
+// duration as a string, such as "5m0s", in the form that the REST
+// API accepts.
+type alertRuleJSON struct {
+	ID        string          `json:"id"`
+	Metric    string          `json:"metric"`
+	Operator  string          `json:"op"`
+	Threshold float64         `json:"threshold"`
+	Duration  json.RawMessage `json:"duration"`
+	Level     string          `json:"level"`
+	Hostname  string          `json:"host,omitempty"`
+}
+
+func (r *AlertRule) MarshalJSON() ([]byte, error) {
+	dur, err := json.Marshal(r.Duration.String())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return json.Marshal(&alertRuleJSON{
+		ID:        r.ID,
+		Metric:    r.Metric,
+		Operator:  r.Operator,
+		Threshold: r.Threshold,
+		Duration:  dur,
+		Level:     r.Level,
+		Hostname:  r.Hostname,
+	})
+}
+
+// UnmarshalJSON accepts the duration as a string, such as "5m".
+func (r *AlertRule) UnmarshalJSON(data []byte) error {
+	in := alertRuleJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var dur time.Duration
+	if len(in.Duration) > 0 && string(in.Duration) != "null" {
+		var str string
+		if err := json.Unmarshal(in.Duration, &str); err != nil {
+			return errors.Wrap(err, "duration must be a string")
+		}
+
+		parsed, err := time.ParseDuration(str)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse duration '%s'", str)
+		}
+		dur = parsed
+	}
+
+	*r = AlertRule{
+		ID:        in.ID,
+		Metric:    in.Metric,
+		Operator:  in.Operator,
+		Threshold: in.Threshold,
+		Duration:  dur,
+		Level:     in.Level,
+		Hostname:  in.Hostname,
+		populated: r.populated,
+	}
+
+	return nil
+}
+
+// Validate checks that the rule is well formed, and sets a default
+// level if none is specified.
+func (r *AlertRule) Validate() error {
+	if r.ID == "" {
+		return errors.New("alert rule must have an id")
+	}
+
+	switch r.Metric {
+	case AlertMetricMemoryAvailable, AlertMetricMemoryUsedPercent,
+		AlertMetricDiskUsedPercent, AlertMetricDiskFree, AlertMetricInodesUsedPercent:
+	default:
+		return errors.Errorf("'%s' is not a supported alert metric", r.Metric)
+	}
+
+	if r.Operator != AlertOperatorGreaterThan && r.Operator != AlertOperatorLessThan {
+		return errors.Errorf("'%s' is not a valid operator", r.Operator)
+	}
+
+	if r.Duration < 0 {
+		return errors.New("alert rule duration cannot be negative")
+	}
+
+	if r.Level == "" {
+		r.Level = alertRuleDefaultLevel
+	}
+
+	if !level.IsValidPriority(level.FromString(r.Level)) {
+		return errors.Errorf("'%s' is not a valid level", r.Level)
+	}
+
+	return nil
+}
+
+// Save inserts or replaces the rule.
+func (r *AlertRule) Save() error {
+	if err := r.Validate(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(db.UpsertID(alertRuleCollection, r.ID, r))
+}
+
+func (r *AlertRule) Find(id string) error {
+	err := db.Query(bson.M{alertRuleIDKey: id}).FindOne(alertRuleCollection, r)
+
+	r.populated = false
+	if errors.Cause(err) == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "problem running alert rule query")
+	}
+	r.populated = true
+
+	return nil
+}
+
+// Remove deletes the rule along with any state tracked for it.
+func (r *AlertRule) Remove() error {
+	if err := db.Query(bson.M{alertRuleIDKey: r.ID}).RemoveOne(alertRuleCollection); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(db.Query(bson.M{alertStateRuleKey: r.ID}).RemoveAll(alertStateCollection))
+}
+
+func (r *AlertRule) compare(value float64) bool {
+	if r.Operator == AlertOperatorLessThan {
+		return value < r.Threshold
+	}
+
+	return value > r.Threshold
+}
+
+// Check reports whether the rule's condition holds for the given
+// sample. It also returns the observed value and, for disk metrics,
+// the mountpoint that matched.
+func (r *AlertRule) Check(info *message.SystemInfo) (bool, float64, string) {
+	switch r.Metric {
+	case AlertMetricMemoryAvailable:
+		value := float64(info.VMStat.Available)
+		return r.compare(value), value, ""
+	case AlertMetricMemoryUsedPercent:
+		value := info.VMStat.UsedPercent
+		return r.compare(value), value, ""
+	}
+
+	var value float64
+	for _, usage := range info.Usage {
+		switch r.Metric {
+		case AlertMetricDiskUsedPercent:
+			value = usage.UsedPercent
+		case AlertMetricDiskFree:
+			value = float64(usage.Free)
+		case AlertMetricInodesUsedPercent:
+			value = usage.InodesUsedPercent
+		default:
+			return false, 0, ""
+		}
+
+		if r.compare(value) {
+			return true, value, usage.Path
+		}
+	}
+
+	return false, value, ""
+}
+
+// Evaluate checks the rule against a system information record and
+// updates the rule's state for the record's host. Events for firing
+// and resolving alerts are sent to the specified sender; resolving
+// an alert also acknowledges the events it produced while firing.
+func (r *AlertRule) Evaluate(rec *SystemInformationRecord, sender send.Sender) error {
+	if r.Hostname != "" && r.Hostname != rec.Hostname {
+		return nil
+	}
+
+	if sender == nil {
+		return errors.New("cannot evaluate alert rules without a sender")
+	}
+
+	state := &AlertState{}
+	if err := state.Find(r.ID, rec.Hostname); err != nil {
+		return errors.WithStack(err)
+	}
+
+	msg, cleared := r.advance(state, rec)
+	if msg != nil {
+		sender.Send(msg)
+	}
+
+	if cleared {
+		if err := resolveAlertEvents(r.ID, rec.Hostname, rec.Timestamp); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return errors.WithStack(state.Save())
+}
+
+// advance updates the state with the record, and returns the message
+// to send, if the rule started firing or resolved, and whether the
+// rule resolved.
+func (r *AlertRule) advance(state *AlertState, rec *SystemInformationRecord) (message.Composer, bool) {
+	triggered, value, subject := r.Check(&rec.Data)
+	state.Value = value
+	state.LastSample = rec.Timestamp
+
+	if triggered {
+		if state.PendingSince.IsZero() {
+			state.PendingSince = rec.Timestamp
+		}
+
+		if !state.Firing && rec.Timestamp.Sub(state.PendingSince) >= r.Duration {
+			state.Firing = true
+			return r.makeMessage(level.FromString(r.Level), rec.Hostname, alertStateFiring, value, subject), false
+		}
+
+		return nil, false
+	}
+
+	state.PendingSince = time.Time{}
+	if !state.Firing {
+		return nil, false
+	}
+
+	state.Firing = false
+	return r.makeMessage(level.Notice, rec.Hostname, alertStateResolved, value, subject), true
+}
+
+func (r *AlertRule) makeMessage(p level.Priority, host, state string, value float64, subject string) message.Composer {
+	msg := fmt.Sprintf("alert '%s' %s on '%s': %s is %v (%s %v)",
+		r.ID, state, host, r.Metric, value, r.Operator, r.Threshold)
+
+	return message.NewFieldsMessage(p, msg, message.Fields{
+		alertEventPayloadRuleField:    r.ID,
+		alertEventPayloadHostField:    host,
+		alertEventPayloadStateField:   state,
+		alertEventPayloadValueField:   value,
+		alertEventPayloadSubjectField: subject,
+	})
+}
+
+// alertEventsQuery selects the firing events for the rule and host.
+func alertEventsQuery(rule, host string) bson.M {
+	return bson.M{
+		bsonutil.GetDottedKeyName(eventPayloadKey, alertEventPayloadRuleField):  rule,
+		bsonutil.GetDottedKeyName(eventPayloadKey, alertEventPayloadHostField):  host,
+		bsonutil.GetDottedKeyName(eventPayloadKey, alertEventPayloadStateField): alertStateFiring,
+	}
+}
+
+// alertClearedTransition resolves the events of an alert that stopped
+// firing, so that the events reopen if the alert fires again.
+func alertClearedTransition(ts time.Time) EventTransition {
+	return EventTransition{
+		To:   EventResolved,
+		User: eventSystemUser,
+		Note: "alert cleared",
+		Time: ts,
+	}
+}
+
+// resolveAlertEvents resolves the open firing events for the rule and
+// host.
+func resolveAlertEvents(rule, host string, ts time.Time) error {
+	_, err := transitionEvents(alertEventsQuery(rule, host), []string{EventOpen, EventReopened}, alertClearedTransition(ts))
+
+	return errors.WithStack(err)
+}
+
+type AlertRules struct {
+	slice     []*AlertRule
+	populated bool
+}
+
+func (r *AlertRules) Slice() []*AlertRule { return r.slice }
+func (r *AlertRules) IsNil() bool         { return !r.populated }
+
+func (r *AlertRules) FindAll() error {
+	r.populated = false
+	if err := db.Query(bson.M{}).Sort(alertRuleIDKey).FindAll(alertRuleCollection, &r.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	r.populated = true
+
+	return nil
+}
+
+// FindHostname returns all rules that apply to the host, including
+// rules that apply to all hosts.
+func (r *AlertRules) FindHostname(host string) error {
+	query := db.Query(bson.M{
+		alertRuleHostnameKey: bson.M{"$in": []interface{}{host, "", nil}},
+	})
+
+	r.populated = false
+	if err := query.FindAll(alertRuleCollection, &r.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	r.populated = true
+
+	return nil
+}
+
+///////////////////////////////////
+//
+// per-host state of alert rules
+
+// AlertStateID identifies the state of a rule for a host.
+type AlertStateID struct {
+	RuleID   string `bson:"rule" json:"rule"`
+	Hostname string `bson:"hn" json:"host"`
+}
+
+// AlertState tracks the condition of an alert rule for a single host
+// between samples.
+type AlertState struct {
+	ID           AlertStateID `bson:"_id" json:"id"`
+	RuleID       string       `bson:"rule" json:"rule"`
+	Hostname     string       `bson:"hn" json:"host"`
+	Firing       bool         `bson:"firing" json:"firing"`
+	PendingSince time.Time    `bson:"pending,omitempty" json:"pending_since,omitempty"`
+	LastSample   time.Time    `bson:"last" json:"last_sample"`
+	Value        float64      `bson:"value" json:"value"`
+
+	populated bool
+}
+
+var (
+	alertStateIDKey     = bsonutil.MustHaveTag(AlertState{}, "ID")
+	alertStateRuleKey   = bsonutil.MustHaveTag(AlertState{}, "RuleID")
+	alertStateFiringKey = bsonutil.MustHaveTag(AlertState{}, "Firing")
+)
+
+func (s *AlertState) IsNil() bool { return !s.populated }
+
+// Find retrieves the state for a rule and host. If no state exists,
+// Find returns without an error and initializes the document so that
+// it can be saved.
+func (s *AlertState) Find(rule, host string) error {
+	id := AlertStateID{RuleID: rule, Hostname: host}
+	err := db.Query(bson.M{alertStateIDKey: id}).FindOne(alertStateCollection, s)
+
+	s.populated = false
+	if errors.Cause(err) == mgo.ErrNotFound {
+		s.ID = id
+		s.RuleID = rule
+		s.Hostname = host
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "problem running alert state query")
+	}
+	s.populated = true
+
+	return nil
+}
+
+func (s *AlertState) Save() error {
+	if s.ID.RuleID == "" {
+		return errors.New("cannot save alert state without a rule")
+	}
+
+	return errors.WithStack(db.UpsertID(alertStateCollection, s.ID, s))
+}
+
+type AlertStates struct {
+	slice     []*AlertState
+	populated bool
+}
+
+func (s *AlertStates) Slice() []*AlertState { return s.slice }
+func (s *AlertStates) IsNil() bool          { return !s.populated }
+
+func (s *AlertStates) FindFiring() error {
+	s.populated = false
+	if err := db.Query(bson.M{alertStateFiringKey: true}).FindAll(alertStateCollection, &s.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	s.populated = true
+
+	return nil
+}
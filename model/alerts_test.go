@@ -0,0 +1,233 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAlertRuleValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &AlertRule{}
+	assert.Error(rule.Validate())
+
+	rule.ID = "disk-full"
+	assert.Error(rule.Validate())
+
+	rule.Metric = AlertMetricDiskUsedPercent
+	assert.Error(rule.Validate())
+
+	rule.Operator = AlertOperatorGreaterThan
+	assert.NoError(rule.Validate())
+	assert.Equal("warning", rule.Level)
+
+	rule.Level = "not-a-level"
+	assert.Error(rule.Validate())
+
+	rule.Level = "critical"
+	rule.Duration = -1
+	assert.Error(rule.Validate())
+}
+
+func TestAlertRuleJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &AlertRule{
+		ID:        "disk-full",
+		Metric:    AlertMetricDiskUsedPercent,
+		Operator:  AlertOperatorGreaterThan,
+		Threshold: 90,
+		Duration:  5 * time.Minute,
+		Level:     "warning",
+	}
+
+	out, err := json.Marshal(rule)
+	assert.NoError(err)
+	assert.Contains(string(out), `"duration":"5m0s"`)
+
+	parsed := &AlertRule{}
+	assert.NoError(json.Unmarshal(out, parsed))
+	assert.Equal(rule, parsed)
+
+	assert.NoError(json.Unmarshal([]byte(`{"id":"a","duration":"1m"}`), parsed))
+	assert.Equal(time.Minute, parsed.Duration)
+	assert.Error(json.Unmarshal([]byte(`{"id":"a","duration":60000000000}`), parsed))
+
+	assert.NoError(json.Unmarshal([]byte(`{"id":"a"}`), parsed))
+	assert.Equal(time.Duration(0), parsed.Duration)
+
+	assert.Error(json.Unmarshal([]byte(`{"id":"a","duration":"soon"}`), parsed))
+}
+
+// inState reports whether an event in the state, with the
+// acknowledged flag, satisfies a query from stateQuery.
+func inState(state string, ack bool, query bson.M) bool {
+	if want, ok := query[eventAcknowledgedKey]; ok && want != ack {
+		return false
+	}
+
+	switch q := query[eventStateKey].(type) {
+	case string:
+		return q == state
+	case bson.M:
+		for _, s := range q["$in"].([]interface{}) {
+			if s == state {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stateClause returns the state query of an update from
+// transitionUpdates.
+func stateClause(u db.BulkUpdate) bson.M {
+	return u.Query.(bson.M)["$and"].([]bson.M)[1]
+}
+
+func TestClearedAlertEventsReopenOnRecurrence(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	cleared := transitionUpdates(alertEventsQuery("cpu", "host"), []string{EventOpen, EventReopened}, alertClearedTransition(now))
+	require.Len(cleared, 2)
+	assert.True(inState(EventOpen, false, stateClause(cleared[0])))
+	assert.True(inState(EventReopened, false, stateClause(cleared[1])))
+
+	set := cleared[0].Update.(bson.M)["$set"].(bson.M)
+	state, ack := set[eventStateKey].(string), set[eventAcknowledgedKey].(bool)
+	assert.Equal(EventResolved, state)
+	assert.True(ack)
+
+	// the cleared event is no longer open, and the next time the alert
+	// fires, the recurrence reopens it.
+	assert.False(inState(state, ack, stateQuery(EventOpen)))
+
+	recurrence := &Event{Key: "cpu-host", LastSeen: now.Add(time.Minute)}
+	reopened := false
+	for _, u := range recurrence.reopenUpdates() {
+		if inState(state, ack, stateClause(u)) {
+			reopened = true
+			assert.Equal(EventReopened, u.Update.(bson.M)["$set"].(bson.M)[eventStateKey])
+		}
+	}
+	assert.True(reopened)
+}
+
+func TestAlertRuleFiresAfterDurationAndResolves(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rule := &AlertRule{
+		ID:        "memory",
+		Metric:    AlertMetricMemoryUsedPercent,
+		Operator:  AlertOperatorGreaterThan,
+		Threshold: 90,
+		Duration:  5 * time.Minute,
+		Level:     "error",
+	}
+	state := &AlertState{ID: AlertStateID{RuleID: rule.ID, Hostname: "host"}}
+
+	start := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(offset time.Duration, used float64) *SystemInformationRecord {
+		rec := &SystemInformationRecord{Hostname: "host", Timestamp: start.Add(offset)}
+		rec.Data.VMStat.UsedPercent = used
+		return rec
+	}
+
+	// the breach starts the pending period without firing.
+	msg, cleared := rule.advance(state, sample(0, 95))
+	assert.Nil(msg)
+	assert.False(cleared)
+	assert.Equal(start, state.PendingSince)
+	assert.False(state.Firing)
+
+	msg, _ = rule.advance(state, sample(time.Minute, 96))
+	assert.Nil(msg)
+	assert.Equal(start, state.PendingSince)
+
+	// the rule fires once the breach has lasted the duration.
+	msg, cleared = rule.advance(state, sample(5*time.Minute, 97))
+	require.NotNil(msg)
+	assert.False(cleared)
+	assert.True(state.Firing)
+	assert.Equal(level.Error, msg.Priority())
+	assert.Equal(alertStateFiring, msg.Raw().(message.Fields)[alertEventPayloadStateField])
+
+	// firing again does not send another message.
+	msg, _ = rule.advance(state, sample(6*time.Minute, 98))
+	assert.Nil(msg)
+
+	// clearing resolves the rule and its events.
+	msg, cleared = rule.advance(state, sample(7*time.Minute, 50))
+	require.NotNil(msg)
+	assert.True(cleared)
+	assert.False(state.Firing)
+	assert.True(state.PendingSince.IsZero())
+	assert.Equal(level.Notice, msg.Priority())
+	assert.Equal(alertStateResolved, msg.Raw().(message.Fields)[alertEventPayloadStateField])
+
+	msg, cleared = rule.advance(state, sample(8*time.Minute, 50))
+	assert.Nil(msg)
+	assert.False(cleared)
+}
+
+func TestAlertStateIDsDoNotCollide(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	one, err := bson.Marshal(AlertStateID{RuleID: "a.b", Hostname: "c"})
+	require.NoError(err)
+	two, err := bson.Marshal(AlertStateID{RuleID: "a", Hostname: "b.c"})
+	require.NoError(err)
+
+	assert.NotEqual(one, two)
+}
+
+func TestAlertRuleCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	info := &message.SystemInfo{}
+	assert.NoError(json.Unmarshal([]byte(`{"usage": [
+		{"path": "/", "usedPercent": 42},
+		{"path": "/data", "usedPercent": 95}]}`), info))
+	info.VMStat.Available = 400 * 1024 * 1024
+
+	rule := &AlertRule{
+		Metric:    AlertMetricDiskUsedPercent,
+		Operator:  AlertOperatorGreaterThan,
+		Threshold: 90,
+	}
+	triggered, value, subject := rule.Check(info)
+	assert.True(triggered)
+	assert.Equal(95.0, value)
+	assert.Equal("/data", subject)
+
+	rule.Threshold = 99
+	triggered, _, subject = rule.Check(info)
+	assert.False(triggered)
+	assert.Equal("", subject)
+
+	rule = &AlertRule{
+		Metric:    AlertMetricMemoryAvailable,
+		Operator:  AlertOperatorLessThan,
+		Threshold: 500 * 1024 * 1024,
+	}
+	triggered, value, _ = rule.Check(info)
+	assert.True(triggered)
+	assert.Equal(float64(info.VMStat.Available), value)
+
+	info.VMStat.Available = 600 * 1024 * 1024
+	triggered, _, _ = rule.Check(info)
+	assert.False(triggered)
+}
@@ -0,0 +1,393 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/mongodb/grip/send"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	anomalyBaselineCollection = "sysinfo.anomalies.baselines"
+	anomalyCursorCollection   = "sysinfo.anomalies.cursors"
+	anomalyCollection         = "sysinfo.anomalies"
+)
+
+// anomalyRetention is how long detected anomalies are kept.
+const anomalyRetention = 90 * 24 * time.Hour
+
+func init() {
+	registerIndexes(anomalyCollection,
+		mgo.Index{Key: []string{anomalyHostKey, anomalyMetricKey, anomalyTimestampKey}},
+		mgo.Index{Key: []string{anomalyTimestampKey}, ExpireAfter: anomalyRetention})
+}
+
+// Metrics tracked by anomaly detection. Rates are computed from the
+// difference between consecutive samples from a host.
+const (
+	AnomalyMetricCPU         = "cpu.used_percent"
+	AnomalyMetricMemory      = "memory.used_percent"
+	AnomalyMetricNetworkSent = "network.sent_bytes_per_second"
+	AnomalyMetricNetworkRecv = "network.recv_bytes_per_second"
+)
+
+const (
+	// anomalyBaselineMinSamples is the number of samples a baseline
+	// needs before it is used to flag anomalies.
+	anomalyBaselineMinSamples = 30
+
+	// anomalyBaselineWeight is the weight of each new sample once a
+	// baseline has more than 1/weight samples; until then, the
+	// baseline is the plain mean and variance of all samples.
+	anomalyBaselineWeight = 0.01
+
+	// anomalyInitialLookback is how far back detection starts for
+	// hosts that have not been processed before.
+	anomalyInitialLookback = time.Hour
+)
+
+// HourOfWeek returns the hour since the start of the week (Sunday,
+// midnight UTC), from 0 to 167.
+func HourOfWeek(ts time.Time) int {
+	ts = ts.UTC()
+	return int(ts.Weekday())*24 + ts.Hour()
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// baselines
+
+// AnomalyBaseline holds the exponentially weighted mean and variance of
+// a metric for one host during one hour of the week, so that the
+// baseline follows slow changes in the host's workload, and daily and
+// weekly cycles are not flagged as anomalies.
+type AnomalyBaseline struct {
+	ID         string  `bson:"_id" json:"id"`
+	Hostname   string  `bson:"hn" json:"host"`
+	Metric     string  `bson:"metric" json:"metric"`
+	HourOfWeek int     `bson:"how" json:"hour_of_week"`
+	Count      int     `bson:"n" json:"count"`
+	Mean       float64 `bson:"mean" json:"mean"`
+	Variance   float64 `bson:"var" json:"variance"`
+}
+
+func anomalyBaselineID(host, metric string, how int) string {
+	return fmt.Sprintf("%s|%s|%d", host, metric, how)
+}
+
+// StdDev returns the standard deviation of the baseline.
+func (b *AnomalyBaseline) StdDev() float64 { return math.Sqrt(b.Variance) }
+
+// Update adds the value to the baseline.
+func (b *AnomalyBaseline) Update(value float64) {
+	b.Count++
+
+	weight := 1 / float64(b.Count)
+	if weight < anomalyBaselineWeight {
+		weight = anomalyBaselineWeight
+	}
+
+	diff := value - b.Mean
+	increment := weight * diff
+	b.Mean += increment
+	b.Variance = (1 - weight) * (b.Variance + diff*increment)
+}
+
+// ZScore returns the number of standard deviations between the value
+// and the baseline's mean. The result is only meaningful if the second
+// return value is true, which requires that the baseline has enough
+// samples and nonzero variance.
+func (b *AnomalyBaseline) ZScore(value float64) (float64, bool) {
+	std := b.StdDev()
+	if b.Count < anomalyBaselineMinSamples || std == 0 {
+		return 0, false
+	}
+
+	return (value - b.Mean) / std, true
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// anomalies
+
+// Anomaly records a sample whose value for a metric deviated from the
+// host's baseline for that hour of the week.
+type Anomaly struct {
+	ID         bson.ObjectId `bson:"_id" json:"id"`
+	Hostname   string        `bson:"hn" json:"host"`
+	Metric     string        `bson:"metric" json:"metric"`
+	Timestamp  time.Time     `bson:"ts" json:"time"`
+	SampleID   bson.ObjectId `bson:"sample" json:"sample_id"`
+	Value      float64       `bson:"value" json:"value"`
+	Mean       float64       `bson:"mean" json:"mean"`
+	StdDev     float64       `bson:"stddev" json:"stddev"`
+	ZScore     float64       `bson:"z" json:"zscore"`
+	HourOfWeek int           `bson:"how" json:"hour_of_week"`
+}
+
+var (
+	anomalyTimestampKey = bsonutil.MustHaveTag(Anomaly{}, "Timestamp")
+	anomalyHostKey      = bsonutil.MustHaveTag(Anomaly{}, "Hostname")
+	anomalyMetricKey    = bsonutil.MustHaveTag(Anomaly{}, "Metric")
+)
+
+func (a *Anomaly) Insert() error {
+	if a.ID == "" {
+		a.ID = bson.NewObjectId()
+	}
+
+	return errors.WithStack(db.Insert(anomalyCollection, a))
+}
+
+// Message returns a system event describing the anomaly.
+func (a *Anomaly) Message() message.Composer {
+	msg := fmt.Sprintf("anomalous %s on '%s': %.2f is %.1f standard deviations from the baseline of %.2f",
+		a.Metric, a.Hostname, a.Value, a.ZScore, a.Mean)
+
+	return message.NewFieldsMessage(level.Warning, msg, message.Fields{
+		"host":         a.Hostname,
+		"metric":       a.Metric,
+		"time":         a.Timestamp,
+		"sample":       a.SampleID.Hex(),
+		"value":        a.Value,
+		"mean":         a.Mean,
+		"stddev":       a.StdDev,
+		"zscore":       a.ZScore,
+		"hour_of_week": a.HourOfWeek,
+	})
+}
+
+type Anomalies struct {
+	slice     []*Anomaly
+	populated bool
+}
+
+func (a *Anomalies) Slice() []*Anomaly { return a.slice }
+func (a *Anomalies) IsNil() bool       { return !a.populated }
+
+// FindBetween populates the anomalies detected in the time range, most
+// recent first. The host and metric filter the results if they are not
+// empty.
+func (a *Anomalies) FindBetween(host, metric string, start, end time.Time, limit int) error {
+	filter := bson.M{anomalyTimestampKey: bson.M{"$gte": start, "$lt": end}}
+	if host != "" {
+		filter[anomalyHostKey] = host
+	}
+	if metric != "" {
+		filter[anomalyMetricKey] = metric
+	}
+
+	query := db.Query(filter).Sort("-" + anomalyTimestampKey)
+	if limit > 0 {
+		query.Limit(limit)
+	}
+
+	a.populated = false
+	if err := query.FindAll(anomalyCollection, &a.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	a.populated = true
+
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// detection
+
+// anomalyCursor records how far detection has processed a host's
+// samples, and the counters from the last sample, to compute rates.
+type anomalyCursor struct {
+	Hostname string    `bson:"_id"`
+	Last     time.Time `bson:"last"`
+	CPUBusy  float64   `bson:"cpu_busy"`
+	CPUTotal float64   `bson:"cpu_total"`
+	NetSent  uint64    `bson:"net_sent"`
+	NetRecv  uint64    `bson:"net_recv"`
+}
+
+// advance computes the metrics for the sample, using the counters from
+// the previous sample for rates, and moves the cursor to the sample.
+// Rates are omitted for the first sample and when counters reset.
+func (c *anomalyCursor) advance(rec *SystemInformationRecord) map[string]float64 {
+	cpu := rec.Data.CPU
+	busy := cpu.User + cpu.System + cpu.Nice + cpu.Iowait + cpu.Irq + cpu.Softirq + cpu.Steal
+	total := busy + cpu.Idle
+	net := rec.Data.NetStat
+
+	out := map[string]float64{
+		AnomalyMetricMemory: rec.Data.VMStat.UsedPercent,
+	}
+
+	elapsed := rec.Timestamp.Sub(c.Last).Seconds()
+	if !c.Last.IsZero() && elapsed > 0 {
+		if total > c.CPUTotal && busy >= c.CPUBusy {
+			out[AnomalyMetricCPU] = 100 * (busy - c.CPUBusy) / (total - c.CPUTotal)
+		}
+
+		if net.BytesSent >= c.NetSent && net.BytesRecv >= c.NetRecv {
+			out[AnomalyMetricNetworkSent] = float64(net.BytesSent-c.NetSent) / elapsed
+			out[AnomalyMetricNetworkRecv] = float64(net.BytesRecv-c.NetRecv) / elapsed
+		}
+	}
+
+	c.Last = rec.Timestamp
+	c.CPUBusy = busy
+	c.CPUTotal = total
+	c.NetSent = net.BytesSent
+	c.NetRecv = net.BytesRecv
+
+	return out
+}
+
+// AnomalyDetector compares samples to rolling per-host baselines, and
+// records samples that deviate by more than the z-score threshold as
+// anomalies and system events.
+type AnomalyDetector struct {
+	ZScore float64
+	Sender send.Sender
+
+	baselines map[string]*AnomalyBaseline
+}
+
+// check compares the metrics from a sample to the baselines, and then
+// adds them to the baselines. It returns the anomalies found.
+func (d *AnomalyDetector) check(rec *SystemInformationRecord, metrics map[string]float64) ([]*Anomaly, error) {
+	how := HourOfWeek(rec.Timestamp)
+	out := []*Anomaly{}
+
+	for metric, value := range metrics {
+		baseline, err := d.getBaseline(rec.Hostname, metric, how)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if z, ok := baseline.ZScore(value); ok && math.Abs(z) > d.ZScore {
+			out = append(out, &Anomaly{
+				Hostname:   rec.Hostname,
+				Metric:     metric,
+				Timestamp:  rec.Timestamp,
+				SampleID:   rec.ID,
+				Value:      value,
+				Mean:       baseline.Mean,
+				StdDev:     baseline.StdDev(),
+				ZScore:     z,
+				HourOfWeek: how,
+			})
+		}
+
+		baseline.Update(value)
+	}
+
+	return out, nil
+}
+
+func (d *AnomalyDetector) getBaseline(host, metric string, how int) (*AnomalyBaseline, error) {
+	if d.baselines == nil {
+		d.baselines = map[string]*AnomalyBaseline{}
+	}
+
+	id := anomalyBaselineID(host, metric, how)
+	if baseline, ok := d.baselines[id]; ok {
+		return baseline, nil
+	}
+
+	baseline := &AnomalyBaseline{}
+	err := db.Query(bson.M{"_id": id}).FindOne(anomalyBaselineCollection, baseline)
+	if errors.Cause(err) == mgo.ErrNotFound {
+		baseline = &AnomalyBaseline{ID: id, Hostname: host, Metric: metric, HourOfWeek: how}
+	} else if err != nil {
+		return nil, errors.Wrap(err, "problem finding anomaly baseline")
+	}
+
+	d.baselines[id] = baseline
+	return baseline, nil
+}
+
+// ProcessHost checks all of the host's samples since the last time the
+// host was processed, up to the specified time, and returns the number
+// of anomalies found.
+func (d *AnomalyDetector) ProcessHost(host string, until time.Time) (int, error) {
+	cursor := &anomalyCursor{}
+	err := db.Query(bson.M{"_id": host}).FindOne(anomalyCursorCollection, cursor)
+	start := cursor.Last.Add(time.Nanosecond)
+	if errors.Cause(err) == mgo.ErrNotFound {
+		cursor = &anomalyCursor{Hostname: host}
+		start = until.Add(-anomalyInitialLookback)
+	} else if err != nil {
+		return 0, errors.Wrap(err, "problem finding anomaly cursor")
+	}
+
+	d.baselines = map[string]*AnomalyBaseline{}
+	var count int
+	err = streamSysInfo(host, start, until, func(rec *SystemInformationRecord) error {
+		anomalies, err := d.check(rec, cursor.advance(rec))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, anomaly := range anomalies {
+			if err = anomaly.Insert(); err != nil {
+				return errors.Wrap(err, "problem saving anomaly")
+			}
+			if d.Sender != nil {
+				d.Sender.Send(anomaly.Message())
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	// save the progress so far, even if processing failed part way
+	// through, so that the baselines and cursor remain consistent.
+	for id, baseline := range d.baselines {
+		if serr := db.UpsertID(anomalyBaselineCollection, id, baseline); serr != nil {
+			return count, errors.Wrap(serr, "problem saving anomaly baseline")
+		}
+	}
+
+	if !cursor.Last.IsZero() {
+		if serr := db.UpsertID(anomalyCursorCollection, host, cursor); serr != nil {
+			return count, errors.Wrap(serr, "problem saving anomaly cursor")
+		}
+	}
+
+	return count, errors.WithStack(err)
+}
+
+// Process checks the samples from every host that reported in the
+// window before the specified time, and returns the number of
+// anomalies found.
+func (d *AnomalyDetector) Process(window time.Duration, until time.Time) (int, error) {
+	hosts := []struct {
+		Hostname string `bson:"_id"`
+	}{}
+
+	pipeline := []bson.M{
+		{"$match": sysInfoBucketFilter("", until.Add(-window), until)},
+		{"$group": bson.M{"_id": "$" + sysInfoBucketHostKey}},
+	}
+	if err := db.Aggregate(sysInfoBucketCollection, pipeline, &hosts); err != nil {
+		return 0, errors.Wrap(err, "problem finding hosts")
+	}
+
+	var count int
+	catcher := grip.NewCatcher()
+	for _, host := range hosts {
+		num, err := d.ProcessHost(host.Hostname, until)
+		count += num
+		catcher.Add(errors.Wrapf(err, "problem detecting anomalies for '%s'", host.Hostname))
+	}
+
+	return count, catcher.Resolve()
+}
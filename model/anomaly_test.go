@@ -0,0 +1,135 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHourOfWeek(t *testing.T) {
+	assert := assert.New(t)
+
+	// March 5th, 2017 was a Sunday.
+	sunday := time.Date(2017, time.March, 5, 0, 30, 0, 0, time.UTC)
+	assert.Equal(0, HourOfWeek(sunday))
+	assert.Equal(25, HourOfWeek(sunday.Add(25*time.Hour)))
+	assert.Equal(167, HourOfWeek(sunday.Add(-time.Hour)))
+
+	est := time.FixedZone("EST", -5*60*60)
+	assert.Equal(HourOfWeek(sunday), HourOfWeek(sunday.In(est)))
+}
+
+func TestAnomalyBaselineMatchesMeanAndVariance(t *testing.T) {
+	assert := assert.New(t)
+	baseline := &AnomalyBaseline{}
+
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		baseline.Update(v)
+	}
+
+	assert.Equal(8, baseline.Count)
+	assert.InDelta(5, baseline.Mean, 0.0001)
+	assert.InDelta(2, baseline.StdDev(), 0.0001)
+}
+
+func TestAnomalyBaselineZScore(t *testing.T) {
+	assert := assert.New(t)
+	baseline := &AnomalyBaseline{}
+
+	for i := 0; i < anomalyBaselineMinSamples-1; i++ {
+		baseline.Update(float64(10 + i%2))
+	}
+	_, ok := baseline.ZScore(100)
+	assert.False(ok, "too few samples")
+
+	baseline.Update(10)
+	z, ok := baseline.ZScore(100)
+	assert.True(ok)
+	assert.True(z > 3)
+
+	z, ok = baseline.ZScore(baseline.Mean)
+	assert.True(ok)
+	assert.InDelta(0, z, 0.0001)
+
+	flat := &AnomalyBaseline{}
+	for i := 0; i < anomalyBaselineMinSamples; i++ {
+		flat.Update(10)
+	}
+	_, ok = flat.ZScore(100)
+	assert.False(ok, "no variance")
+}
+
+func TestAnomalyBaselineFollowsShift(t *testing.T) {
+	assert := assert.New(t)
+	baseline := &AnomalyBaseline{}
+
+	for i := 0; i < 1000; i++ {
+		baseline.Update(10)
+	}
+	for i := 0; i < 1000; i++ {
+		baseline.Update(50)
+	}
+
+	assert.InDelta(50, baseline.Mean, 0.01)
+	assert.False(math.IsNaN(baseline.Variance))
+}
+
+func TestAnomalyCursorComputesRates(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now().Round(time.Second)
+
+	sample := func(offset time.Duration, busy, idle float64, sent, recv uint64, mem float64) *SystemInformationRecord {
+		rec := &SystemInformationRecord{Timestamp: start.Add(offset)}
+		rec.Data.CPU.User = busy
+		rec.Data.CPU.Idle = idle
+		rec.Data.NetStat.BytesSent = sent
+		rec.Data.NetStat.BytesRecv = recv
+		rec.Data.VMStat.UsedPercent = mem
+		return rec
+	}
+
+	cursor := &anomalyCursor{}
+	metrics := cursor.advance(sample(0, 100, 100, 1000, 2000, 40))
+	assert.Equal(map[string]float64{AnomalyMetricMemory: 40}, metrics)
+
+	metrics = cursor.advance(sample(10*time.Second, 130, 170, 2000, 4000, 50))
+	assert.InDelta(30, metrics[AnomalyMetricCPU], 0.0001)
+	assert.InDelta(100, metrics[AnomalyMetricNetworkSent], 0.0001)
+	assert.InDelta(200, metrics[AnomalyMetricNetworkRecv], 0.0001)
+	assert.Equal(50.0, metrics[AnomalyMetricMemory])
+
+	// counters reset when the host restarts.
+	metrics = cursor.advance(sample(20*time.Second, 10, 10, 10, 10, 20))
+	assert.Len(metrics, 1)
+	assert.Equal(start.Add(20*time.Second), cursor.Last)
+}
+
+func TestAnomalyDetectorFlagsDeviations(t *testing.T) {
+	assert := assert.New(t)
+	ts := time.Date(2017, time.March, 6, 12, 0, 0, 0, time.UTC)
+	how := HourOfWeek(ts)
+
+	detector := &AnomalyDetector{ZScore: 3, baselines: map[string]*AnomalyBaseline{}}
+	baseline := &AnomalyBaseline{ID: anomalyBaselineID("a", AnomalyMetricMemory, how)}
+	for i := 0; i < 100; i++ {
+		baseline.Update(float64(40 + i%5))
+	}
+	detector.baselines[baseline.ID] = baseline
+
+	rec := &SystemInformationRecord{Hostname: "a", Timestamp: ts}
+	anomalies, err := detector.check(rec, map[string]float64{AnomalyMetricMemory: 42})
+	assert.NoError(err)
+	assert.Len(anomalies, 0)
+
+	anomalies, err = detector.check(rec, map[string]float64{AnomalyMetricMemory: 95})
+	assert.NoError(err)
+	if assert.Len(anomalies, 1) {
+		assert.Equal("a", anomalies[0].Hostname)
+		assert.Equal(how, anomalies[0].HourOfWeek)
+		assert.True(anomalies[0].ZScore > 3)
+		assert.True(anomalies[0].Message().Loggable())
+	}
+	assert.Equal(102, baseline.Count)
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/cgroup"
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const containerInfoCollection = "sysinfo.containers"
+
+func init() {
+	registerIndexes(containerInfoCollection, mgo.Index{Key: []string{containerInfoHostKey, containerInfoContainerKey, containerInfoTimestampKey}})
+}
+
+// ContainerInfoRecord holds a sample of the cgroup accounting for a
+// single container, reported from inside of the container.
+type ContainerInfoRecord struct {
+	ID        bson.ObjectId     `bson:"_id" json:"id"`
+	Timestamp time.Time         `bson:"ts" json:"time"`
+	Hostname  string            `bson:"hn" json:"hostname"`
+	Container string            `bson:"container" json:"container"`
+	Labels    map[string]string `bson:"labels,omitempty" json:"labels,omitempty"`
+	Data      cgroup.Stats      `bson:"cgroup" json:"cgroup"`
+	populated bool
+}
+
+var (
+	containerInfoIDKey        = bsonutil.MustHaveTag(ContainerInfoRecord{}, "ID")
+	containerInfoTimestampKey = bsonutil.MustHaveTag(ContainerInfoRecord{}, "Timestamp")
+	containerInfoHostKey      = bsonutil.MustHaveTag(ContainerInfoRecord{}, "Hostname")
+	containerInfoContainerKey = bsonutil.MustHaveTag(ContainerInfoRecord{}, "Container")
+)
+
+func (i *ContainerInfoRecord) Insert() error {
+	if i.ID == "" {
+		i.ID = bson.NewObjectId()
+	}
+
+	if i.Container == "" {
+		return errors.New("container records must specify a container")
+	}
+
+	if err := ValidateSystemInfoLabels(i.Labels); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(db.Insert(containerInfoCollection, i))
+}
+
+func (i *ContainerInfoRecord) FindID(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return errors.Errorf("'%s' is not a valid record id", id)
+	}
+
+	query := db.Query(bson.M{
+		containerInfoIDKey: bson.ObjectIdHex(id),
+	})
+
+	i.populated = false
+	if err := query.FindOne(containerInfoCollection, i); err != nil {
+		return errors.WithStack(err)
+	}
+	i.populated = true
+
+	return nil
+}
+
+type ContainerInfoRecords struct {
+	slice     []*ContainerInfoRecord
+	populated bool
+}
+
+func (i *ContainerInfoRecords) IsNil() bool                   { return !i.populated }
+func (i *ContainerInfoRecords) Slice() []*ContainerInfoRecord { return i.slice }
+
+// FindBetween populates the records, in time order, with samples
+// reported in the time range. The host and container filter the
+// results if they are not empty.
+func (i *ContainerInfoRecords) FindBetween(host, container string, start, end time.Time, limit int) error {
+	filter := bson.M{
+		containerInfoTimestampKey: bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}
+
+	if host != "" {
+		filter[containerInfoHostKey] = host
+	}
+
+	if container != "" {
+		filter[containerInfoContainerKey] = container
+	}
+
+	query := db.Query(filter).Sort(containerInfoTimestampKey)
+	if limit > 0 {
+		query.Limit(limit)
+	}
+
+	i.populated = false
+	if err := query.FindAll(containerInfoCollection, &i.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	i.populated = true
+
+	return nil
+}
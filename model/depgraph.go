@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/evergreen-ci/sink/db"
 	"github.com/evergreen-ci/sink/db/bsonutil"
@@ -17,32 +18,46 @@ const (
 	depEdgeCollection     = "depgraph.edges"
 )
 
+func init() {
+	registerIndexes(depNodeCollection, mgo.Index{Key: []string{graphNodeGraphNameKey}})
+	registerIndexes(depEdgeCollection, mgo.Index{Key: []string{graphEdgeGraphKey}})
+}
+
 type GraphMetadata struct {
-	BuildID string `bson:"_id"`
+	BuildID string      `bson:"_id" json:"id"`
+	Import  GraphImport `bson:"import" json:"import"`
+
+	// Revision increases each time the graph's nodes or edges
+	// change, so that cached copies of the graph can tell that they
+	// are stale.
+	Revision int `bson:"revision" json:"revision"`
 
 	populated bool
 }
 
 var (
-	graphMetadataIDKey = bsonutil.MustHaveTag(GraphMetadata{}, "BuildID")
+	graphMetadataIDKey       = bsonutil.MustHaveTag(GraphMetadata{}, "BuildID")
+	graphMetadataImportKey   = bsonutil.MustHaveTag(GraphMetadata{}, "Import")
+	graphMetadataRevisionKey = bsonutil.MustHaveTag(GraphMetadata{}, "Revision")
 )
 
-func (g *GraphMetadata) IsNil() bool { return g.populated }
+func (g *GraphMetadata) IsNil() bool { return !g.populated }
 
 func (g *GraphMetadata) Insert() error { return errors.WithStack(db.Insert(depMetadataCollection, g)) }
 
+// Find populates the graph with the specified ID. It is not an error
+// if the graph does not exist; use IsNil to check.
 func (g *GraphMetadata) Find(id string) error {
-	err := db.Query(bson.M{graphMetadataIDKey: id}).FindOne(depMetadataCollection, g)
-
 	g.populated = false
-	if err == mgo.ErrNotFound {
+	err := db.Query(bson.M{graphMetadataIDKey: id}).FindOne(depMetadataCollection, g)
+	if errors.Cause(err) == mgo.ErrNotFound {
 		return nil
 	}
-	g.populated = true
 
 	if err != nil {
 		return errors.Wrap(err, "problem running graph metadata query")
 	}
+	g.populated = true
 
 	return nil
 }
@@ -86,7 +101,9 @@ func (g *GraphMetadata) GetEdges() <-chan *GraphEdge {
 
 		doc := &GraphEdge{}
 		for iter.Next(doc) {
+			doc.populated = true
 			out <- doc
+			doc = &GraphEdge{}
 		}
 
 		close(out)
@@ -97,7 +114,7 @@ func (g *GraphMetadata) GetEdges() <-chan *GraphEdge {
 
 func (g *GraphMetadata) AllEdges() ([]*GraphEdge, error) {
 	out := []*GraphEdge{}
-	err := db.Query(bson.M{graphEdgeGraphKey: g.BuildID}).FindAll(depEdgeCollection, out)
+	err := db.Query(bson.M{graphEdgeGraphKey: g.BuildID}).FindAll(depEdgeCollection, &out)
 
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -119,7 +136,9 @@ func (g *GraphMetadata) GetNodes() <-chan *GraphNode {
 
 		doc := &GraphNode{}
 		for iter.Next(doc) {
+			doc.populated = true
 			out <- doc
+			doc = &GraphNode{}
 		}
 
 		close(out)
@@ -130,7 +149,7 @@ func (g *GraphMetadata) GetNodes() <-chan *GraphNode {
 
 func (g *GraphMetadata) AllNodes() ([]*GraphNode, error) {
 	out := []*GraphNode{}
-	err := db.Query(bson.M{graphNodeGraphNameKey: g.BuildID}).FindAll(depNodeCollection, out)
+	err := db.Query(bson.M{graphNodeGraphNameKey: g.BuildID}).FindAll(depNodeCollection, &out)
 
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -160,7 +179,7 @@ type GraphNode struct {
 	populated bool
 }
 
-func (n *GraphNode) IsNil() bool { return n.populated }
+func (n *GraphNode) IsNil() bool { return !n.populated }
 
 func (n *GraphNode) Insert() error {
 	if !n.populated {
@@ -193,7 +212,7 @@ type GraphEdge struct {
 	populated bool
 }
 
-func (e *GraphEdge) IsNil() bool { return e.populated }
+func (e *GraphEdge) IsNil() bool { return !e.populated }
 
 func (e *GraphEdge) Insert() error {
 	if !e.populated {
@@ -206,3 +225,202 @@ func (e *GraphEdge) Insert() error {
 
 	return errors.WithStack(db.Insert(depEdgeCollection, e))
 }
+
+////////////////////////////////////////////////////////////////////////
+//
+// Adding Nodes and Edges
+
+// Validate returns an error if the node is not complete.
+func (n *GraphNode) Validate() error {
+	if n.Name == "" {
+		return errors.Errorf("node %d does not have a name", n.GraphID)
+	}
+
+	if n.GraphID < 0 {
+		return errors.Errorf("node '%s' has invalid index %d", n.Name, n.GraphID)
+	}
+
+	if n.Relationships.Type < depgraph.Library || n.Relationships.Type > depgraph.Artifact {
+		return errors.Errorf("node '%s' has invalid type %d", n.Name, n.Relationships.Type)
+	}
+
+	return nil
+}
+
+// Validate returns an error if the edge is not complete.
+func (e *GraphEdge) Validate() error {
+	if e.Type < depgraph.LibraryToLibrary || e.Type > depgraph.ArtifactToLibrary {
+		return errors.Errorf("edge from '%s' has invalid type %d", e.FromNode.Name, e.Type)
+	}
+
+	if e.FromNode.Name == "" {
+		return errors.Errorf("edge of type '%s' from node %d does not name its source", e.Type, e.FromNode.GraphID)
+	}
+
+	if len(e.ToNodes) == 0 {
+		return errors.Errorf("edge of type '%s' from '%s' has no targets", e.Type, e.FromNode.Name)
+	}
+
+	for _, to := range e.ToNodes {
+		if to.Name == "" {
+			return errors.Errorf("edge of type '%s' from '%s' has a target without a name", e.Type, e.FromNode.Name)
+		}
+	}
+
+	return nil
+}
+
+// AddNodes validates the nodes and stores them in the graph in one
+// bulk write. Nodes are identified by their index in the graph, and
+// adding a node that the graph already has replaces it, so that
+// adding the same nodes again has no effect.
+func (g *GraphMetadata) AddNodes(nodes []*depgraph.Node) (int, error) {
+	updates := make([]db.BulkUpdate, 0, len(nodes))
+	seen := map[string]struct{}{}
+
+	for idx, source := range nodes {
+		node := g.MakeNode(source)
+		if node == nil {
+			return 0, errors.Errorf("node %d is empty", idx)
+		}
+
+		if err := node.Validate(); err != nil {
+			return 0, errors.Wrapf(err, "node %d is not valid", idx)
+		}
+
+		if _, ok := seen[node.ID]; ok {
+			return 0, errors.Errorf("node %d duplicates index %d", idx, node.GraphID)
+		}
+		seen[node.ID] = struct{}{}
+
+		updates = append(updates, db.BulkUpdate{
+			Query:  bson.M{graphNodeIDKey: node.ID},
+			Update: node,
+			Upsert: true,
+		})
+	}
+
+	num, err := db.RunBulk(depNodeCollection, updates)
+	if num > 0 {
+		if rerr := g.incRevision(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+
+	return num, errors.Wrapf(err, "problem adding nodes to graph '%s'", g.BuildID)
+}
+
+// AddEdges validates the edges and stores them in the graph in one
+// bulk write. The nodes that the edges connect must already be in the
+// graph. Edges are identified by their type and source node, and
+// adding an edge that the graph already has replaces it, so that
+// adding the same edges again has no effect.
+func (g *GraphMetadata) AddEdges(edges []*depgraph.Edge) (int, error) {
+	updates := make([]db.BulkUpdate, 0, len(edges))
+	seen := map[string]struct{}{}
+	indexes := map[int]struct{}{}
+
+	for idx, source := range edges {
+		edge := g.MakeEdge(source)
+		if edge == nil {
+			return 0, errors.Errorf("edge %d is empty", idx)
+		}
+
+		if err := edge.Validate(); err != nil {
+			return 0, errors.Wrapf(err, "edge %d is not valid", idx)
+		}
+
+		if _, ok := seen[edge.ID]; ok {
+			return 0, errors.Errorf("edge %d duplicates the '%s' edge from '%s'", idx, edge.Type, edge.FromNode.Name)
+		}
+		seen[edge.ID] = struct{}{}
+
+		indexes[edge.FromNode.GraphID] = struct{}{}
+		for _, to := range edge.ToNodes {
+			indexes[to.GraphID] = struct{}{}
+		}
+
+		updates = append(updates, db.BulkUpdate{
+			Query:  bson.M{graphEdgeIDKey: edge.ID},
+			Update: edge,
+			Upsert: true,
+		})
+	}
+
+	if err := g.checkNodesExist(indexes); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	num, err := db.RunBulk(depEdgeCollection, updates)
+	if num > 0 {
+		if rerr := g.incRevision(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+
+	return num, errors.Wrapf(err, "problem adding edges to graph '%s'", g.BuildID)
+}
+
+// Clear removes the graph's nodes and edges, so that an import
+// replaces the graph rather than adding to it.
+func (g *GraphMetadata) Clear() error {
+	if err := db.Query(bson.M{graphEdgeGraphKey: g.BuildID}).RemoveAll(depEdgeCollection); err != nil {
+		return errors.Wrapf(err, "problem clearing edges of graph '%s'", g.BuildID)
+	}
+
+	if err := db.Query(bson.M{graphNodeGraphNameKey: g.BuildID}).RemoveAll(depNodeCollection); err != nil {
+		return errors.Wrapf(err, "problem clearing nodes of graph '%s'", g.BuildID)
+	}
+
+	return errors.WithStack(g.incRevision())
+}
+
+// incRevision records that the graph's nodes or edges have changed.
+func (g *GraphMetadata) incRevision() error {
+	err := db.Query(bson.M{graphMetadataIDKey: g.BuildID}).Update(depMetadataCollection,
+		bson.M{"$inc": bson.M{graphMetadataRevisionKey: 1}})
+	if err != nil {
+		return errors.Wrapf(err, "problem updating revision of graph '%s'", g.BuildID)
+	}
+	g.Revision++
+
+	return nil
+}
+
+// checkNodesExist returns an error if the graph does not have a node
+// at each of the indexes.
+func (g *GraphMetadata) checkNodesExist(indexes map[int]struct{}) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(indexes))
+	for id := range indexes {
+		ids = append(ids, id)
+	}
+
+	found := []GraphNode{}
+	query := db.Query(bson.M{
+		graphNodeGraphNameKey: g.BuildID,
+		graphNodeGraphIDKey:   bson.M{"$in": ids},
+	}).Project(bson.M{graphNodeGraphIDKey: 1})
+	if err := query.FindAll(depNodeCollection, &found); err != nil {
+		return errors.Wrap(err, "problem finding edge endpoints")
+	}
+
+	for _, n := range found {
+		delete(indexes, n.GraphID)
+	}
+
+	if len(indexes) > 0 {
+		missing := make([]int, 0, len(indexes))
+		for id := range indexes {
+			missing = append(missing, id)
+		}
+		sort.Ints(missing)
+
+		return errors.Errorf("graph '%s' does not have nodes %v", g.BuildID, missing)
+	}
+
+	return nil
+}
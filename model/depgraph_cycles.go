@@ -0,0 +1,216 @@
+package model
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+)
+
+// libraryEdges are the edges by which libraries depend on other
+// libraries, either directly or implicitly. Dependency cycles and the
+// impact of changes follow both.
+var libraryEdges = []depgraph.EdgeType{depgraph.LibraryToLibrary, depgraph.ImplicitLibraryToLibrary}
+
+// GraphCycle is a strongly connected component of a graph: a set of
+// nodes that each depend, transitively, on all of the others. Nodes
+// lists the members by name; Cycle is the shortest chain of
+// dependencies from the first member back to itself, without
+// repeating the first member at the end.
+type GraphCycle struct {
+	Nodes []depgraph.NodeRelationship `json:"nodes"`
+	Cycle []depgraph.NodeRelationship `json:"cycle"`
+}
+
+// Key identifies the cycle by its members, so that cycles can be
+// compared between graphs.
+func (c *GraphCycle) Key() string {
+	names := make([]string, 0, len(c.Nodes))
+	for _, n := range c.Nodes {
+		names = append(names, n.Name)
+	}
+
+	return strings.Join(names, "\x00")
+}
+
+// LibraryCycles returns the cycles among the graph's libraries, over
+// explicit and implicit library dependencies, ordered by the name of
+// each cycle's first member.
+func (g *GraphMetadata) LibraryCycles() ([]GraphCycle, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return idx.cycles(libraryEdges), nil
+}
+
+func (idx *graphIndex) cycles(types []depgraph.EdgeType) []GraphCycle {
+	follow := edgeTypeFilter(types)
+
+	out := []GraphCycle{}
+	for _, component := range idx.components(follow) {
+		if len(component) == 1 && !idx.hasSelfLoop(component[0], follow) {
+			continue
+		}
+
+		members := make([]depgraph.NodeRelationship, 0, len(component))
+		inComponent := map[int]struct{}{}
+		for _, id := range component {
+			members = append(members, idx.relationship(id))
+			inComponent[id] = struct{}{}
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+		out = append(out, GraphCycle{
+			Nodes: members,
+			Cycle: idx.shortestCycle(members[0].GraphID, inComponent, follow),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Nodes[0].Name < out[j].Nodes[0].Name })
+
+	return out
+}
+
+func (idx *graphIndex) hasSelfLoop(id int, follow func(depgraph.EdgeType) bool) bool {
+	for _, link := range idx.out[id] {
+		if link.Node == id && follow(link.Type) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// components returns the strongly connected components of the graph
+// over the edges that it follows, using Tarjan's algorithm. The search
+// keeps its own stack, as dependency chains can be deeper than is safe
+// to recurse.
+func (idx *graphIndex) components(follow func(depgraph.EdgeType) bool) [][]int {
+	type frame struct {
+		node int
+		next int
+	}
+
+	ids := make([]int, 0, len(idx.nodes))
+	for id := range idx.nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	order := map[int]int{}
+	lowlink := map[int]int{}
+	onStack := map[int]bool{}
+	stack := []int{}
+	out := [][]int{}
+	counter := 0
+
+	for _, root := range ids {
+		if _, ok := order[root]; ok {
+			continue
+		}
+
+		order[root], lowlink[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+		frames := []frame{{node: root}}
+
+		for len(frames) > 0 {
+			top := &frames[len(frames)-1]
+			links := idx.out[top.node]
+
+			if top.next < len(links) {
+				link := links[top.next]
+				top.next++
+
+				if !follow(link.Type) {
+					continue
+				}
+
+				if _, ok := order[link.Node]; !ok {
+					order[link.Node], lowlink[link.Node] = counter, counter
+					counter++
+					stack = append(stack, link.Node)
+					onStack[link.Node] = true
+					frames = append(frames, frame{node: link.Node})
+				} else if onStack[link.Node] && order[link.Node] < lowlink[top.node] {
+					lowlink[top.node] = order[link.Node]
+				}
+				continue
+			}
+
+			node := top.node
+			frames = frames[:len(frames)-1]
+			if len(frames) > 0 {
+				parent := frames[len(frames)-1].node
+				if lowlink[node] < lowlink[parent] {
+					lowlink[parent] = lowlink[node]
+				}
+			}
+
+			if lowlink[node] != order[node] {
+				continue
+			}
+
+			component := []int{}
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				component = append(component, member)
+				if member == node {
+					break
+				}
+			}
+			out = append(out, component)
+		}
+	}
+
+	return out
+}
+
+// shortestCycle returns the shortest chain of dependencies from the
+// node back to itself, within the component.
+func (idx *graphIndex) shortestCycle(start int, component map[int]struct{}, follow func(depgraph.EdgeType) bool) []depgraph.NodeRelationship {
+	prev := map[int]int{}
+	queue := []int{start}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, link := range idx.out[id] {
+			if !follow(link.Type) {
+				continue
+			}
+			if _, ok := component[link.Node]; !ok {
+				continue
+			}
+
+			if link.Node == start {
+				path := []depgraph.NodeRelationship{}
+				for n := id; n != start; n = prev[n] {
+					path = append(path, idx.relationship(n))
+				}
+				path = append(path, idx.relationship(start))
+
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+
+				return path
+			}
+
+			if _, ok := prev[link.Node]; ok {
+				continue
+			}
+			prev[link.Node] = id
+			queue = append(queue, link.Node)
+		}
+	}
+
+	return []depgraph.NodeRelationship{}
+}
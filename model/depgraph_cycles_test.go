@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tychoish/depgraph"
+)
+
+func cycleNames(nodes []depgraph.NodeRelationship) []string {
+	out := []string{}
+	for _, n := range nodes {
+		out = append(out, n.Name)
+	}
+	return out
+}
+
+func TestGraphIndexCycles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// lib0 -> lib1 -> lib2 -> lib0 with a shortcut lib0 -> lib2, a
+	// self loop on lib4, and lib5 -> lib6 -> lib5 only through a
+	// file edge, which does not count.
+	idx := makeTestGraphIndex(7, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+		{2, 0}: depgraph.ImplicitLibraryToLibrary,
+		{0, 2}: depgraph.LibraryToLibrary,
+		{2, 3}: depgraph.LibraryToLibrary,
+		{4, 4}: depgraph.LibraryToLibrary,
+		{5, 6}: depgraph.LibraryToLibrary,
+		{6, 5}: depgraph.LibraryToFile,
+	})
+
+	cycles := idx.cycles(libraryEdges)
+	require.Len(cycles, 2)
+
+	assert.Equal([]string{"lib0", "lib1", "lib2"}, cycleNames(cycles[0].Nodes))
+	assert.Equal([]string{"lib0", "lib2"}, cycleNames(cycles[0].Cycle))
+	assert.Equal([]string{"lib4"}, cycleNames(cycles[1].Nodes))
+	assert.Equal([]string{"lib4"}, cycleNames(cycles[1].Cycle))
+
+	assert.Equal("lib0\x00lib1\x00lib2", cycles[0].Key())
+
+	assert.Len(idx.cycles(nil), 3, "all edge types")
+	assert.Empty(makeTestGraphIndex(3, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+	}).cycles(libraryEdges))
+}
+
+func TestGraphIndexComponentsDeepChain(t *testing.T) {
+	edges := map[[2]int]depgraph.EdgeType{}
+	size := 100000
+	for i := 0; i < size-1; i++ {
+		edges[[2]int{i, i + 1}] = depgraph.LibraryToLibrary
+	}
+	edges[[2]int{size - 1, 0}] = depgraph.LibraryToLibrary
+
+	cycles := makeTestGraphIndex(size, edges).cycles(libraryEdges)
+	require.Len(t, cycles, 1)
+	assert.Len(t, cycles[0].Nodes, size)
+	assert.Len(t, cycles[0].Cycle, size)
+}
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+)
+
+// GraphDiff describes the changes between two graphs. Nodes are
+// matched by name, as their indexes differ between builds.
+type GraphDiff struct {
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	AddedNodes   []GraphDiffNode     `json:"added_nodes"`
+	RemovedNodes []GraphDiffNode     `json:"removed_nodes"`
+	Edges        []GraphEdgeTypeDiff `json:"edges"`
+	Libraries    []GraphLibraryDiff  `json:"libraries"`
+}
+
+// GraphDiffNode is a node that one graph has and the other does not.
+type GraphDiffNode struct {
+	Name string            `json:"name"`
+	Type depgraph.NodeType `json:"type"`
+}
+
+// GraphDiffEdge is an edge between two named nodes.
+type GraphDiffEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GraphEdgeTypeDiff holds the edges of one type that one graph has and
+// the other does not.
+type GraphEdgeTypeDiff struct {
+	Type    depgraph.EdgeType `json:"type"`
+	Added   []GraphDiffEdge   `json:"added"`
+	Removed []GraphDiffEdge   `json:"removed"`
+}
+
+// GraphLibraryDiff holds the changes to the libraries that a library,
+// which both graphs have, depends on directly.
+type GraphLibraryDiff struct {
+	Library string   `json:"library"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// Diff compares the graph to another graph, reporting the nodes and
+// edges that the other graph adds and removes.
+func (g *GraphMetadata) Diff(to *GraphMetadata) (*GraphDiff, error) {
+	fromIdx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	toIdx, err := to.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	out := diffGraphIndexes(fromIdx, toIdx)
+	out.From = g.BuildID
+	out.To = to.BuildID
+
+	return out, nil
+}
+
+func diffGraphIndexes(from, to *graphIndex) *GraphDiff {
+	out := &GraphDiff{
+		AddedNodes:   diffNodes(to, from),
+		RemovedNodes: diffNodes(from, to),
+		Edges:        []GraphEdgeTypeDiff{},
+		Libraries:    []GraphLibraryDiff{},
+	}
+
+	fromEdges := from.namedEdges()
+	toEdges := to.namedEdges()
+	for t := depgraph.LibraryToLibrary; t <= depgraph.ArtifactToLibrary; t++ {
+		diff := GraphEdgeTypeDiff{
+			Type:    t,
+			Added:   diffEdges(toEdges[t], fromEdges[t]),
+			Removed: diffEdges(fromEdges[t], toEdges[t]),
+		}
+
+		if len(diff.Added) > 0 || len(diff.Removed) > 0 {
+			out.Edges = append(out.Edges, diff)
+		}
+	}
+
+	fromLibs := from.libraryDependencies()
+	toLibs := to.libraryDependencies()
+	for _, lib := range sortedKeys(fromLibs) {
+		if _, ok := toLibs[lib]; !ok {
+			continue
+		}
+
+		diff := GraphLibraryDiff{
+			Library: lib,
+			Added:   diffNames(toLibs[lib], fromLibs[lib]),
+			Removed: diffNames(fromLibs[lib], toLibs[lib]),
+		}
+
+		if len(diff.Added) > 0 || len(diff.Removed) > 0 {
+			out.Libraries = append(out.Libraries, diff)
+		}
+	}
+
+	return out
+}
+
+// diffNodes returns the nodes in a that are not in b.
+func diffNodes(a, b *graphIndex) []GraphDiffNode {
+	out := []GraphDiffNode{}
+	for name, id := range a.byName {
+		if _, ok := b.byName[name]; !ok {
+			out = append(out, GraphDiffNode{Name: name, Type: a.nodeType(id)})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// namedEdges returns the graph's edges by type, identifying nodes by
+// name.
+func (idx *graphIndex) namedEdges() map[depgraph.EdgeType]map[GraphDiffEdge]struct{} {
+	out := map[depgraph.EdgeType]map[GraphDiffEdge]struct{}{}
+	for from, links := range idx.out {
+		for _, link := range links {
+			if out[link.Type] == nil {
+				out[link.Type] = map[GraphDiffEdge]struct{}{}
+			}
+
+			edge := GraphDiffEdge{From: idx.relationship(from).Name, To: idx.relationship(link.Node).Name}
+			out[link.Type][edge] = struct{}{}
+		}
+	}
+
+	return out
+}
+
+// diffEdges returns the edges in a that are not in b.
+func diffEdges(a, b map[GraphDiffEdge]struct{}) []GraphDiffEdge {
+	out := []GraphDiffEdge{}
+	for edge := range a {
+		if _, ok := b[edge]; !ok {
+			out = append(out, edge)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		return out[i].To < out[j].To
+	})
+
+	return out
+}
+
+// libraryDependencies returns the names of the libraries that each
+// library depends on directly, explicitly or implicitly.
+func (idx *graphIndex) libraryDependencies() map[string]map[string]struct{} {
+	out := map[string]map[string]struct{}{}
+	for id, node := range idx.nodes {
+		if node.Relationships.Type != depgraph.Library {
+			continue
+		}
+
+		deps := map[string]struct{}{}
+		for _, link := range idx.out[id] {
+			if link.Type == depgraph.LibraryToLibrary || link.Type == depgraph.ImplicitLibraryToLibrary {
+				deps[idx.relationship(link.Node).Name] = struct{}{}
+			}
+		}
+		out[node.Name] = deps
+	}
+
+	return out
+}
+
+// diffNames returns the names in a that are not in b, in order.
+func diffNames(a, b map[string]struct{}) []string {
+	out := []string{}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func sortedKeys(m map[string]map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+
+	return out
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tychoish/depgraph"
+)
+
+func TestDiffGraphIndexes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	from := makeTestGraphIndex(3, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+	})
+
+	// the later build renumbers the nodes, drops lib2, adds lib3,
+	// and moves lib1's dependency from lib2 to lib3.
+	to := newGraphIndex(0)
+	for id, name := range map[int]string{5: "lib0", 6: "lib1", 7: "lib3"} {
+		node := &depgraph.Node{Name: name, GraphID: id}
+		node.Relationships.Type = depgraph.Library
+		to.addNode(node)
+	}
+	to.addEdge(&depgraph.Edge{
+		Type:     depgraph.LibraryToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 5, Name: "lib0"},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 6, Name: "lib1"}},
+	})
+	to.addEdge(&depgraph.Edge{
+		Type:     depgraph.ImplicitLibraryToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 6, Name: "lib1"},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 7, Name: "lib3"}},
+	})
+
+	diff := diffGraphIndexes(from, to)
+	assert.Equal([]GraphDiffNode{{Name: "lib3", Type: depgraph.Library}}, diff.AddedNodes)
+	assert.Equal([]GraphDiffNode{{Name: "lib2", Type: depgraph.Library}}, diff.RemovedNodes)
+
+	require.Len(diff.Edges, 2)
+	assert.Equal(depgraph.LibraryToLibrary, diff.Edges[0].Type)
+	assert.Empty(diff.Edges[0].Added)
+	assert.Equal([]GraphDiffEdge{{From: "lib1", To: "lib2"}}, diff.Edges[0].Removed)
+	assert.Equal(depgraph.ImplicitLibraryToLibrary, diff.Edges[1].Type)
+	assert.Equal([]GraphDiffEdge{{From: "lib1", To: "lib3"}}, diff.Edges[1].Added)
+
+	require.Len(diff.Libraries, 1)
+	assert.Equal(GraphLibraryDiff{Library: "lib1", Added: []string{"lib3"}, Removed: []string{"lib2"}}, diff.Libraries[0])
+
+	same := diffGraphIndexes(from, from)
+	assert.Empty(same.AddedNodes)
+	assert.Empty(same.RemovedNodes)
+	assert.Empty(same.Edges)
+	assert.Empty(same.Libraries)
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+)
+
+// Graph export formats.
+const (
+	GraphExportDOT     = "dot"
+	GraphExportGraphML = "graphml"
+)
+
+// GraphExportFilter selects the part of a graph to export. Empty
+// fields select every node; a node must match every field that is set.
+// Only the edges between selected nodes are exported.
+type GraphExportFilter struct {
+	// Types selects nodes of any of the types.
+	Types []depgraph.NodeType
+
+	// Prefix selects nodes whose names start with the prefix.
+	Prefix string
+
+	// Node selects the node with the name or index, and the nodes
+	// within Depth edges of it, in either direction. Depth defaults
+	// to one.
+	Node  string
+	Depth int
+}
+
+// ParseNodeType returns the node type with the name, such as
+// "Library", or with the number.
+func ParseNodeType(name string) (depgraph.NodeType, error) {
+	for t := depgraph.Library; t <= depgraph.Artifact; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		t := depgraph.NodeType(n)
+		if t >= depgraph.Library && t <= depgraph.Artifact {
+			return t, nil
+		}
+	}
+
+	return 0, errors.Errorf("'%s' is not a valid node type", name)
+}
+
+// Export writes the part of the graph that the filter selects to the
+// writer in the format, which is either GraphExportDOT or
+// GraphExportGraphML.
+func (g *GraphMetadata) Export(w io.Writer, format string, filter GraphExportFilter) error {
+	if format != GraphExportDOT && format != GraphExportGraphML {
+		return errors.Errorf("'%s' is not a supported export format", format)
+	}
+
+	idx, err := g.index()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	nodes, err := idx.selectNodes(filter)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if format == GraphExportDOT {
+		return errors.WithStack(idx.writeDOT(w, g.BuildID, nodes))
+	}
+
+	return errors.WithStack(idx.writeGraphML(w, g.BuildID, nodes))
+}
+
+// selectNodes returns the indexes of the nodes that the filter
+// selects, in order.
+func (idx *graphIndex) selectNodes(filter GraphExportFilter) ([]int, error) {
+	var near map[int]int
+	if filter.Node != "" {
+		id, err := idx.find(filter.Node)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		depth := filter.Depth
+		if depth < 1 {
+			depth = 1
+		}
+
+		near = idx.walk(idx.out, []int{id}, depth, nil)
+		for node, d := range idx.walk(idx.in, []int{id}, depth, nil) {
+			near[node] = d
+		}
+		near[id] = 0
+	}
+
+	types := map[depgraph.NodeType]struct{}{}
+	for _, t := range filter.Types {
+		types[t] = struct{}{}
+	}
+
+	out := []int{}
+	for id, node := range idx.nodes {
+		if len(types) > 0 {
+			if _, ok := types[node.Relationships.Type]; !ok {
+				continue
+			}
+		}
+
+		if !strings.HasPrefix(node.Name, filter.Prefix) {
+			continue
+		}
+
+		if near != nil {
+			if _, ok := near[id]; !ok {
+				continue
+			}
+		}
+
+		out = append(out, id)
+	}
+	sort.Ints(out)
+
+	return out, nil
+}
+
+// exportEdges returns the edges between the nodes, in order.
+func (idx *graphIndex) exportEdges(nodes []int) [][2]graphLink {
+	selected := map[int]struct{}{}
+	for _, id := range nodes {
+		selected[id] = struct{}{}
+	}
+
+	out := [][2]graphLink{}
+	for _, from := range nodes {
+		for _, link := range idx.out[from] {
+			if _, ok := selected[link.Node]; ok {
+				out = append(out, [2]graphLink{{Node: from, Type: link.Type}, link})
+			}
+		}
+	}
+
+	return out
+}
+
+// dotQuote renders the string as a DOT quoted identifier.
+func dotQuote(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+func (idx *graphIndex) writeDOT(w io.Writer, name string, nodes []int) error {
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintf(buf, "digraph %s {\n", dotQuote(name))
+	for _, id := range nodes {
+		fmt.Fprintf(buf, "  n%d [label=%s, type=%s];\n", id,
+			dotQuote(idx.nodes[id].Name), dotQuote(idx.nodeType(id).String()))
+	}
+	for _, edge := range idx.exportEdges(nodes) {
+		fmt.Fprintf(buf, "  n%d -> n%d [label=%s];\n", edge[0].Node, edge[1].Node, dotQuote(edge[1].Type.String()))
+	}
+	fmt.Fprintln(buf, "}")
+
+	return errors.WithStack(buf.Flush())
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	Name     string `xml:"attr.name,attr"`
+	DataType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (idx *graphIndex) writeGraphML(w io.Writer, name string, nodes []int) error {
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", DataType: "string"},
+			{ID: "node_type", For: "node", Name: "type", DataType: "string"},
+			{ID: "edge_type", For: "edge", Name: "type", DataType: "string"},
+		},
+		Graph: graphMLGraph{
+			ID:          name,
+			EdgeDefault: "directed",
+			Nodes:       make([]graphMLNode, 0, len(nodes)),
+			Edges:       []graphMLEdge{},
+		},
+	}
+
+	for _, id := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: fmt.Sprintf("n%d", id),
+			Data: []graphMLData{
+				{Key: "name", Value: idx.nodes[id].Name},
+				{Key: "node_type", Value: idx.nodeType(id).String()},
+			},
+		})
+	}
+
+	for _, edge := range idx.exportEdges(nodes) {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: fmt.Sprintf("n%d", edge[0].Node),
+			Target: fmt.Sprintf("n%d", edge[1].Node),
+			Data:   []graphMLData{{Key: "edge_type", Value: edge[1].Type.String()}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrap(err, "problem encoding graphml")
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return errors.WithStack(err)
+}
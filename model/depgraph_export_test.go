@@ -0,0 +1,115 @@
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tychoish/depgraph"
+)
+
+func makeTestExportIndex() *graphIndex {
+	idx := makeTestGraphIndex(4, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+		{2, 3}: depgraph.LibraryToLibrary,
+	})
+
+	file := &depgraph.Node{Name: `src/"quoted".cpp`, GraphID: 4}
+	file.Relationships.Type = depgraph.File
+	idx.addNode(file)
+	idx.addEdge(&depgraph.Edge{
+		Type:     depgraph.FileToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 4, Name: file.Name},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 1, Name: "lib1"}},
+	})
+
+	return idx
+}
+
+func TestGraphIndexSelectNodes(t *testing.T) {
+	assert := assert.New(t)
+	idx := makeTestExportIndex()
+
+	nodes, err := idx.selectNodes(GraphExportFilter{})
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2, 3, 4}, nodes)
+
+	nodes, err = idx.selectNodes(GraphExportFilter{Types: []depgraph.NodeType{depgraph.File}})
+	assert.NoError(err)
+	assert.Equal([]int{4}, nodes)
+
+	nodes, err = idx.selectNodes(GraphExportFilter{Prefix: "lib"})
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2, 3}, nodes)
+
+	nodes, err = idx.selectNodes(GraphExportFilter{Node: "lib1"})
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2, 4}, nodes)
+
+	nodes, err = idx.selectNodes(GraphExportFilter{Node: "lib1", Depth: 2, Prefix: "lib"})
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2, 3}, nodes)
+
+	_, err = idx.selectNodes(GraphExportFilter{Node: "libfoo"})
+	assert.Error(err)
+}
+
+func TestGraphIndexWriteDOT(t *testing.T) {
+	assert := assert.New(t)
+	idx := makeTestExportIndex()
+
+	out := &bytes.Buffer{}
+	require.NoError(t, idx.writeDOT(out, "build", []int{1, 2, 4}))
+
+	assert.Equal(`digraph "build" {
+  n1 [label="lib1", type="Library"];
+  n2 [label="lib2", type="Library"];
+  n4 [label="src/\"quoted\".cpp", type="File"];
+  n1 -> n2 [label="LibraryToLibrary"];
+  n4 -> n1 [label="FileToLibrary"];
+}
+`, out.String())
+}
+
+func TestGraphIndexWriteGraphML(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	idx := makeTestExportIndex()
+
+	out := &bytes.Buffer{}
+	require.NoError(idx.writeGraphML(out, "build", []int{0, 1, 4}))
+
+	doc := graphMLDocument{}
+	require.NoError(xml.Unmarshal(out.Bytes(), &doc))
+	assert.Equal("build", doc.Graph.ID)
+	assert.Equal("directed", doc.Graph.EdgeDefault)
+	require.Len(doc.Graph.Nodes, 3)
+	assert.Equal("n4", doc.Graph.Nodes[2].ID)
+	assert.Equal(`src/"quoted".cpp`, doc.Graph.Nodes[2].Data[0].Value)
+	assert.Equal("File", doc.Graph.Nodes[2].Data[1].Value)
+
+	require.Len(doc.Graph.Edges, 2)
+	assert.Equal(graphMLEdge{Source: "n0", Target: "n1",
+		Data: []graphMLData{{Key: "edge_type", Value: "LibraryToLibrary"}}}, doc.Graph.Edges[0])
+	assert.Equal("n4", doc.Graph.Edges[1].Source)
+}
+
+func TestParseNodeType(t *testing.T) {
+	assert := assert.New(t)
+
+	nt, err := ParseNodeType("Artifact")
+	assert.NoError(err)
+	assert.Equal(depgraph.Artifact, nt)
+
+	nt, err = ParseNodeType("3")
+	assert.NoError(err)
+	assert.Equal(depgraph.File, nt)
+
+	for _, name := range []string{"", "0", "5", "library"} {
+		_, err = ParseNodeType(name)
+		assert.Error(err, name)
+	}
+}
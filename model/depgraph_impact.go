@@ -0,0 +1,99 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+)
+
+// GraphImpact describes the libraries and artifacts that a change to a
+// set of files affects. The depth of each library or artifact is the
+// number of edges between it and the nearest changed file.
+type GraphImpact struct {
+	Files     []string        `json:"files"`
+	Unknown   []string        `json:"unknown"`
+	Libraries []GraphRelative `json:"libraries"`
+	Artifacts []GraphRelative `json:"artifacts"`
+}
+
+// Impact returns the libraries and artifacts affected by changes to
+// the files, which are node names. Each file affects the libraries
+// that its FileToLibrary edges lead to, which in turn affect the
+// libraries that depend on them over LibraryToLibrary and
+// ImplicitLibraryToLibrary edges and the artifacts that link them over
+// ArtifactToLibrary edges. Files that
+// the graph does not have are reported as unknown.
+func (g *GraphMetadata) Impact(files []string) (*GraphImpact, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return idx.impact(files), nil
+}
+
+func (idx *graphIndex) impact(files []string) *GraphImpact {
+	out := &GraphImpact{
+		Files:     []string{},
+		Unknown:   []string{},
+		Libraries: []GraphRelative{},
+		Artifacts: []GraphRelative{},
+	}
+
+	seen := map[string]struct{}{}
+	start := []int{}
+	for _, name := range files {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		id, ok := idx.byName[name]
+		if !ok || idx.nodeType(id) != depgraph.File {
+			out.Unknown = append(out.Unknown, name)
+			continue
+		}
+
+		out.Files = append(out.Files, name)
+		start = append(start, id)
+	}
+	sort.Strings(out.Files)
+	sort.Strings(out.Unknown)
+
+	// the libraries that the files are built into.
+	direct := idx.walk(idx.out, start, 1, []depgraph.EdgeType{depgraph.FileToLibrary})
+	libs := make([]int, 0, len(direct))
+	for id := range direct {
+		libs = append(libs, id)
+	}
+	sort.Ints(libs)
+
+	// the libraries that depend on those libraries, at depths
+	// counted from the changed files.
+	libraries := idx.walk(idx.in, libs, 0, libraryEdges)
+	for id, depth := range libraries {
+		libraries[id] = depth + 1
+	}
+	for id := range direct {
+		libraries[id] = 1
+	}
+
+	artifacts := map[int]int{}
+	for lib, depth := range libraries {
+		for _, link := range idx.in[lib] {
+			if link.Type != depgraph.ArtifactToLibrary {
+				continue
+			}
+
+			if current, ok := artifacts[link.Node]; !ok || depth+1 < current {
+				artifacts[link.Node] = depth + 1
+			}
+		}
+	}
+
+	out.Libraries = idx.relatives(libraries)
+	out.Artifacts = idx.relatives(artifacts)
+
+	return out
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tychoish/depgraph"
+)
+
+func TestGraphIndexImpact(t *testing.T) {
+	assert := assert.New(t)
+
+	// lib1 depends on lib0, and lib2 on lib1; lib3 is unrelated.
+	idx := makeTestGraphIndex(4, map[[2]int]depgraph.EdgeType{
+		{1, 0}: depgraph.LibraryToLibrary,
+		{2, 1}: depgraph.LibraryToLibrary,
+	})
+
+	add := func(id int, name string, nt depgraph.NodeType) {
+		node := &depgraph.Node{Name: name, GraphID: id}
+		node.Relationships.Type = nt
+		idx.addNode(node)
+	}
+	link := func(et depgraph.EdgeType, from, to int) {
+		idx.addEdge(&depgraph.Edge{
+			Type:     et,
+			FromNode: depgraph.NodeRelationship{GraphID: from, Name: idx.nodes[from].Name},
+			ToNodes:  []depgraph.NodeRelationship{{GraphID: to, Name: idx.nodes[to].Name}},
+		})
+	}
+
+	add(10, "a.cpp", depgraph.File)
+	add(11, "b.cpp", depgraph.File)
+	add(20, "mongod", depgraph.Artifact)
+	add(21, "mongos", depgraph.Artifact)
+	link(depgraph.FileToLibrary, 10, 0)
+	link(depgraph.FileToLibrary, 11, 3)
+	link(depgraph.ArtifactToLibrary, 20, 2)
+	link(depgraph.ArtifactToLibrary, 21, 3)
+
+	impact := idx.impact([]string{"a.cpp", "a.cpp", "missing.cpp", "lib0"})
+	assert.Equal([]string{"a.cpp"}, impact.Files)
+	assert.Equal([]string{"lib0", "missing.cpp"}, impact.Unknown)
+
+	assert.Equal([]GraphRelative{
+		{Node: depgraph.NodeRelationship{GraphID: 0, Name: "lib0"}, Type: depgraph.Library, Depth: 1},
+		{Node: depgraph.NodeRelationship{GraphID: 1, Name: "lib1"}, Type: depgraph.Library, Depth: 2},
+		{Node: depgraph.NodeRelationship{GraphID: 2, Name: "lib2"}, Type: depgraph.Library, Depth: 3},
+	}, impact.Libraries)
+	assert.Equal([]GraphRelative{
+		{Node: depgraph.NodeRelationship{GraphID: 20, Name: "mongod"}, Type: depgraph.Artifact, Depth: 4},
+	}, impact.Artifacts)
+
+	impact = idx.impact([]string{"b.cpp"})
+	assert.Len(impact.Libraries, 1)
+	assert.Equal("lib3", impact.Libraries[0].Node.Name)
+	assert.Len(impact.Artifacts, 1)
+	assert.Equal("mongos", impact.Artifacts[0].Node.Name)
+
+	impact = idx.impact(nil)
+	assert.Empty(impact.Libraries)
+	assert.Empty(impact.Artifacts)
+}
+
+func TestGraphIndexImpactFollowsImplicitDependencies(t *testing.T) {
+	assert := assert.New(t)
+
+	// lib1 depends on lib0 implicitly, and lib2 on lib1 directly.
+	idx := makeTestGraphIndex(3, map[[2]int]depgraph.EdgeType{
+		{1, 0}: depgraph.ImplicitLibraryToLibrary,
+		{2, 1}: depgraph.LibraryToLibrary,
+	})
+
+	file := &depgraph.Node{Name: "a.cpp", GraphID: 10}
+	file.Relationships.Type = depgraph.File
+	idx.addNode(file)
+	idx.addEdge(&depgraph.Edge{
+		Type:     depgraph.FileToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 10, Name: "a.cpp"},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 0, Name: "lib0"}},
+	})
+
+	impact := idx.impact([]string{"a.cpp"})
+	assert.Equal([]GraphRelative{
+		{Node: depgraph.NodeRelationship{GraphID: 0, Name: "lib0"}, Type: depgraph.Library, Depth: 1},
+		{Node: depgraph.NodeRelationship{GraphID: 1, Name: "lib1"}, Type: depgraph.Library, Depth: 2},
+		{Node: depgraph.NodeRelationship{GraphID: 2, Name: "lib2"}, Type: depgraph.Library, Depth: 3},
+	}, impact.Libraries)
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Graph import states. A graph that has never been imported has no
+// import state.
+const (
+	GraphImportQueued   = "queued"
+	GraphImportRunning  = "running"
+	GraphImportComplete = "complete"
+	GraphImportFailed   = "failed"
+)
+
+// graphImportExpiration is how long after an import is queued that
+// another import may replace it, in case the import's job was lost
+// or stopped without recording its result.
+const graphImportExpiration = 6 * time.Hour
+
+// GraphImport tracks the progress of loading a graph's nodes and edges
+// from a file or URL.
+type GraphImport struct {
+	State       string    `bson:"state,omitempty" json:"state,omitempty"`
+	Source      string    `bson:"source,omitempty" json:"source,omitempty"`
+	JobID       string    `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+	NodesTotal  int       `bson:"nodes_total" json:"nodes_total"`
+	NodesStored int       `bson:"nodes_stored" json:"nodes_stored"`
+	EdgesTotal  int       `bson:"edges_total" json:"edges_total"`
+	EdgesStored int       `bson:"edges_stored" json:"edges_stored"`
+	Queued      time.Time `bson:"queued,omitempty" json:"queued,omitempty"`
+	Started     time.Time `bson:"started,omitempty" json:"started,omitempty"`
+	Completed   time.Time `bson:"completed,omitempty" json:"completed,omitempty"`
+}
+
+var (
+	graphImportStateKey       = bsonutil.MustHaveTag(GraphImport{}, "State")
+	graphImportJobIDKey       = bsonutil.MustHaveTag(GraphImport{}, "JobID")
+	graphImportErrorKey       = bsonutil.MustHaveTag(GraphImport{}, "Error")
+	graphImportNodesTotalKey  = bsonutil.MustHaveTag(GraphImport{}, "NodesTotal")
+	graphImportNodesStoredKey = bsonutil.MustHaveTag(GraphImport{}, "NodesStored")
+	graphImportEdgesTotalKey  = bsonutil.MustHaveTag(GraphImport{}, "EdgesTotal")
+	graphImportEdgesStoredKey = bsonutil.MustHaveTag(GraphImport{}, "EdgesStored")
+	graphImportQueuedKey      = bsonutil.MustHaveTag(GraphImport{}, "Queued")
+	graphImportStartedKey     = bsonutil.MustHaveTag(GraphImport{}, "Started")
+	graphImportCompletedKey   = bsonutil.MustHaveTag(GraphImport{}, "Completed")
+)
+
+// IsImporting reports whether an import of the graph is queued or
+// running.
+func (i *GraphImport) IsImporting() bool {
+	return i.State == GraphImportQueued || i.State == GraphImportRunning
+}
+
+func graphImportKey(key string) string {
+	return bsonutil.GetDottedKeyName(graphMetadataImportKey, key)
+}
+
+// QueueImport records that the job will import the graph from the
+// source. It is an error to queue an import while another import of
+// the graph is queued or running, unless that import was queued long
+// enough ago to have expired. The job of an import that another
+// import replaces can no longer update the graph's import state.
+func (g *GraphMetadata) QueueImport(source, jobID string) error {
+	imp := GraphImport{
+		State:  GraphImportQueued,
+		Source: source,
+		JobID:  jobID,
+		Queued: time.Now(),
+	}
+
+	query := db.Query(bson.M{
+		graphMetadataIDKey: g.BuildID,
+		"$or": []bson.M{
+			{graphImportKey(graphImportStateKey): bson.M{
+				"$nin": []string{GraphImportQueued, GraphImportRunning},
+			}},
+			{graphImportKey(graphImportQueuedKey): bson.M{
+				"$lt": imp.Queued.Add(-graphImportExpiration),
+			}},
+		},
+	})
+
+	err := query.Update(depMetadataCollection, bson.M{"$set": bson.M{graphMetadataImportKey: imp}})
+	if errors.Cause(err) == mgo.ErrNotFound {
+		return errors.Errorf("graph '%s' does not exist or is already importing", g.BuildID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "problem queuing import of graph '%s'", g.BuildID)
+	}
+
+	g.Import = imp
+	return nil
+}
+
+// StartImport records that the import has started and how many nodes
+// and edges it will store.
+func (g *GraphMetadata) StartImport(nodes, edges int) error {
+	g.Import.State = GraphImportRunning
+	g.Import.Started = time.Now()
+	g.Import.NodesTotal = nodes
+	g.Import.EdgesTotal = edges
+
+	return errors.WithStack(g.updateImport(bson.M{
+		graphImportKey(graphImportStateKey):      g.Import.State,
+		graphImportKey(graphImportStartedKey):    g.Import.Started,
+		graphImportKey(graphImportNodesTotalKey): nodes,
+		graphImportKey(graphImportEdgesTotalKey): edges,
+	}))
+}
+
+// SetImportProgress records the number of nodes and edges that the
+// import has stored.
+func (g *GraphMetadata) SetImportProgress(nodes, edges int) error {
+	g.Import.NodesStored = nodes
+	g.Import.EdgesStored = edges
+
+	return errors.WithStack(g.updateImport(bson.M{
+		graphImportKey(graphImportNodesStoredKey): nodes,
+		graphImportKey(graphImportEdgesStoredKey): edges,
+	}))
+}
+
+// FinishImport records that the import is complete, or that it failed
+// if the error is not nil.
+func (g *GraphMetadata) FinishImport(err error) error {
+	g.Import.State = GraphImportComplete
+	g.Import.Error = ""
+	if err != nil {
+		g.Import.State = GraphImportFailed
+		g.Import.Error = err.Error()
+	}
+	g.Import.Completed = time.Now()
+
+	return errors.WithStack(g.updateImport(bson.M{
+		graphImportKey(graphImportStateKey):     g.Import.State,
+		graphImportKey(graphImportErrorKey):     g.Import.Error,
+		graphImportKey(graphImportCompletedKey): g.Import.Completed,
+	}))
+}
+
+// updateImport sets the import fields, as long as the graph's import
+// is still the one that this graph's job is running.
+func (g *GraphMetadata) updateImport(set bson.M) error {
+	query := db.Query(bson.M{
+		graphMetadataIDKey:                  g.BuildID,
+		graphImportKey(graphImportJobIDKey): g.Import.JobID,
+	})
+
+	err := query.Update(depMetadataCollection, bson.M{"$set": set})
+	if errors.Cause(err) == mgo.ErrNotFound {
+		return errors.Errorf("import '%s' of graph '%s' is no longer current", g.Import.JobID, g.BuildID)
+	}
+
+	return errors.Wrapf(err, "problem updating import of graph '%s'", g.BuildID)
+}
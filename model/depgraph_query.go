@@ -0,0 +1,404 @@
+package model
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+	"gopkg.in/mgo.v2/bson"
+)
+
+////////////////////////////////////////////////////////////////////////
+//
+// In-Memory Graph Index
+
+// graphLink is one end of an edge in a graph index.
+type graphLink struct {
+	Node int
+	Type depgraph.EdgeType
+}
+
+// graphIndex holds a graph's nodes and edges in memory, for queries
+// that walk the graph. Edges run from a node to its dependencies; out
+// holds each node's outgoing edges, and in holds its incoming edges.
+type graphIndex struct {
+	revision int
+	nodes    map[int]*depgraph.Node
+	byName   map[string]int
+	out      map[int][]graphLink
+	in       map[int][]graphLink
+}
+
+// graphCacheSize is the number of graph indexes kept in memory.
+const graphCacheSize = 8
+
+// graphCache keeps the indexes of the most recently queried graphs.
+type graphCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type graphCacheEntry struct {
+	id    string
+	index *graphIndex
+}
+
+var depGraphCache = &graphCache{
+	order:   list.New(),
+	entries: map[string]*list.Element{},
+}
+
+func (c *graphCache) get(id string, revision int) *graphIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+
+	entry := elem.Value.(*graphCacheEntry)
+	if entry.index.revision != revision {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.index
+}
+
+func (c *graphCache) put(id string, index *graphIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[id] = c.order.PushFront(&graphCacheEntry{id: id, index: index})
+
+	for c.order.Len() > graphCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*graphCacheEntry).id)
+	}
+}
+
+// index returns the graph's in-memory index, loading it if the cache
+// does not have the graph's current revision.
+func (g *GraphMetadata) index() (*graphIndex, error) {
+	if idx := depGraphCache.get(g.BuildID, g.Revision); idx != nil {
+		return idx, nil
+	}
+
+	idx := newGraphIndex(g.Revision)
+
+	nodes := []*GraphNode{}
+	err := db.Query(bson.M{graphNodeGraphNameKey: g.BuildID}).
+		Project(bson.M{
+			graphNodeNameKey:    1,
+			graphNodeGraphIDKey: 1,
+			bsonutil.GetDottedKeyName(graphNodeRelationshipsKey, graphNodeRelationshipsTypeKey): 1,
+		}).
+		FindAll(depNodeCollection, &nodes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem loading nodes of graph '%s'", g.BuildID)
+	}
+	for _, n := range nodes {
+		idx.addNode(&n.Node)
+	}
+
+	edges := []*GraphEdge{}
+	err = db.Query(bson.M{graphEdgeGraphKey: g.BuildID}).
+		Project(bson.M{graphEdgeTypeKey: 1, graphEdgeFromNodeKey: 1, graphEdgeToNodeKey: 1}).
+		FindAll(depEdgeCollection, &edges)
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem loading edges of graph '%s'", g.BuildID)
+	}
+	for _, e := range edges {
+		idx.addEdge(&e.Edge)
+	}
+
+	depGraphCache.put(g.BuildID, idx)
+
+	return idx, nil
+}
+
+func newGraphIndex(revision int) *graphIndex {
+	return &graphIndex{
+		revision: revision,
+		nodes:    map[int]*depgraph.Node{},
+		byName:   map[string]int{},
+		out:      map[int][]graphLink{},
+		in:       map[int][]graphLink{},
+	}
+}
+
+func (idx *graphIndex) addNode(n *depgraph.Node) {
+	idx.nodes[n.GraphID] = n
+	idx.byName[n.Name] = n.GraphID
+}
+
+func (idx *graphIndex) addEdge(e *depgraph.Edge) {
+	from := e.FromNode.GraphID
+	for _, to := range e.ToNodes {
+		idx.out[from] = append(idx.out[from], graphLink{Node: to.GraphID, Type: e.Type})
+		idx.in[to.GraphID] = append(idx.in[to.GraphID], graphLink{Node: from, Type: e.Type})
+	}
+}
+
+// find returns the index of the node with the name, or with the index
+// if the name is a number and no node has that name.
+func (idx *graphIndex) find(name string) (int, error) {
+	if id, ok := idx.byName[name]; ok {
+		return id, nil
+	}
+
+	if id, err := strconv.Atoi(name); err == nil {
+		if _, ok := idx.nodes[id]; ok {
+			return id, nil
+		}
+	}
+
+	return 0, errors.Errorf("graph does not have node '%s'", name)
+}
+
+func (idx *graphIndex) relationship(id int) depgraph.NodeRelationship {
+	out := depgraph.NodeRelationship{GraphID: id}
+	if n, ok := idx.nodes[id]; ok {
+		out.Name = n.Name
+	}
+
+	return out
+}
+
+func (idx *graphIndex) nodeType(id int) depgraph.NodeType {
+	if n, ok := idx.nodes[id]; ok {
+		return n.Relationships.Type
+	}
+
+	return 0
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Transitive Queries
+
+// GraphRelative is a node reached by walking a graph from another node.
+// Depth is the number of edges between them.
+type GraphRelative struct {
+	Node  depgraph.NodeRelationship `json:"node"`
+	Type  depgraph.NodeType         `json:"type"`
+	Depth int                       `json:"depth"`
+}
+
+// GraphPathStep is one node on a path through a graph, and the type of
+// the edge that reached it, which is zero for the first node.
+type GraphPathStep struct {
+	Node depgraph.NodeRelationship `json:"node"`
+	Edge depgraph.EdgeType         `json:"edge,omitempty"`
+}
+
+// ParseEdgeType returns the edge type with the name, such as
+// "LibraryToLibrary", or with the number.
+func ParseEdgeType(name string) (depgraph.EdgeType, error) {
+	for t := depgraph.LibraryToLibrary; t <= depgraph.ArtifactToLibrary; t++ {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		t := depgraph.EdgeType(n)
+		if t >= depgraph.LibraryToLibrary && t <= depgraph.ArtifactToLibrary {
+			return t, nil
+		}
+	}
+
+	return 0, errors.Errorf("'%s' is not a valid edge type", name)
+}
+
+// edgeTypeFilter returns a function that reports whether to follow
+// edges of a type. Without types, all edges are followed.
+func edgeTypeFilter(types []depgraph.EdgeType) func(depgraph.EdgeType) bool {
+	if len(types) == 0 {
+		return func(depgraph.EdgeType) bool { return true }
+	}
+
+	allowed := map[depgraph.EdgeType]struct{}{}
+	for _, t := range types {
+		allowed[t] = struct{}{}
+	}
+
+	return func(t depgraph.EdgeType) bool {
+		_, ok := allowed[t]
+		return ok
+	}
+}
+
+// walk returns the nodes reachable from the start nodes over links of
+// the types, and the depth at which it first reached each one. A
+// depth limit less than one does not limit the walk.
+func (idx *graphIndex) walk(links map[int][]graphLink, start []int, limit int, types []depgraph.EdgeType) map[int]int {
+	follow := edgeTypeFilter(types)
+	depths := map[int]int{}
+	for _, id := range start {
+		depths[id] = 0
+	}
+
+	queue := append([]int{}, start...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if limit > 0 && depths[id] >= limit {
+			continue
+		}
+
+		for _, link := range links[id] {
+			if !follow(link.Type) {
+				continue
+			}
+			if _, ok := depths[link.Node]; ok {
+				continue
+			}
+
+			depths[link.Node] = depths[id] + 1
+			queue = append(queue, link.Node)
+		}
+	}
+
+	for _, id := range start {
+		delete(depths, id)
+	}
+
+	return depths
+}
+
+func (idx *graphIndex) relatives(depths map[int]int) []GraphRelative {
+	out := make([]GraphRelative, 0, len(depths))
+	for id, depth := range depths {
+		out = append(out, GraphRelative{
+			Node:  idx.relationship(id),
+			Type:  idx.nodeType(id),
+			Depth: depth,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Depth != out[j].Depth {
+			return out[i].Depth < out[j].Depth
+		}
+		return out[i].Node.Name < out[j].Node.Name
+	})
+
+	return out
+}
+
+// Dependencies returns the nodes that the node depends on, directly or
+// transitively, following the edges of the types, or all edges if
+// there are no types. A depth less than one does not limit the search.
+func (g *GraphMetadata) Dependencies(node string, depth int, types []depgraph.EdgeType) ([]GraphRelative, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	id, err := idx.find(node)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return idx.relatives(idx.walk(idx.out, []int{id}, depth, types)), nil
+}
+
+// Dependents returns the nodes that depend on the node, directly or
+// transitively, as in Dependencies.
+func (g *GraphMetadata) Dependents(node string, depth int, types []depgraph.EdgeType) ([]GraphRelative, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	id, err := idx.find(node)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return idx.relatives(idx.walk(idx.in, []int{id}, depth, types)), nil
+}
+
+// ShortestPath returns the shortest chain of dependencies from one node
+// to the other, following the edges of the types, or all edges if
+// there are no types. The path is empty if there is no such chain.
+func (g *GraphMetadata) ShortestPath(from, to string, types []depgraph.EdgeType) ([]GraphPathStep, error) {
+	idx, err := g.index()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	start, err := idx.find(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	end, err := idx.find(to)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return idx.shortestPath(start, end, types), nil
+}
+
+func (idx *graphIndex) shortestPath(start, end int, types []depgraph.EdgeType) []GraphPathStep {
+	if start == end {
+		return []GraphPathStep{{Node: idx.relationship(start)}}
+	}
+
+	follow := edgeTypeFilter(types)
+	// prev maps each node reached to the link that reached it.
+	prev := map[int]graphLink{start: {Node: start}}
+
+	queue := []int{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, link := range idx.out[id] {
+			if !follow(link.Type) {
+				continue
+			}
+			if _, ok := prev[link.Node]; ok {
+				continue
+			}
+
+			prev[link.Node] = graphLink{Node: id, Type: link.Type}
+			if link.Node == end {
+				return idx.tracePath(prev, start, end)
+			}
+			queue = append(queue, link.Node)
+		}
+	}
+
+	return []GraphPathStep{}
+}
+
+func (idx *graphIndex) tracePath(prev map[int]graphLink, start, end int) []GraphPathStep {
+	path := []GraphPathStep{}
+	for id := end; id != start; id = prev[id].Node {
+		path = append(path, GraphPathStep{Node: idx.relationship(id), Edge: prev[id].Type})
+	}
+	path = append(path, GraphPathStep{Node: idx.relationship(start)})
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"container/list"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tychoish/depgraph"
+)
+
+// makeTestGraphIndex builds an index of libraries 0 through n-1, with
+// an edge of the type between each pair.
+func makeTestGraphIndex(n int, edges map[[2]int]depgraph.EdgeType) *graphIndex {
+	idx := newGraphIndex(0)
+	for i := 0; i < n; i++ {
+		node := &depgraph.Node{Name: fmt.Sprintf("lib%d", i), GraphID: i}
+		node.Relationships.Type = depgraph.Library
+		idx.addNode(node)
+	}
+
+	for pair, t := range edges {
+		idx.addEdge(&depgraph.Edge{
+			Type:     t,
+			FromNode: depgraph.NodeRelationship{GraphID: pair[0], Name: fmt.Sprintf("lib%d", pair[0])},
+			ToNodes:  []depgraph.NodeRelationship{{GraphID: pair[1], Name: fmt.Sprintf("lib%d", pair[1])}},
+		})
+	}
+
+	return idx
+}
+
+func TestGraphIndexWalk(t *testing.T) {
+	assert := assert.New(t)
+
+	// 0 -> 1 -> 2 -> 3, and 0 -> 3 implicitly.
+	idx := makeTestGraphIndex(5, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+		{2, 3}: depgraph.LibraryToLibrary,
+		{0, 3}: depgraph.ImplicitLibraryToLibrary,
+	})
+
+	assert.Equal(map[int]int{1: 1, 2: 2, 3: 1}, idx.walk(idx.out, []int{0}, 0, nil))
+	assert.Equal(map[int]int{1: 1, 3: 1}, idx.walk(idx.out, []int{0}, 1, nil))
+	assert.Equal(map[int]int{1: 1, 2: 2, 3: 3},
+		idx.walk(idx.out, []int{0}, 0, []depgraph.EdgeType{depgraph.LibraryToLibrary}))
+	assert.Equal(map[int]int{0: 1, 1: 2, 2: 1}, idx.walk(idx.in, []int{3}, 0, nil))
+	assert.Empty(idx.walk(idx.out, []int{4}, 0, nil))
+
+	relatives := idx.relatives(idx.walk(idx.out, []int{0}, 0, nil))
+	require.Len(t, relatives, 3)
+	assert.Equal("lib1", relatives[0].Node.Name)
+	assert.Equal("lib3", relatives[1].Node.Name)
+	assert.Equal("lib2", relatives[2].Node.Name)
+	assert.Equal(2, relatives[2].Depth)
+	assert.Equal(depgraph.Library, relatives[2].Type)
+}
+
+func TestGraphIndexShortestPath(t *testing.T) {
+	assert := assert.New(t)
+
+	idx := makeTestGraphIndex(5, map[[2]int]depgraph.EdgeType{
+		{0, 1}: depgraph.LibraryToLibrary,
+		{1, 2}: depgraph.LibraryToLibrary,
+		{2, 3}: depgraph.LibraryToLibrary,
+		{0, 3}: depgraph.ImplicitLibraryToLibrary,
+	})
+
+	path := idx.shortestPath(0, 3, nil)
+	assert.Equal([]GraphPathStep{
+		{Node: depgraph.NodeRelationship{GraphID: 0, Name: "lib0"}},
+		{Node: depgraph.NodeRelationship{GraphID: 3, Name: "lib3"}, Edge: depgraph.ImplicitLibraryToLibrary},
+	}, path)
+
+	path = idx.shortestPath(0, 3, []depgraph.EdgeType{depgraph.LibraryToLibrary})
+	require.Len(t, path, 4)
+	assert.Equal("lib1", path[1].Node.Name)
+	assert.Equal(depgraph.LibraryToLibrary, path[3].Edge)
+
+	assert.Len(idx.shortestPath(2, 2, nil), 1)
+	assert.Empty(idx.shortestPath(3, 0, nil))
+	assert.Empty(idx.shortestPath(0, 4, nil))
+}
+
+func TestGraphIndexFind(t *testing.T) {
+	assert := assert.New(t)
+	idx := makeTestGraphIndex(3, nil)
+
+	id, err := idx.find("lib2")
+	assert.NoError(err)
+	assert.Equal(2, id)
+
+	id, err = idx.find("1")
+	assert.NoError(err)
+	assert.Equal(1, id)
+
+	_, err = idx.find("7")
+	assert.Error(err)
+	_, err = idx.find("libfoo")
+	assert.Error(err)
+}
+
+func TestGraphCache(t *testing.T) {
+	assert := assert.New(t)
+	cache := &graphCache{order: list.New(), entries: map[string]*list.Element{}}
+
+	cache.put("a", newGraphIndex(1))
+	assert.NotNil(cache.get("a", 1))
+	assert.Nil(cache.get("a", 2), "stale revisions are dropped")
+	assert.Nil(cache.get("a", 1))
+
+	for i := 0; i <= graphCacheSize; i++ {
+		cache.put(fmt.Sprint(i), newGraphIndex(0))
+	}
+	assert.Nil(cache.get("0", 0), "least recently used graph is evicted")
+	assert.NotNil(cache.get(fmt.Sprint(graphCacheSize), 0))
+	assert.Equal(graphCacheSize, cache.order.Len())
+}
+
+func TestParseEdgeType(t *testing.T) {
+	assert := assert.New(t)
+
+	et, err := ParseEdgeType("LibraryToLibrary")
+	assert.NoError(err)
+	assert.Equal(depgraph.LibraryToLibrary, et)
+
+	et, err = ParseEdgeType("8")
+	assert.NoError(err)
+	assert.Equal(depgraph.ArtifactToLibrary, et)
+
+	for _, name := range []string{"", "0", "9", "librarytolibrary"} {
+		_, err = ParseEdgeType(name)
+		assert.Error(err, name)
+	}
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tychoish/depgraph"
+)
+
+func TestGraphNodeValidate(t *testing.T) {
+	assert := assert.New(t)
+	g := &GraphMetadata{BuildID: "build"}
+
+	node := g.MakeNode(&depgraph.Node{Name: "libfoo.a", GraphID: 2})
+	node.Relationships.Type = depgraph.Library
+	assert.NoError(node.Validate())
+	assert.Equal("build.2", node.ID)
+	assert.Equal("build", node.GraphName)
+
+	node.Relationships.Type = 0
+	assert.Error(node.Validate())
+	node.Relationships.Type = depgraph.Artifact + 1
+	assert.Error(node.Validate())
+	node.Relationships.Type = depgraph.File
+
+	node.GraphID = -1
+	assert.Error(node.Validate())
+	node.GraphID = 2
+
+	node.Name = ""
+	assert.Error(node.Validate())
+}
+
+func TestGraphEdgeValidate(t *testing.T) {
+	assert := assert.New(t)
+	g := &GraphMetadata{BuildID: "build"}
+
+	edge := g.MakeEdge(&depgraph.Edge{
+		Type:     depgraph.LibraryToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 1, Name: "liba.a"},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 2, Name: "libb.a"}},
+	})
+	assert.NoError(edge.Validate())
+	assert.Equal("build", edge.Graph)
+
+	edge.Type = 0
+	assert.Error(edge.Validate())
+	edge.Type = depgraph.ArtifactToLibrary + 1
+	assert.Error(edge.Validate())
+	edge.Type = depgraph.ArtifactToLibrary
+	assert.NoError(edge.Validate())
+
+	edge.ToNodes = append(edge.ToNodes, depgraph.NodeRelationship{GraphID: 3})
+	assert.Error(edge.Validate())
+	edge.ToNodes = nil
+	assert.Error(edge.Validate())
+	edge.ToNodes = []depgraph.NodeRelationship{{GraphID: 2, Name: "libb.a"}}
+
+	edge.FromNode.Name = ""
+	assert.Error(edge.Validate())
+}
+
+func TestGraphAddRejectsInvalidInput(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	g := &GraphMetadata{BuildID: "build"}
+
+	// these fail validation before reaching the database.
+	num, err := g.AddNodes([]*depgraph.Node{nil})
+	assert.Error(err)
+	assert.Equal(0, num)
+
+	a := &depgraph.Node{Name: "a", GraphID: 1}
+	a.Relationships.Type = depgraph.Library
+	b := &depgraph.Node{Name: "b", GraphID: 1}
+	b.Relationships.Type = depgraph.Library
+	num, err = g.AddNodes([]*depgraph.Node{a, b})
+	require.Error(err)
+	assert.Contains(err.Error(), "duplicates")
+	assert.Equal(0, num)
+
+	edge := &depgraph.Edge{
+		Type:     depgraph.LibraryToLibrary,
+		FromNode: depgraph.NodeRelationship{GraphID: 1, Name: "a"},
+		ToNodes:  []depgraph.NodeRelationship{{GraphID: 2, Name: "b"}},
+	}
+	num, err = g.AddEdges([]*depgraph.Edge{edge, edge})
+	require.Error(err)
+	assert.Contains(err.Error(), "duplicates")
+	assert.Equal(0, num)
+
+	num, err = g.AddEdges([]*depgraph.Edge{{FromNode: edge.FromNode, ToNodes: edge.ToNodes}})
+	assert.Error(err)
+	assert.Equal(0, num)
+}
+
+func TestGraphImportIsImporting(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False((&GraphImport{}).IsImporting())
+	assert.True((&GraphImport{State: GraphImportQueued}).IsImporting())
+	assert.True((&GraphImport{State: GraphImportRunning}).IsImporting())
+	assert.False((&GraphImport{State: GraphImportComplete}).IsImporting())
+	assert.False((&GraphImport{State: GraphImportFailed}).IsImporting())
+}
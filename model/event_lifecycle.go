@@ -0,0 +1,307 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Event lifecycle states. Events begin open; a reopened event is open
+// again after having been acknowledged, snoozed, or resolved.
+const (
+	EventOpen         = "open"
+	EventAcknowledged = "acknowledged"
+	EventSnoozed      = "snoozed"
+	EventResolved     = "resolved"
+	EventReopened     = "reopened"
+)
+
+// EventAssign is the transition that assigns an event to a user, or
+// clears its assignee, without changing its state. Its history
+// entries record the assignee.
+const EventAssign = "assign"
+
+// eventSystemUser records transitions that sink makes on its own, as
+// when an event recurs or its snooze expires.
+const eventSystemUser = "sink"
+
+// eventTransitions maps each state to the states that events may move
+// to from it.
+var eventTransitions = map[string][]string{
+	EventOpen:         {EventAcknowledged, EventSnoozed, EventResolved},
+	EventReopened:     {EventAcknowledged, EventSnoozed, EventResolved},
+	EventAcknowledged: {EventSnoozed, EventResolved, EventReopened},
+	EventSnoozed:      {EventAcknowledged, EventSnoozed, EventResolved, EventReopened},
+	EventResolved:     {EventReopened},
+}
+
+// EventTransition records one change to an event's state or assignee.
+type EventTransition struct {
+	From     string    `bson:"from" json:"from"`
+	To       string    `bson:"to" json:"to"`
+	User     string    `bson:"user" json:"user"`
+	Assignee string    `bson:"assignee,omitempty" json:"assignee,omitempty"`
+	Note     string    `bson:"note,omitempty" json:"note,omitempty"`
+	Until    time.Time `bson:"until,omitempty" json:"until,omitempty"`
+	Time     time.Time `bson:"ts" json:"time"`
+}
+
+var (
+	eventStateKey        = bsonutil.MustHaveTag(Event{}, "State")
+	eventSnoozedUntilKey = bsonutil.MustHaveTag(Event{}, "SnoozedUntil")
+	eventAssigneeKey     = bsonutil.MustHaveTag(Event{}, "Assignee")
+	eventHistoryKey      = bsonutil.MustHaveTag(Event{}, "History")
+)
+
+// isAcknowledged reports whether events in the state count as
+// acknowledged, which keeps the acknowledged flag consistent with the
+// state for filtering and notifications.
+func isAcknowledged(state string) bool {
+	return state == EventAcknowledged || state == EventSnoozed || state == EventResolved
+}
+
+// CurrentState returns the event's lifecycle state. Events stored
+// before states existed only have the acknowledged flag.
+func (e *Event) CurrentState() string {
+	if e.State != "" {
+		return e.State
+	}
+
+	if e.Acknowledged {
+		return EventAcknowledged
+	}
+
+	return EventOpen
+}
+
+// Validate returns an error if the transition is not valid from the
+// specified state.
+func (t *EventTransition) Validate(from string) error {
+	if t.User == "" {
+		return errors.New("must specify the user making the transition")
+	}
+
+	if t.To == EventAssign {
+		if !t.Until.IsZero() {
+			return errors.New("only snoozed events have an end time")
+		}
+
+		return nil
+	}
+
+	if t.Assignee != "" {
+		return errors.New("only assign transitions have an assignee")
+	}
+
+	if _, ok := eventTransitions[t.To]; !ok {
+		return errors.Errorf("'%s' is not a valid event state", t.To)
+	}
+
+	valid := false
+	for _, state := range eventTransitions[from] {
+		if state == t.To {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.Errorf("cannot move event from '%s' to '%s'", from, t.To)
+	}
+
+	if t.To == EventSnoozed {
+		if t.Until.IsZero() {
+			return errors.New("must specify when the snooze ends")
+		}
+		if !t.Until.After(t.Time) {
+			return errors.New("snooze must end in the future")
+		}
+	} else if !t.Until.IsZero() {
+		return errors.New("only snoozed events have an end time")
+	}
+
+	return nil
+}
+
+// Transition moves the event to the specified state, recording the
+// user and note in the event's history. Snoozed events reopen when
+// the until time passes.
+func (e *Event) Transition(state, user, note string, until time.Time) error {
+	t := EventTransition{
+		From:  e.CurrentState(),
+		To:    state,
+		User:  user,
+		Note:  note,
+		Until: until,
+		Time:  time.Now(),
+	}
+
+	if err := t.Validate(t.From); err != nil {
+		return errors.WithStack(err)
+	}
+
+	num, err := transitionEvents(bson.M{eventIDKey: e.ID}, []string{t.From}, t)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if num == 0 {
+		return errors.Errorf("event '%s' is no longer '%s'", e.ID.Hex(), t.From)
+	}
+
+	e.State = t.To
+	e.Acknowledged = isAcknowledged(t.To)
+	e.SnoozedUntil = t.Until
+	if t.To == EventReopened {
+		e.Opened = t.Time
+	}
+	e.History = append(e.History, t)
+
+	return nil
+}
+
+// Assign assigns the event to the assignee, or, if the assignee is
+// empty, clears the event's assignee, recording the user and note in
+// the event's history. The event's state does not change.
+func (e *Event) Assign(assignee, user, note string) error {
+	t := EventTransition{
+		From:     e.CurrentState(),
+		To:       EventAssign,
+		User:     user,
+		Assignee: assignee,
+		Note:     note,
+		Time:     time.Now(),
+	}
+
+	if err := t.Validate(t.From); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := db.Query(bson.M{eventIDKey: e.ID}).Update(eventCollection, assignUpdate(t)); err != nil {
+		return errors.Wrapf(err, "problem assigning event '%s'", e.ID.Hex())
+	}
+
+	e.Assignee = t.Assignee
+	e.History = append(e.History, t)
+
+	return nil
+}
+
+// assignUpdate returns the update that applies the assign transition.
+func assignUpdate(t EventTransition) bson.M {
+	update := bson.M{"$push": bson.M{eventHistoryKey: t}}
+	if t.Assignee == "" {
+		update["$unset"] = bson.M{eventAssigneeKey: ""}
+	} else {
+		update["$set"] = bson.M{eventAssigneeKey: t.Assignee}
+	}
+
+	return update
+}
+
+// Acknowledge moves the event to the acknowledged state.
+func (e *Event) Acknowledge(user, note string) error {
+	return errors.WithStack(e.Transition(EventAcknowledged, user, note, time.Time{}))
+}
+
+// stateQuery selects events in the state, including events stored
+// before states existed, whose state follows from the acknowledged
+// flag.
+func stateQuery(state string) bson.M {
+	switch state {
+	case EventOpen:
+		return bson.M{eventStateKey: bson.M{"$in": []interface{}{EventOpen, nil}}, eventAcknowledgedKey: false}
+	case EventAcknowledged:
+		return bson.M{eventStateKey: bson.M{"$in": []interface{}{EventAcknowledged, nil}}, eventAcknowledgedKey: true}
+	default:
+		return bson.M{eventStateKey: state}
+	}
+}
+
+// transitionEvents applies the transition to the events that match
+// the query and are in one of the from states, and returns the number
+// of events that changed.
+func transitionEvents(query bson.M, from []string, t EventTransition) (int, error) {
+	total := 0
+	for _, u := range transitionUpdates(query, from, t) {
+		num, err := db.Query(u.Query).UpdateAll(eventCollection, u.Update)
+		if err != nil {
+			return total, errors.Wrapf(err, "problem moving events to '%s'", t.To)
+		}
+		total += num
+	}
+
+	return total, nil
+}
+
+// transitionUpdates returns the updates that apply the transition to
+// the events that match the query and are in each of the from states,
+// recording each event's previous state in its history. The
+// transition's From field is ignored.
+func transitionUpdates(query bson.M, from []string, t EventTransition) []db.BulkUpdate {
+	set := bson.M{
+		eventStateKey:        t.To,
+		eventAcknowledgedKey: isAcknowledged(t.To),
+	}
+	if t.To == EventReopened {
+		set[eventOpenedKey] = t.Time
+	}
+	if t.To == EventSnoozed {
+		set[eventSnoozedUntilKey] = t.Until
+	}
+
+	out := make([]db.BulkUpdate, 0, len(from))
+	for _, state := range from {
+		t.From = state
+
+		update := bson.M{
+			"$set":  set,
+			"$push": bson.M{eventHistoryKey: t},
+		}
+		if t.To != EventSnoozed {
+			update["$unset"] = bson.M{eventSnoozedUntilKey: ""}
+		}
+
+		out = append(out, db.BulkUpdate{
+			Query:  bson.M{"$and": []bson.M{query, stateQuery(state)}},
+			Update: update,
+			Multi:  true,
+		})
+	}
+
+	return out
+}
+
+// reopenUpdates returns the updates that reopen the stored event that
+// the recorded event recurs, if it was acknowledged, resolved, or
+// snoozed until before the recurrence.
+func (e *Event) reopenUpdates() []db.BulkUpdate {
+	query := bson.M{
+		eventKeyKey: e.Key,
+		"$or": []bson.M{
+			{eventSnoozedUntilKey: bson.M{"$exists": false}},
+			{eventSnoozedUntilKey: bson.M{"$lte": e.LastSeen}},
+		},
+	}
+
+	return transitionUpdates(query, []string{EventAcknowledged, EventSnoozed, EventResolved}, EventTransition{
+		To:   EventReopened,
+		User: eventSystemUser,
+		Note: "event recurred",
+		Time: e.LastSeen,
+	})
+}
+
+// ReopenExpiredSnoozes reopens the snoozed events whose snooze ended
+// before now, and returns the number of events reopened.
+func ReopenExpiredSnoozes(now time.Time) (int, error) {
+	num, err := transitionEvents(bson.M{eventSnoozedUntilKey: bson.M{"$lte": now}}, []string{EventSnoozed}, EventTransition{
+		To:   EventReopened,
+		User: eventSystemUser,
+		Note: "snooze expired",
+		Time: now,
+	})
+
+	return num, errors.WithStack(err)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestEventCurrentState(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(EventOpen, (&Event{}).CurrentState())
+	assert.Equal(EventAcknowledged, (&Event{Acknowledged: true}).CurrentState())
+	assert.Equal(EventSnoozed, (&Event{Acknowledged: true, State: EventSnoozed}).CurrentState())
+}
+
+func TestEventTransitionValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	transition := func(to string, until time.Time) *EventTransition {
+		return &EventTransition{To: to, User: "user", Until: until, Time: now}
+	}
+
+	assert.NoError(transition(EventAcknowledged, time.Time{}).Validate(EventOpen))
+	assert.NoError(transition(EventResolved, time.Time{}).Validate(EventReopened))
+	assert.NoError(transition(EventReopened, time.Time{}).Validate(EventResolved))
+	assert.NoError(transition(EventSnoozed, now.Add(time.Hour)).Validate(EventSnoozed))
+
+	assert.Error(transition(EventAcknowledged, time.Time{}).Validate(EventResolved))
+	assert.Error(transition(EventReopened, time.Time{}).Validate(EventOpen))
+	assert.Error(transition(EventOpen, time.Time{}).Validate(EventAcknowledged))
+	assert.Error(transition("closed", time.Time{}).Validate(EventOpen))
+
+	assert.Error(transition(EventSnoozed, time.Time{}).Validate(EventOpen))
+	assert.Error(transition(EventSnoozed, now.Add(-time.Hour)).Validate(EventOpen))
+	assert.Error(transition(EventResolved, now.Add(time.Hour)).Validate(EventOpen))
+
+	anonymous := transition(EventAcknowledged, time.Time{})
+	anonymous.User = ""
+	assert.Error(anonymous.Validate(EventOpen))
+
+	assigned := transition(EventAcknowledged, time.Time{})
+	assigned.Assignee = "other"
+	assert.Error(assigned.Validate(EventOpen))
+}
+
+func TestEventAssignTransition(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	assign := &EventTransition{To: EventAssign, User: "user", Assignee: "other", Time: now}
+	for _, state := range []string{EventOpen, EventAcknowledged, EventSnoozed, EventResolved, EventReopened} {
+		assert.NoError(assign.Validate(state))
+	}
+
+	unassign := &EventTransition{To: EventAssign, User: "user", Time: now}
+	assert.NoError(unassign.Validate(EventOpen))
+
+	assert.Error((&EventTransition{To: EventAssign, Assignee: "other", Time: now}).Validate(EventOpen))
+	assert.Error((&EventTransition{To: EventAssign, User: "user", Until: now.Add(time.Hour), Time: now}).Validate(EventOpen))
+
+	// assignment records the transition in the history without
+	// changing the state.
+	update := assignUpdate(*assign)
+	assert.Equal(bson.M{eventAssigneeKey: "other"}, update["$set"])
+	assert.Equal(bson.M{eventHistoryKey: *assign}, update["$push"])
+	assert.NotContains(update, "$unset")
+
+	update = assignUpdate(*unassign)
+	assert.Equal(bson.M{eventAssigneeKey: ""}, update["$unset"])
+	assert.NotContains(update, "$set")
+}
+
+func TestEventStateQueryIncludesLegacyEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	open := stateQuery(EventOpen)
+	assert.Equal(false, open[eventAcknowledgedKey])
+	assert.Contains(open[eventStateKey], "$in")
+
+	acked := stateQuery(EventAcknowledged)
+	assert.Equal(true, acked[eventAcknowledgedKey])
+
+	resolved := stateQuery(EventResolved)
+	assert.Equal(EventResolved, resolved[eventStateKey])
+	assert.NotContains(resolved, eventAcknowledgedKey)
+}
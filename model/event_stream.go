@@ -0,0 +1,93 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// eventFollowerLag is how far behind the newest write the follower
+// looks for events, as writes that start before a query can commit
+// after it, with earlier times.
+const eventFollowerLag = 10 * time.Second
+
+// EventFollower follows the events collection for occurrences of
+// events that match a filter. Because it reads the collection rather
+// than the events that one process records, it sees the events that
+// every sink process stores, including spooled events that are stored
+// late.
+type EventFollower struct {
+	filter *EventFilter
+	start  time.Time
+	last   time.Time
+
+	// seen holds the count of each event returned within the lag of
+	// the last time, so that the follower returns each occurrence
+	// once.
+	seen map[bson.ObjectId]*Event
+}
+
+// NewEventFollower returns a follower for the events that match the
+// filter and are stored at or after the start time.
+func NewEventFollower(filter *EventFilter, start time.Time) *EventFollower {
+	return &EventFollower{
+		filter: filter,
+		start:  start,
+		last:   start,
+		seen:   map[bson.ObjectId]*Event{},
+	}
+}
+
+// Next returns the matching events that have been stored since the
+// previous call, in the order that they were stored. Because repeated
+// events are collapsed into one document, an event is returned again,
+// with its new count and samples, each time it recurs.
+func (f *EventFollower) Next() ([]*Event, error) {
+	query := f.filter.query()
+	query[eventRecordedKey] = bson.M{"$gte": f.since()}
+
+	events := []*Event{}
+	if err := db.Query(query).Sort(eventRecordedKey, eventIDKey).FindAll(eventCollection, &events); err != nil {
+		return nil, errors.Wrap(err, "problem finding recent events")
+	}
+
+	return f.advance(events), nil
+}
+
+func (f *EventFollower) since() time.Time {
+	since := f.last.Add(-eventFollowerLag)
+	if since.Before(f.start) {
+		return f.start
+	}
+
+	return since
+}
+
+// advance moves the follower past the events, which are ordered by
+// the time they were stored, and returns the events that it had not
+// already returned.
+func (f *EventFollower) advance(events []*Event) []*Event {
+	out := []*Event{}
+	for _, e := range events {
+		if prev, ok := f.seen[e.ID]; ok && prev.Count == e.Count {
+			continue
+		}
+
+		f.seen[e.ID] = e
+		if e.Recorded.After(f.last) {
+			f.last = e.Recorded
+		}
+		out = append(out, e)
+	}
+
+	since := f.since()
+	for id, e := range f.seen {
+		if e.Recorded.Before(since) {
+			delete(f.seen, id)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestEventFollowerReturnsNewOccurrences(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	f := NewEventFollower(&EventFilter{}, start)
+	assert.Equal(start, f.since())
+
+	one := &Event{ID: bson.NewObjectId(), Count: 1, Recorded: start}
+	two := &Event{ID: bson.NewObjectId(), Count: 1, Recorded: start.Add(time.Second)}
+	assert.Equal([]*Event{one, two}, f.advance([]*Event{one, two}))
+
+	// the query includes the events within the lag, which the
+	// follower has already returned.
+	assert.Len(f.advance([]*Event{one, two}), 0)
+
+	// another occurrence changes the count, and a write that commits
+	// late has an earlier time than events already returned.
+	again := &Event{ID: two.ID, Count: 2, Recorded: two.Recorded}
+	late := &Event{ID: bson.NewObjectId(), Count: 1, Recorded: start.Add(500 * time.Millisecond)}
+	assert.Equal([]*Event{late, again}, f.advance([]*Event{one, late, again}))
+
+	// events older than the lag are forgotten.
+	later := &Event{ID: one.ID, Count: 2, Recorded: start.Add(time.Minute)}
+	assert.Equal([]*Event{later}, f.advance([]*Event{later}))
+	assert.Equal(later.Recorded, f.last)
+	assert.Equal(later.Recorded.Add(-eventFollowerLag), f.since())
+	assert.Len(f.seen, 1)
+}
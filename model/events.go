@@ -0,0 +1,178 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/mongodb/grip/level"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// EventFilter selects events by component, level, time, and
+// acknowledgment. Zero values do not constrain the results.
+type EventFilter struct {
+	Component string
+	// MinLevel and MaxLevel bound the events' priority, inclusively.
+	MinLevel level.Priority
+	MaxLevel level.Priority
+	// Start and End bound the time that the events were first seen,
+	// their Timestamp, so that an event that recurs within the window
+	// but was first seen before it does not match.
+	Start        time.Time
+	End          time.Time
+	Acknowledged *bool
+}
+
+// Validate returns an error if the level range is not valid.
+func (f *EventFilter) Validate() error {
+	if f.MinLevel != level.Invalid && !level.IsValidPriority(f.MinLevel) {
+		return errors.Errorf("%d is not a valid minimum level", f.MinLevel)
+	}
+
+	if f.MaxLevel != level.Invalid && !level.IsValidPriority(f.MaxLevel) {
+		return errors.Errorf("%d is not a valid maximum level", f.MaxLevel)
+	}
+
+	if f.MinLevel != level.Invalid && f.MaxLevel != level.Invalid && f.MinLevel > f.MaxLevel {
+		return errors.Errorf("minimum level '%s' is above maximum level '%s'", f.MinLevel, f.MaxLevel)
+	}
+
+	if !f.Start.IsZero() && !f.End.IsZero() && !f.Start.Before(f.End) {
+		return errors.New("start time must be before end time")
+	}
+
+	return nil
+}
+
+// IsEmpty returns true if the filter matches all events.
+func (f *EventFilter) IsEmpty() bool {
+	return f.Component == "" && f.MinLevel == level.Invalid && f.MaxLevel == level.Invalid &&
+		f.Start.IsZero() && f.End.IsZero() && f.Acknowledged == nil
+}
+
+// levels returns the names of the levels in the filter's range, as
+// events store the name of their level rather than its priority.
+func (f *EventFilter) levels() []string {
+	min, max := f.MinLevel, f.MaxLevel
+	if min == level.Invalid {
+		min = level.Trace
+	}
+	if max == level.Invalid {
+		max = level.Emergency
+	}
+
+	out := []string{}
+	for p := min; p <= max; p++ {
+		if name := p.String(); name != level.Invalid.String() {
+			out = append(out, name)
+		}
+	}
+
+	return out
+}
+
+func (f *EventFilter) query() bson.M {
+	query := bson.M{}
+
+	if f.Component != "" {
+		query[eventComponentKey] = f.Component
+	}
+
+	if f.MinLevel != level.Invalid || f.MaxLevel != level.Invalid {
+		query[eventLevelKey] = bson.M{"$in": f.levels()}
+	}
+
+	ts := bson.M{}
+	if !f.Start.IsZero() {
+		ts["$gte"] = f.Start
+	}
+	if !f.End.IsZero() {
+		ts["$lt"] = f.End
+	}
+	if len(ts) > 0 {
+		query[eventTimestampKey] = ts
+	}
+
+	if f.Acknowledged != nil {
+		query[eventAcknowledgedKey] = *f.Acknowledged
+	}
+
+	return query
+}
+
+// FindPage populates up to limit events that match the filter, most
+// recently first seen first. Events are ordered by ID, which does not
+// change when an event recurs, so pages remain stable as events are
+// recorded. To page through results, pass the ID of the last event of
+// the previous page as the cursor; an empty cursor starts from the
+// newest event.
+func (e *Events) FindPage(filter *EventFilter, cursor string, limit int) error {
+	query := filter.query()
+	if cursor != "" {
+		if !bson.IsObjectIdHex(cursor) {
+			return errors.Errorf("'%s' is not a valid cursor", cursor)
+		}
+		query[eventIDKey] = bson.M{"$lt": bson.ObjectIdHex(cursor)}
+	}
+
+	q := db.Query(query).Sort("-" + eventIDKey)
+	if limit > 0 {
+		q.Limit(limit)
+	}
+
+	e.populated = false
+	e.slice = []*Event{}
+	if err := q.FindAll(eventCollection, &e.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	e.populated = true
+
+	return nil
+}
+
+// CountMatching returns the number of events that match the filter.
+func (e *Events) CountMatching(filter *EventFilter) (int, error) {
+	num, err := db.Query(filter.query()).Count(eventCollection)
+	return num, errors.WithStack(err)
+}
+
+// AcknowledgeEvents moves the open events with the specified IDs, or
+// that match the filter, to the acknowledged state, recording the user
+// and note in their history, and returns the number of events that
+// changed. At least one of the IDs or the filter must be specified;
+// when both are, events must satisfy both.
+func AcknowledgeEvents(ids []string, filter *EventFilter, user, note string) (int, error) {
+	if len(ids) == 0 && (filter == nil || filter.IsEmpty()) {
+		return 0, errors.New("must specify events to acknowledge")
+	}
+
+	if user == "" {
+		return 0, errors.New("must specify the user acknowledging the events")
+	}
+
+	query := bson.M{}
+	if filter != nil {
+		query = filter.query()
+	}
+
+	if len(ids) > 0 {
+		oids := make([]bson.ObjectId, 0, len(ids))
+		for _, id := range ids {
+			if !bson.IsObjectIdHex(id) {
+				return 0, errors.Errorf("'%s' is not a valid event id", id)
+			}
+			oids = append(oids, bson.ObjectIdHex(id))
+		}
+		query[eventIDKey] = bson.M{"$in": oids}
+	}
+
+	num, err := transitionEvents(query, []string{EventOpen, EventReopened}, EventTransition{
+		To:   EventAcknowledged,
+		User: user,
+		Note: note,
+		Time: time.Now(),
+	})
+
+	return num, errors.WithStack(err)
+}
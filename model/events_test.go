@@ -0,0 +1,122 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mongodb/grip/level"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestEventFilterLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	filter := &EventFilter{MinLevel: level.Warning, MaxLevel: level.Critical}
+	assert.Equal([]string{"warning", "error", "critical"}, filter.levels())
+
+	filter = &EventFilter{MinLevel: level.Alert}
+	assert.Equal([]string{"alert", "emergency"}, filter.levels())
+
+	filter = &EventFilter{MaxLevel: level.Debug}
+	assert.Equal([]string{"trace", "debug"}, filter.levels())
+}
+
+func TestEventFilterQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	empty := &EventFilter{}
+	assert.True(empty.IsEmpty())
+	assert.Len(empty.query(), 0)
+
+	ack := false
+	start := time.Now().Add(-time.Hour)
+	filter := &EventFilter{
+		Component:    "sink",
+		MinLevel:     level.Error,
+		MaxLevel:     level.Error,
+		Start:        start,
+		Acknowledged: &ack,
+	}
+	assert.False(filter.IsEmpty())
+
+	query := filter.query()
+	assert.Equal("sink", query[eventComponentKey])
+	assert.Equal(bson.M{"$in": []string{"error"}}, query[eventLevelKey])
+	assert.Equal(bson.M{"$gte": start}, query[eventTimestampKey])
+	assert.Equal(false, query[eventAcknowledgedKey])
+}
+
+func TestEventFilterValidate(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	assert.NoError((&EventFilter{}).Validate())
+	assert.NoError((&EventFilter{MinLevel: level.Info, MaxLevel: level.Error}).Validate())
+	assert.Error((&EventFilter{MinLevel: level.Error, MaxLevel: level.Info}).Validate())
+	assert.Error((&EventFilter{MinLevel: level.Priority(101)}).Validate())
+	assert.Error((&EventFilter{Start: now, End: now.Add(-time.Minute)}).Validate())
+}
+
+func TestAcknowledgeEventsRequiresSelection(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := AcknowledgeEvents(nil, nil, "user", "")
+	assert.Error(err)
+
+	_, err = AcknowledgeEvents(nil, &EventFilter{}, "user", "")
+	assert.Error(err)
+
+	_, err = AcknowledgeEvents([]string{"not-an-id"}, nil, "user", "")
+	assert.Error(err)
+
+	_, err = AcknowledgeEvents([]string{bson.NewObjectId().Hex()}, nil, "", "")
+	assert.Error(err)
+}
+
+func TestEventFilterMatchesFirstSeenTime(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	query := (&EventFilter{Start: start, End: end}).query()
+
+	assert.Equal(bson.M{"$gte": start, "$lt": end}, query[eventTimestampKey])
+	assert.NotContains(query, eventLastSeenKey)
+}
+
+func TestNormalizeEventMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("job <id> failed after <n> attempts",
+		normalizeEventMessage("job 5a0b1c2d3e4f5a6b7c8d9e0f failed after 3 attempts"))
+	assert.Equal("request <id> took <n>s",
+		normalizeEventMessage("request  123e4567-e89b-12d3-a456-426655440000 took 1.25s"))
+	assert.Equal("no variable parts", normalizeEventMessage(" no variable\tparts "))
+	assert.Equal("disk at <n>% after <n>, retrying in <n>ms.",
+		normalizeEventMessage("disk at 95% after -3, retrying in 500ms."))
+
+	for _, msg := range []string{
+		"lost contact with ip-10-0-0-1",
+		"lost contact with build-host-3",
+		"lost contact with 10.0.0.1",
+		"job hello-world-12 failed",
+		"job task_42 failed",
+		"could not open /var/log/sink/2017/10/out.log",
+		"could not open logs/app.3.log",
+	} {
+		assert.Equal(msg, normalizeEventMessage(msg))
+	}
+}
+
+func TestEventKeyCollapsesOccurrences(t *testing.T) {
+	assert := assert.New(t)
+	mtype := "*message.stringMessage"
+
+	first := eventKey("sink", mtype, "problem scheduling job hello-world-12 (count: 1)")
+	assert.Equal(first, eventKey("sink", mtype, "problem scheduling job hello-world-12 (count: 2)"))
+	assert.NotEqual(first, eventKey("sink", mtype, "problem scheduling job hello-world-13 (count: 1)"))
+	assert.NotEqual(first, eventKey("other", mtype, "problem scheduling job hello-world-12 (count: 1)"))
+	assert.NotEqual(first, eventKey("sink", "*message.fieldsMessage", "problem scheduling job hello-world-12 (count: 1)"))
+	assert.NotEqual(first, eventKey("sink", mtype, "problem running job hello-world-12 (count: 1)"))
+}
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/mongodb/grip"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Models declare the indexes for their collections by calling
+// registerIndexes from an init function, next to the collection's
+// definition. EnsureIndexes creates the declared indexes, and
+// CheckIndexes compares them to the indexes that exist.
+var declaredIndexes = map[string][]mgo.Index{}
+
+func registerIndexes(collection string, indexes ...mgo.Index) {
+	for idx := range indexes {
+		indexes[idx].Background = true
+	}
+
+	declaredIndexes[collection] = append(declaredIndexes[collection], indexes...)
+}
+
+func declaredCollections() []string {
+	out := make([]string, 0, len(declaredIndexes))
+	for coll := range declaredIndexes {
+		out = append(out, coll)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func indexKey(idx mgo.Index) string { return strings.Join(idx.Key, ",") }
+
+func describeIndex(idx mgo.Index) string {
+	out := []string{fmt.Sprintf("{%s}", indexKey(idx))}
+	if idx.Unique {
+		out = append(out, "unique")
+	}
+	if idx.Sparse {
+		out = append(out, "sparse")
+	}
+	if idx.ExpireAfter > 0 {
+		out = append(out, fmt.Sprintf("ttl=%s", idx.ExpireAfter))
+	}
+
+	return strings.Join(out, " ")
+}
+
+// EnsureIndexes creates every declared index that does not exist.
+func EnsureIndexes() error {
+	return ensureIndexes(func(mgo.Index) bool { return true })
+}
+
+// EnsureUniqueIndexes creates the declared unique indexes that do not
+// exist. Other indexes only make queries faster, but unique indexes
+// enforce constraints that the models rely on, such as there being
+// one event for each event key.
+func EnsureUniqueIndexes() error {
+	return ensureIndexes(func(idx mgo.Index) bool { return idx.Unique })
+}
+
+func ensureIndexes(include func(mgo.Index) bool) error {
+	catcher := grip.NewCatcher()
+	for _, coll := range declaredCollections() {
+		for _, idx := range declaredIndexes[coll] {
+			if !include(idx) {
+				continue
+			}
+
+			err := db.EnsureIndex(coll, idx)
+			catcher.Add(errors.Wrapf(err, "problem ensuring index %s on '%s'", describeIndex(idx), coll))
+		}
+	}
+
+	return catcher.Resolve()
+}
+
+// Kinds of differences between declared and existing indexes.
+const (
+	IndexMissing    = "missing"
+	IndexUndeclared = "undeclared"
+	IndexChanged    = "changed"
+)
+
+// IndexDrift describes a difference between the indexes declared for
+// a collection and the indexes that exist.
+type IndexDrift struct {
+	Collection string `json:"collection"`
+	Problem    string `json:"problem"`
+	Declared   string `json:"declared,omitempty"`
+	Existing   string `json:"existing,omitempty"`
+}
+
+func (d IndexDrift) String() string {
+	switch d.Problem {
+	case IndexMissing:
+		return fmt.Sprintf("%s: missing index %s", d.Collection, d.Declared)
+	case IndexUndeclared:
+		return fmt.Sprintf("%s: undeclared index %s", d.Collection, d.Existing)
+	default:
+		return fmt.Sprintf("%s: index %s exists as %s", d.Collection, d.Declared, d.Existing)
+	}
+}
+
+// CheckIndexes compares the declared indexes on each collection to
+// the indexes that exist, and returns the differences.
+func CheckIndexes() ([]IndexDrift, error) {
+	out := []IndexDrift{}
+	for _, coll := range declaredCollections() {
+		existing, err := db.Indexes(coll)
+		if err != nil {
+			return nil, errors.Wrapf(err, "problem listing indexes on '%s'", coll)
+		}
+
+		out = append(out, compareIndexes(coll, declaredIndexes[coll], existing)...)
+	}
+
+	return out, nil
+}
+
+func compareIndexes(coll string, declared, existing []mgo.Index) []IndexDrift {
+	out := []IndexDrift{}
+
+	byKey := map[string]mgo.Index{}
+	for _, idx := range existing {
+		byKey[indexKey(idx)] = idx
+	}
+
+	for _, idx := range declared {
+		key := indexKey(idx)
+		found, ok := byKey[key]
+		delete(byKey, key)
+
+		if !ok {
+			out = append(out, IndexDrift{Collection: coll, Problem: IndexMissing, Declared: describeIndex(idx)})
+			continue
+		}
+
+		if describeIndex(idx) != describeIndex(found) {
+			out = append(out, IndexDrift{
+				Collection: coll,
+				Problem:    IndexChanged,
+				Declared:   describeIndex(idx),
+				Existing:   describeIndex(found),
+			})
+		}
+	}
+
+	// existing indexes are already in a stable order.
+	for _, idx := range existing {
+		if _, ok := byKey[indexKey(idx)]; ok && indexKey(idx) != "_id" {
+			out = append(out, IndexDrift{Collection: coll, Problem: IndexUndeclared, Existing: describeIndex(idx)})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	mgo "gopkg.in/mgo.v2"
+)
+
+func TestDeclaredIndexes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, coll := range []string{eventCollection, logSegmentsCollection, sysInfoBucketCollection,
+		depNodeCollection, depEdgeCollection} {
+		assert.NotEmpty(declaredIndexes[coll], coll)
+	}
+
+	for coll, indexes := range declaredIndexes {
+		for _, idx := range indexes {
+			assert.True(idx.Background, coll)
+			if idx.ExpireAfter > 0 {
+				assert.Len(idx.Key, 1, "ttl indexes must have one field: %s", coll)
+			}
+		}
+	}
+}
+
+func TestCompareIndexes(t *testing.T) {
+	assert := assert.New(t)
+
+	declared := []mgo.Index{
+		{Key: []string{"hn", "ts"}},
+		{Key: []string{"key"}, Unique: true},
+		{Key: []string{"ts"}, ExpireAfter: time.Hour},
+	}
+
+	existing := []mgo.Index{
+		{Key: []string{"_id"}},
+		{Key: []string{"hn", "ts"}},
+		{Key: []string{"ts"}, ExpireAfter: 2 * time.Hour},
+		{Key: []string{"old"}},
+	}
+
+	assert.Empty(compareIndexes("c", declared, declared))
+
+	drift := compareIndexes("c", declared, existing)
+	if assert.Len(drift, 3) {
+		assert.Equal(IndexDrift{Collection: "c", Problem: IndexMissing, Declared: "{key} unique"}, drift[0])
+		assert.Equal(IndexDrift{Collection: "c", Problem: IndexChanged, Declared: "{ts} ttl=1h0m0s", Existing: "{ts} ttl=2h0m0s"}, drift[1])
+		assert.Equal(IndexDrift{Collection: "c", Problem: IndexUndeclared, Existing: "{old}"}, drift[2])
+	}
+}
@@ -10,6 +10,10 @@ import (
 
 const logSegmentsCollection = "simple.log.segments"
 
+func init() {
+	registerIndexes(logSegmentsCollection, mgo.Index{Key: []string{logSegmentLogIDKey, logSegmentSegmentIDKey}})
+}
+
 type LogSegment struct {
 	// common log information
 	ID      bson.ObjectId `bson:"_id"`
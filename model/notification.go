@@ -0,0 +1,497 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip/level"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	notificationRuleCollection     = "application.notifications.rules"
+	notificationDeliveryCollection = "application.notifications.deliveries"
+	notificationCursorCollection   = "application.notifications.cursor"
+)
+
+// notificationDeliveryRetention is how long the delivery log is kept.
+const notificationDeliveryRetention = 30 * 24 * time.Hour
+
+func init() {
+	registerIndexes(notificationDeliveryCollection,
+		mgo.Index{Key: []string{notificationDeliveryStatusKey, notificationDeliveryNextKey}},
+		mgo.Index{Key: []string{notificationDeliveryRuleKey, "-" + notificationDeliveryCreatedKey}},
+		mgo.Index{Key: []string{notificationDeliveryCreatedKey}, ExpireAfter: notificationDeliveryRetention})
+}
+
+// Kinds of notification targets. Webhooks receive the event as JSON,
+// and Slack targets are incoming webhook URLs that receive a one-line
+// summary of the event.
+const (
+	NotificationTargetWebhook = "webhook"
+	NotificationTargetEmail   = "email"
+	NotificationTargetSlack   = "slack"
+)
+
+// States of a notification delivery.
+const (
+	NotificationPending   = "pending"
+	NotificationDelivered = "delivered"
+	NotificationFailed    = "failed"
+)
+
+const (
+	notificationDefaultLevel       = "error"
+	notificationDefaultMaxAttempts = 5
+
+	// notificationRetryInterval is the delay before the first retry;
+	// each later retry waits twice as long as the one before.
+	notificationRetryInterval = time.Minute
+
+	// notificationClaimLease is how long a delivery that one process
+	// has claimed is hidden from others. If the process stops before
+	// recording the attempt, the delivery is attempted again once the
+	// lease expires.
+	notificationClaimLease = 10 * time.Minute
+
+	notificationCursorID = "events"
+)
+
+// NotificationRule forwards events that match its level, component,
+// and message type to a target. Empty component and message type
+// filters match all events.
+type NotificationRule struct {
+	ID          string             `bson:"_id" json:"id"`
+	MinLevel    string             `bson:"level" json:"level"`
+	Component   string             `bson:"com,omitempty" json:"component,omitempty"`
+	MessageType string             `bson:"mtype,omitempty" json:"type,omitempty"`
+	Target      NotificationTarget `bson:"target" json:"target"`
+	MaxAttempts int                `bson:"attempts" json:"max_attempts"`
+
+	populated bool
+}
+
+// NotificationTarget describes where notifications are delivered.
+// Webhook and Slack targets use the URL, and email targets use the
+// SMTP settings.
+//
+// The SMTP password is stored with the rule in plain text, and is
+// omitted from JSON output. To keep it out of the database, set
+// PasswordEnv to the name of an environment variable that holds the
+// password in the process that delivers notifications instead.
+type NotificationTarget struct {
+	Type        string   `bson:"type" json:"type"`
+	URL         string   `bson:"url,omitempty" json:"url,omitempty"`
+	SMTPServer  string   `bson:"smtp_server,omitempty" json:"smtp_server,omitempty"`
+	SMTPPort    int      `bson:"smtp_port,omitempty" json:"smtp_port,omitempty"`
+	UseSSL      bool     `bson:"ssl,omitempty" json:"ssl,omitempty"`
+	Username    string   `bson:"username,omitempty" json:"username,omitempty"`
+	Password    string   `bson:"password,omitempty" json:"-"`
+	PasswordEnv string   `bson:"password_env,omitempty" json:"password_env,omitempty"`
+	From        string   `bson:"from,omitempty" json:"from,omitempty"`
+	To          []string `bson:"to,omitempty" json:"to,omitempty"`
+}
+
+var (
+	notificationRuleIDKey = bsonutil.MustHaveTag(NotificationRule{}, "ID")
+)
+
+func (r *NotificationRule) IsNil() bool { return !r.populated }
+
+// Validate checks that the rule is well formed, and sets defaults for
+// the level and the number of delivery attempts.
+func (r *NotificationRule) Validate() error {
+	if r.ID == "" {
+		return errors.New("notification rule must have an id")
+	}
+
+	if r.MinLevel == "" {
+		r.MinLevel = notificationDefaultLevel
+	}
+
+	if !level.IsValidPriority(level.FromString(r.MinLevel)) {
+		return errors.Errorf("'%s' is not a valid level", r.MinLevel)
+	}
+
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = notificationDefaultMaxAttempts
+	}
+
+	if r.MaxAttempts < 0 {
+		return errors.New("notification rule must allow at least one attempt")
+	}
+
+	return errors.WithStack(r.Target.Validate())
+}
+
+// Validate checks that the target has the settings its type needs.
+func (t *NotificationTarget) Validate() error {
+	switch t.Type {
+	case NotificationTargetWebhook, NotificationTargetSlack:
+		if t.URL == "" {
+			return errors.Errorf("%s targets must have a url", t.Type)
+		}
+		if err := ValidatePublicURL(t.URL); err != nil {
+			return errors.Wrapf(err, "%s target has an invalid url", t.Type)
+		}
+	case NotificationTargetEmail:
+		if t.From == "" || len(t.To) == 0 {
+			return errors.New("email targets must have a sender and at least one recipient")
+		}
+		if t.Password != "" && t.PasswordEnv != "" {
+			return errors.New("email targets cannot specify both a password and a password variable")
+		}
+	default:
+		return errors.Errorf("'%s' is not a supported notification target", t.Type)
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule applies to the event.
+func (r *NotificationRule) Matches(e *Event) bool {
+	if level.FromString(e.Level) < level.FromString(r.MinLevel) {
+		return false
+	}
+
+	if r.Component != "" && r.Component != e.Component {
+		return false
+	}
+
+	if r.MessageType != "" && r.MessageType != e.MessageType {
+		return false
+	}
+
+	return true
+}
+
+// Save inserts or replaces the rule.
+func (r *NotificationRule) Save() error {
+	if err := r.Validate(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(db.UpsertID(notificationRuleCollection, r.ID, r))
+}
+
+func (r *NotificationRule) Find(id string) error {
+	err := db.Query(bson.M{notificationRuleIDKey: id}).FindOne(notificationRuleCollection, r)
+
+	r.populated = false
+	if errors.Cause(err) == mgo.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "problem running notification rule query")
+	}
+	r.populated = true
+
+	return nil
+}
+
+// Remove deletes the rule. Pending deliveries for the rule fail the
+// next time delivery is attempted.
+func (r *NotificationRule) Remove() error {
+	return errors.WithStack(db.Query(bson.M{notificationRuleIDKey: r.ID}).RemoveOne(notificationRuleCollection))
+}
+
+type NotificationRules struct {
+	slice     []*NotificationRule
+	populated bool
+}
+
+func (r *NotificationRules) Slice() []*NotificationRule { return r.slice }
+func (r *NotificationRules) IsNil() bool                { return !r.populated }
+
+func (r *NotificationRules) FindAll() error {
+	r.populated = false
+	if err := db.Query(bson.M{}).Sort(notificationRuleIDKey).FindAll(notificationRuleCollection, &r.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	r.populated = true
+
+	return nil
+}
+
+///////////////////////////////////
+//
+// delivery log
+
+// NotificationDelivery records the delivery of one event for one rule,
+// including every attempt made.
+type NotificationDelivery struct {
+	ID          string                `bson:"_id" json:"id"`
+	RuleID      string                `bson:"rule" json:"rule"`
+	EventID     bson.ObjectId         `bson:"event" json:"event"`
+	Target      string                `bson:"target" json:"target"`
+	Status      string                `bson:"status" json:"status"`
+	Created     time.Time             `bson:"created" json:"created"`
+	NextAttempt time.Time             `bson:"next,omitempty" json:"next_attempt,omitempty"`
+	Attempts    []NotificationAttempt `bson:"attempts" json:"attempts"`
+}
+
+// NotificationAttempt records the outcome of one delivery attempt.
+type NotificationAttempt struct {
+	Time  time.Time `bson:"ts" json:"time"`
+	Error string    `bson:"err,omitempty" json:"error,omitempty"`
+}
+
+var (
+	notificationDeliveryRuleKey    = bsonutil.MustHaveTag(NotificationDelivery{}, "RuleID")
+	notificationDeliveryStatusKey  = bsonutil.MustHaveTag(NotificationDelivery{}, "Status")
+	notificationDeliveryCreatedKey = bsonutil.MustHaveTag(NotificationDelivery{}, "Created")
+	notificationDeliveryNextKey    = bsonutil.MustHaveTag(NotificationDelivery{}, "NextAttempt")
+)
+
+func newNotificationDelivery(rule *NotificationRule, e *Event, now time.Time) *NotificationDelivery {
+	return &NotificationDelivery{
+		ID:          fmt.Sprintf("%s|%s|%d", rule.ID, e.ID.Hex(), e.Opened.UnixNano()),
+		RuleID:      rule.ID,
+		EventID:     e.ID,
+		Target:      rule.Target.Type,
+		Status:      NotificationPending,
+		Created:     now,
+		NextAttempt: now,
+		Attempts:    []NotificationAttempt{},
+	}
+}
+
+// record adds the outcome of an attempt to the delivery, and either
+// completes the delivery or schedules a retry with exponential
+// backoff, until the delivery has been attempted maxAttempts times.
+func (d *NotificationDelivery) record(now time.Time, err error, maxAttempts int) {
+	attempt := NotificationAttempt{Time: now}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	d.Attempts = append(d.Attempts, attempt)
+
+	switch {
+	case err == nil:
+		d.Status = NotificationDelivered
+		d.NextAttempt = time.Time{}
+	case len(d.Attempts) >= maxAttempts:
+		d.Status = NotificationFailed
+		d.NextAttempt = time.Time{}
+	default:
+		d.NextAttempt = now.Add(notificationRetryInterval << uint(len(d.Attempts)-1))
+	}
+}
+
+// claimQuery matches the delivery only while it is pending and due at
+// the time that it was read.
+func (d *NotificationDelivery) claimQuery() bson.M {
+	return bson.M{
+		"_id":                         d.ID,
+		notificationDeliveryStatusKey: NotificationPending,
+		notificationDeliveryNextKey:   d.NextAttempt,
+	}
+}
+
+// claim moves the next attempt past the lease, so that other processes
+// do not attempt the delivery at the same time, and reports whether
+// this call claimed it. Another process has claimed the delivery, or
+// completed it, if the delivery no longer matches what was read.
+func (d *NotificationDelivery) claim(now time.Time) (bool, error) {
+	lease := now.Add(notificationClaimLease)
+	err := db.Query(d.claimQuery()).Update(notificationDeliveryCollection,
+		bson.M{"$set": bson.M{notificationDeliveryNextKey: lease}})
+	if errors.Cause(err) == mgo.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "problem claiming notification delivery '%s'", d.ID)
+	}
+
+	d.NextAttempt = lease
+	return true, nil
+}
+
+func (d *NotificationDelivery) save() error {
+	return errors.WithStack(db.UpsertID(notificationDeliveryCollection, d.ID, d))
+}
+
+type NotificationDeliveries struct {
+	slice     []*NotificationDelivery
+	populated bool
+}
+
+func (d *NotificationDeliveries) Slice() []*NotificationDelivery { return d.slice }
+func (d *NotificationDeliveries) IsNil() bool                    { return !d.populated }
+
+// Find populates the most recent deliveries, newest first. The rule
+// and status filter the results if they are not empty.
+func (d *NotificationDeliveries) Find(rule, status string, limit int) error {
+	filter := bson.M{}
+	if rule != "" {
+		filter[notificationDeliveryRuleKey] = rule
+	}
+	if status != "" {
+		filter[notificationDeliveryStatusKey] = status
+	}
+
+	query := db.Query(filter).Sort("-" + notificationDeliveryCreatedKey)
+	if limit > 0 {
+		query.Limit(limit)
+	}
+
+	d.populated = false
+	if err := query.FindAll(notificationDeliveryCollection, &d.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	d.populated = true
+
+	return nil
+}
+
+///////////////////////////////////
+//
+// dispatch
+
+// notificationCursor records the open time and id of the last event
+// queued. Events can share an open time, so the id breaks ties.
+type notificationCursor struct {
+	ID     string        `bson:"_id"`
+	Last   time.Time     `bson:"last"`
+	LastID bson.ObjectId `bson:"last_id,omitempty"`
+}
+
+// QueueNotifications creates pending deliveries for up to limit
+// events opened or re-opened since the last call, for every rule that
+// matches each event, and returns the number of deliveries created.
+// Further occurrences of an event that is still open do not produce
+// notifications. The first call only records the newest event, so
+// that existing events are not forwarded when notifications are first
+// enabled.
+func QueueNotifications(limit int) (int, error) {
+	cursor := &notificationCursor{}
+	err := db.Query(bson.M{"_id": notificationCursorID}).FindOne(notificationCursorCollection, cursor)
+	if errors.Cause(err) == mgo.ErrNotFound {
+		cursor.ID = notificationCursorID
+		latest := []*Event{}
+		if err = db.Query(bson.M{}).Sort("-"+eventOpenedKey, "-"+eventIDKey).Limit(1).FindAll(eventCollection, &latest); err != nil {
+			return 0, errors.Wrap(err, "problem finding latest event")
+		}
+		if len(latest) == 0 {
+			return 0, nil
+		}
+		cursor.Last = latest[0].Opened
+		cursor.LastID = latest[0].ID
+
+		return 0, errors.WithStack(db.UpsertID(notificationCursorCollection, cursor.ID, cursor))
+	} else if err != nil {
+		return 0, errors.Wrap(err, "problem finding notification cursor")
+	}
+
+	rules := &NotificationRules{}
+	if err = rules.FindAll(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	events := []*Event{}
+	// cursors written before the id was recorded match every event at
+	// the boundary; deliveries that already exist are skipped.
+	tiebreak := bson.M{eventOpenedKey: cursor.Last}
+	if cursor.LastID != "" {
+		tiebreak[eventIDKey] = bson.M{"$gt": cursor.LastID}
+	}
+	query := db.Query(bson.M{"$or": []bson.M{
+		{eventOpenedKey: bson.M{"$gt": cursor.Last}},
+		tiebreak,
+	}}).Sort(eventOpenedKey, eventIDKey).Limit(limit)
+	if err = query.FindAll(eventCollection, &events); err != nil {
+		return 0, errors.Wrap(err, "problem finding new events")
+	}
+
+	var count int
+	now := time.Now()
+	for _, e := range events {
+		for _, rule := range rules.Slice() {
+			if !rule.Matches(e) {
+				continue
+			}
+
+			err = db.Insert(notificationDeliveryCollection, newNotificationDelivery(rule, e, now))
+			if mgo.IsDup(errors.Cause(err)) {
+				continue
+			} else if err != nil {
+				return count, errors.Wrap(err, "problem queuing notification")
+			}
+			count++
+		}
+
+		cursor.Last = e.Opened
+		cursor.LastID = e.ID
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	return count, errors.WithStack(db.UpsertID(notificationCursorCollection, cursor.ID, cursor))
+}
+
+// DeliverNotifications attempts every pending delivery that is due,
+// and returns the number delivered successfully. Each delivery is
+// claimed before it is attempted, so that concurrent calls do not
+// deliver a notification more than once.
+func DeliverNotifications(now time.Time) (int, error) {
+	pending := []*NotificationDelivery{}
+	query := db.Query(bson.M{
+		notificationDeliveryStatusKey: NotificationPending,
+		notificationDeliveryNextKey:   bson.M{"$lte": now},
+	}).Sort(notificationDeliveryNextKey)
+
+	if err := query.FindAll(notificationDeliveryCollection, &pending); err != nil {
+		return 0, errors.Wrap(err, "problem finding pending notifications")
+	}
+
+	var count int
+	rules := map[string]*NotificationRule{}
+	for _, d := range pending {
+		claimed, err := d.claim(now)
+		if err != nil {
+			return count, errors.WithStack(err)
+		}
+		if !claimed {
+			continue
+		}
+
+		rule, ok := rules[d.RuleID]
+		if !ok {
+			rule = &NotificationRule{}
+			if err := rule.Find(d.RuleID); err != nil {
+				return count, errors.WithStack(err)
+			}
+			rules[d.RuleID] = rule
+		}
+
+		if rule.IsNil() {
+			d.record(now, errors.Errorf("notification rule '%s' no longer exists", d.RuleID), 0)
+		} else {
+			event := &Event{}
+			err := event.FindID(d.EventID.Hex())
+			if errors.Cause(err) == mgo.ErrNotFound {
+				d.record(now, errors.Errorf("event '%s' no longer exists", d.EventID.Hex()), 0)
+			} else if err != nil {
+				return count, errors.Wrap(err, "problem finding event")
+			} else {
+				d.record(now, rule.Target.Deliver(event), rule.MaxAttempts)
+			}
+		}
+
+		if d.Status == NotificationDelivered {
+			count++
+		}
+
+		if err := d.save(); err != nil {
+			return count, errors.Wrapf(err, "problem saving notification delivery '%s'", d.ID)
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/mongodb/grip/send"
+	"github.com/pkg/errors"
+)
+
+const notificationRequestTimeout = 30 * time.Second
+
+var notificationHTTPClient = &http.Client{Timeout: notificationRequestTimeout}
+
+// Deliver sends the event to the target, returning an error if the
+// target did not accept it.
+func (t *NotificationTarget) Deliver(e *Event) error {
+	switch t.Type {
+	case NotificationTargetWebhook:
+		return errors.WithStack(t.post(e))
+	case NotificationTargetSlack:
+		return errors.WithStack(t.post(map[string]string{"text": notificationSummary(e)}))
+	case NotificationTargetEmail:
+		return errors.WithStack(t.mail(e))
+	default:
+		return errors.Errorf("'%s' is not a supported notification target", t.Type)
+	}
+}
+
+func notificationSummary(e *Event) string {
+	return fmt.Sprintf("[%s] %s: %s", e.Level, e.Component, e.Message)
+}
+
+func (t *NotificationTarget) post(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "problem building notification")
+	}
+
+	resp, err := notificationHTTPClient.Post(t.URL, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return errors.Wrap(err, "problem posting notification")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("notification target responded with '%s'", resp.Status)
+	}
+
+	return nil
+}
+
+func (t *NotificationTarget) mail(e *Event) error {
+	password := t.Password
+	if t.PasswordEnv != "" {
+		password = os.Getenv(t.PasswordEnv)
+		if password == "" {
+			return errors.Errorf("smtp password variable '%s' is not set", t.PasswordEnv)
+		}
+	}
+
+	opts := &send.SMTPOptions{
+		Name:             e.Component,
+		From:             t.From,
+		Server:           t.SMTPServer,
+		Port:             t.SMTPPort,
+		UseSSL:           t.UseSSL,
+		Username:         t.Username,
+		Password:         password,
+		MessageAsSubject: true,
+	}
+	if opts.Name == "" {
+		opts.Name = "sink"
+	}
+
+	if err := opts.AddRecipients(t.To...); err != nil {
+		return errors.Wrap(err, "problem adding recipients")
+	}
+
+	sender, err := send.NewSMTPLogger(opts, send.LevelInfo{Default: level.Trace, Threshold: level.Trace})
+	if err != nil {
+		return errors.Wrap(err, "problem configuring smtp sender")
+	}
+	defer sender.Close()
+
+	// the sender reports delivery errors to its error handler rather
+	// than returning them.
+	var sendErr error
+	if err = sender.SetErrorHandler(func(err error, _ message.Composer) { sendErr = err }); err != nil {
+		return errors.WithStack(err)
+	}
+
+	p := level.FromString(e.Level)
+	if !level.IsValidPriority(p) {
+		p = level.Info
+	}
+
+	sender.Send(message.NewDefaultMessage(p, notificationSummary(e)))
+
+	return errors.Wrap(sendErr, "problem sending notification email")
+}
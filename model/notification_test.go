@@ -0,0 +1,152 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNotificationRuleValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetWebhook, URL: "https://hooks.example.com/sink"}}
+	assert.NoError(rule.Validate())
+	assert.Equal(notificationDefaultLevel, rule.MinLevel)
+	assert.Equal(notificationDefaultMaxAttempts, rule.MaxAttempts)
+
+	assert.Error((&NotificationRule{Target: rule.Target}).Validate())
+	assert.Error((&NotificationRule{ID: "r", MinLevel: "loud", Target: rule.Target}).Validate())
+	assert.Error((&NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetSlack}}).Validate())
+	assert.Error((&NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetEmail, From: "a@example.com"}}).Validate())
+	assert.Error((&NotificationRule{ID: "r", Target: NotificationTarget{Type: "pager"}}).Validate())
+	assert.Error((&NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetEmail,
+		From: "a@example.com", To: []string{"b@example.com"}, Password: "pw", PasswordEnv: "SINK_SMTP_PASSWORD"}}).Validate())
+}
+
+func TestNotificationTargetRejectsInternalURLs(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, u := range []string{
+		"https://hooks.example.com/sink",
+		"http://93.184.216.34:8080/hook",
+	} {
+		target := &NotificationTarget{Type: NotificationTargetWebhook, URL: u}
+		assert.NoError(target.Validate(), u)
+	}
+
+	for _, u := range []string{
+		"file:///etc/passwd",
+		"gopher://hooks.example.com",
+		"http://",
+		"http://localhost:8080/hook",
+		"http://LOCALHOST/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fd00::1]/hook",
+	} {
+		target := &NotificationTarget{Type: NotificationTargetSlack, URL: u}
+		assert.Error(target.Validate(), u)
+	}
+}
+
+func TestNotificationRuleMatches(t *testing.T) {
+	assert := assert.New(t)
+	rule := &NotificationRule{MinLevel: "error", Component: "sink"}
+
+	assert.True(rule.Matches(&Event{Level: "error", Component: "sink"}))
+	assert.True(rule.Matches(&Event{Level: "emergency", Component: "sink"}))
+	assert.False(rule.Matches(&Event{Level: "warning", Component: "sink"}))
+	assert.False(rule.Matches(&Event{Level: "error", Component: "other"}))
+
+	rule.MessageType = "*message.fieldsMessage"
+	assert.False(rule.Matches(&Event{Level: "error", Component: "sink", MessageType: "*message.stringMessage"}))
+	assert.True(rule.Matches(&Event{Level: "error", Component: "sink", MessageType: "*message.fieldsMessage"}))
+}
+
+func TestNotificationDeliveryRetries(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	rule := &NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetWebhook}}
+	d := newNotificationDelivery(rule, &Event{ID: bson.NewObjectId()}, now)
+
+	d.record(now, errors.New("unavailable"), 3)
+	assert.Equal(NotificationPending, d.Status)
+	assert.Equal(now.Add(notificationRetryInterval), d.NextAttempt)
+
+	d.record(now, errors.New("unavailable"), 3)
+	assert.Equal(NotificationPending, d.Status)
+	assert.Equal(now.Add(2*notificationRetryInterval), d.NextAttempt)
+
+	d.record(now, errors.New("unavailable"), 3)
+	assert.Equal(NotificationFailed, d.Status)
+	assert.True(d.NextAttempt.IsZero())
+	assert.Len(d.Attempts, 3)
+	assert.Equal("unavailable", d.Attempts[2].Error)
+
+	ok := newNotificationDelivery(rule, &Event{ID: bson.NewObjectId()}, now)
+	ok.record(now, nil, 3)
+	assert.Equal(NotificationDelivered, ok.Status)
+	assert.Equal("", ok.Attempts[0].Error)
+}
+
+func TestNotificationDeliveryClaimMatchesWhatWasRead(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	rule := &NotificationRule{ID: "r", Target: NotificationTarget{Type: NotificationTargetWebhook}}
+	d := newNotificationDelivery(rule, &Event{ID: bson.NewObjectId()}, now)
+
+	// a claim moves the next attempt, so a second process that read
+	// the same delivery no longer matches it.
+	assert.Equal(bson.M{
+		"_id":                         d.ID,
+		notificationDeliveryStatusKey: NotificationPending,
+		notificationDeliveryNextKey:   now,
+	}, d.claimQuery())
+}
+
+func TestNotificationTargetDeliversToHTTPEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	received := []map[string]interface{}{}
+	status := http.StatusOK
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body)
+		w.WriteHeader(status)
+	}))
+	defer stub.Close()
+
+	e := &Event{ID: bson.NewObjectId(), Level: "error", Component: "sink", Message: "disk full"}
+
+	webhook := &NotificationTarget{Type: NotificationTargetWebhook, URL: stub.URL}
+	require.NoError(webhook.Deliver(e))
+	require.Len(received, 1)
+	assert.Equal("disk full", received[0]["message"])
+	assert.Equal(e.ID.Hex(), received[0]["id"])
+
+	slack := &NotificationTarget{Type: NotificationTargetSlack, URL: stub.URL}
+	require.NoError(slack.Deliver(e))
+	require.Len(received, 2)
+	assert.Equal("[error] sink: disk full", received[1]["text"])
+
+	status = http.StatusServiceUnavailable
+	assert.Error(webhook.Deliver(e))
+
+	stub.Close()
+	assert.Error(slack.Deliver(e))
+}
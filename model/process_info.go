@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/evergreen-ci/sink/procinfo"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const processInfoCollection = "sysinfo.processes"
+
+// processInfoRetention is how long process snapshots are kept.
+const processInfoRetention = 30 * 24 * time.Hour
+
+func init() {
+	registerIndexes(processInfoCollection,
+		mgo.Index{Key: []string{processInfoHostKey, processInfoTimestampKey}},
+		mgo.Index{Key: []string{processInfoTimestampKey}, ExpireAfter: processInfoRetention})
+}
+
+// ProcessSnapshotRecord holds a snapshot of the heaviest processes on a
+// host at one time.
+type ProcessSnapshotRecord struct {
+	ID        bson.ObjectId     `bson:"_id" json:"id"`
+	Timestamp time.Time         `bson:"ts" json:"time"`
+	Hostname  string            `bson:"hn" json:"hostname"`
+	Data      procinfo.Snapshot `bson:"snapshot" json:"snapshot"`
+	populated bool
+}
+
+var (
+	processInfoTimestampKey = bsonutil.MustHaveTag(ProcessSnapshotRecord{}, "Timestamp")
+	processInfoHostKey      = bsonutil.MustHaveTag(ProcessSnapshotRecord{}, "Hostname")
+)
+
+func (i *ProcessSnapshotRecord) Insert() error {
+	if i.ID == "" {
+		i.ID = bson.NewObjectId()
+	}
+
+	if i.Hostname == "" {
+		return errors.New("process snapshots must specify a hostname")
+	}
+
+	return errors.WithStack(db.Insert(processInfoCollection, i))
+}
+
+type ProcessSnapshotRecords struct {
+	slice     []*ProcessSnapshotRecord
+	populated bool
+}
+
+func (i *ProcessSnapshotRecords) IsNil() bool                     { return !i.populated }
+func (i *ProcessSnapshotRecords) Slice() []*ProcessSnapshotRecord { return i.slice }
+
+// FindHostnameBetween populates the records, in time order, with the
+// host's snapshots in the time range.
+func (i *ProcessSnapshotRecords) FindHostnameBetween(host string, start, end time.Time, limit int) error {
+	query := db.Query(bson.M{
+		processInfoHostKey: host,
+		processInfoTimestampKey: bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}).Sort(processInfoTimestampKey)
+
+	if limit > 0 {
+		query.Limit(limit)
+	}
+
+	i.populated = false
+	if err := query.FindAll(processInfoCollection, &i.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	i.populated = true
+
+	return nil
+}
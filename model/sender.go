@@ -1,28 +1,42 @@
 package model
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/evergreen-ci/sink/db"
 	"github.com/evergreen-ci/sink/db/bsonutil"
-	"github.com/mongodb/grip"
 	"github.com/mongodb/grip/message"
-	"github.com/mongodb/grip/send"
 	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// TODO add index on { level: 1, Timestamp: 1 }
 const eventCollection = "application.events"
 
+func init() {
+	registerIndexes(eventCollection,
+		mgo.Index{Key: []string{eventLevelKey, eventTimestampKey}},
+		mgo.Index{Key: []string{eventKeyKey}, Unique: true, Sparse: true},
+		mgo.Index{Key: []string{eventOpenedKey}},
+		mgo.Index{Key: []string{eventRecordedKey}})
+}
+
 ///////////////////////////////////////////////////////////////////////////
 //
 // Models for Handling Events; in db to avoid circular dependency on model
 
-// Event is a translation of
+// Event is a translation of a message.Composer sent to the system
+// sender. Repeated occurrences of the same message are collapsed into
+// one event: the Timestamp records when the event was first seen, and
+// the Message, Payload, and Level reflect the latest occurrence.
 type Event struct {
 	ID           bson.ObjectId `bson:"_id" json:"id"`
+	Key          string        `bson:"key,omitempty" json:"key,omitempty"`
 	Component    string        `bson:"com" json:"component"`
 	Message      string        `bson:"m" json:"message"`
 	Payload      interface{}   `bson:"data" json:"payload"`
@@ -30,39 +44,247 @@ type Event struct {
 	Timestamp    time.Time     `bson:"ts" json:"time"`
 	Level        string        `bson:"l" json:"level"`
 	Acknowledged bool          `bson:"ack" json:"acknowledged"`
+	Count        int           `bson:"n,omitempty" json:"count"`
+	LastSeen     time.Time     `bson:"last,omitempty" json:"last_seen"`
+	Opened       time.Time     `bson:"opened,omitempty" json:"opened"`
+	Samples      []EventSample `bson:"samples,omitempty" json:"samples,omitempty"`
+
+	// Recorded is the database's time when the event was last
+	// written, which, unlike LastSeen, advances when spooled events
+	// are stored late; see EventFollower.
+	Recorded time.Time `bson:"recorded,omitempty" json:"-"`
+
+	// State, SnoozedUntil, Assignee, and History track the event's
+	// lifecycle; see Transition and Assign.
+	State        string            `bson:"state,omitempty" json:"state"`
+	SnoozedUntil time.Time         `bson:"snooze,omitempty" json:"snoozed_until,omitempty"`
+	Assignee     string            `bson:"assignee,omitempty" json:"assignee,omitempty"`
+	History      []EventTransition `bson:"history,omitempty" json:"history,omitempty"`
 
 	populated bool
 }
 
+// EventSample records the message and payload of one occurrence of an
+// event.
+type EventSample struct {
+	Time    time.Time   `bson:"ts" json:"time"`
+	Message string      `bson:"m" json:"message"`
+	Payload interface{} `bson:"data" json:"payload"`
+}
+
+// eventMaxSamples is the number of recent occurrences kept with each
+// event.
+const eventMaxSamples = 10
+
 var (
-	eventIDKey          = bsonutil.MustHaveTag(Event{}, "ID")
-	eventComponentKey   = bsonutil.MustHaveTag(Event{}, "Component")
-	eventMessageKey     = bsonutil.MustHaveTag(Event{}, "Message")
-	eventPayloadKey     = bsonutil.MustHaveTag(Event{}, "Payload")
-	eventMessageTypeKey = bsonutil.MustHaveTag(Event{}, "MessageType")
-	eventTimestampKey   = bsonutil.MustHaveTag(Event{}, "Timestamp")
-	eventLevelKey       = bsonutil.MustHaveTag(Event{}, "Level")
+	eventIDKey           = bsonutil.MustHaveTag(Event{}, "ID")
+	eventComponentKey    = bsonutil.MustHaveTag(Event{}, "Component")
+	eventMessageKey      = bsonutil.MustHaveTag(Event{}, "Message")
+	eventPayloadKey      = bsonutil.MustHaveTag(Event{}, "Payload")
+	eventMessageTypeKey  = bsonutil.MustHaveTag(Event{}, "MessageType")
+	eventTimestampKey    = bsonutil.MustHaveTag(Event{}, "Timestamp")
+	eventLevelKey        = bsonutil.MustHaveTag(Event{}, "Level")
+	eventAcknowledgedKey = bsonutil.MustHaveTag(Event{}, "Acknowledged")
+	eventKeyKey          = bsonutil.MustHaveTag(Event{}, "Key")
+	eventCountKey        = bsonutil.MustHaveTag(Event{}, "Count")
+	eventLastSeenKey     = bsonutil.MustHaveTag(Event{}, "LastSeen")
+	eventOpenedKey       = bsonutil.MustHaveTag(Event{}, "Opened")
+	eventSamplesKey      = bsonutil.MustHaveTag(Event{}, "Samples")
+	eventRecordedKey     = bsonutil.MustHaveTag(Event{}, "Recorded")
 )
 
 func NewEvent(m message.Composer) *Event {
+	now := time.Now()
+
 	return &Event{
 		ID:          bson.NewObjectId(),
 		Message:     m.String(),
 		Payload:     m.Raw(),
 		MessageType: fmt.Sprintf("%T", m),
-		Timestamp:   time.Now(),
+		Timestamp:   now,
 		Level:       m.Priority().String(),
+		Count:       1,
+		LastSeen:    now,
+		Opened:      now,
 	}
 }
 
 func (e *Event) IsNil() bool   { return e.populated }
 func (e *Event) Insert() error { return errors.WithStack(db.Insert(eventCollection, e)) }
 
+var (
+	eventMessageIDPattern     = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b|\b[0-9a-fA-F]{24,}\b`)
+	eventMessageTokenPattern  = regexp.MustCompile(`[\w./+-]*[\w/]`)
+	eventMessageNumberPattern = regexp.MustCompile(`^[-+]?\d+(\.\d+)?([a-zA-Z]{0,3})$`)
+)
+
+// normalizeEventMessage replaces the parts of a message that vary
+// between occurrences of the same event, such as ids, counts, and
+// measurements, with placeholders. Only standalone numbers, which may
+// have a short unit suffix, are replaced: numbers within names, such
+// as hostnames, job names, and paths, identify different events.
+func normalizeEventMessage(msg string) string {
+	msg = eventMessageIDPattern.ReplaceAllString(msg, "<id>")
+	msg = eventMessageTokenPattern.ReplaceAllStringFunc(msg, func(token string) string {
+		match := eventMessageNumberPattern.FindStringSubmatch(token)
+		if match == nil {
+			return token
+		}
+
+		return "<n>" + match[2]
+	})
+
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// eventKey identifies the occurrences of an event by component,
+// message type, and normalized message.
+func eventKey(component, messageType, msg string) string {
+	sum := sha1.Sum([]byte(strings.Join([]string{component, messageType, normalizeEventMessage(msg)}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record stores the event, or, if an event with the same component,
+// message type, and normalized message exists, records another
+// occurrence of that event. A new occurrence reopens the event if it
+// was acknowledged or resolved, or if its snooze has ended.
+func (e *Event) Record() error {
+	_, err := recordEvents([]*Event{e})
+	return errors.WithStack(err)
+}
+
+// recordUpdates returns the updates that store the event as in Record.
+// The event's Count and Samples may describe several occurrences
+// collapsed into it; see collapseEvents.
+func (e *Event) recordUpdates() []db.BulkUpdate {
+	e.Key = eventKey(e.Component, e.MessageType, e.Message)
+
+	count := e.Count
+	if count < 1 {
+		count = 1
+	}
+
+	samples := e.Samples
+	if len(samples) == 0 {
+		samples = []EventSample{{Time: e.LastSeen, Message: e.Message, Payload: e.Payload}}
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			eventIDKey:           e.ID,
+			eventComponentKey:    e.Component,
+			eventMessageTypeKey:  e.MessageType,
+			eventTimestampKey:    e.Timestamp,
+			eventOpenedKey:       e.Opened,
+			eventAcknowledgedKey: false,
+			eventStateKey:        EventOpen,
+		},
+		"$set": bson.M{
+			eventMessageKey:  e.Message,
+			eventPayloadKey:  e.Payload,
+			eventLevelKey:    e.Level,
+			eventLastSeenKey: e.LastSeen,
+		},
+		"$inc":         bson.M{eventCountKey: count},
+		"$currentDate": bson.M{eventRecordedKey: true},
+		"$push": bson.M{eventSamplesKey: bson.M{
+			"$each":  samples,
+			"$slice": -eventMaxSamples,
+		}},
+	}
+
+	return append(e.reopenUpdates(), db.BulkUpdate{
+		Query:  bson.M{eventKeyKey: e.Key},
+		Update: update,
+		Upsert: true,
+	})
+}
+
+// collapseEvents combines the occurrences of each event into one
+// event, in order of each event's first occurrence, so that a batch
+// stores each event once.
+func collapseEvents(events []*Event) []*Event {
+	out := []*Event{}
+	byKey := map[string]*Event{}
+
+	for _, e := range events {
+		key := eventKey(e.Component, e.MessageType, e.Message)
+		sample := EventSample{Time: e.LastSeen, Message: e.Message, Payload: e.Payload}
+
+		group, ok := byKey[key]
+		if !ok {
+			group = &Event{}
+			*group = *e
+			group.Key = key
+			if len(group.Samples) == 0 {
+				group.Samples = []EventSample{sample}
+			}
+			if group.Count < 1 {
+				group.Count = 1
+			}
+
+			byKey[key] = group
+			out = append(out, group)
+			continue
+		}
+
+		group.Count += e.Count
+		group.Message = e.Message
+		group.Payload = e.Payload
+		group.Level = e.Level
+		group.LastSeen = e.LastSeen
+		group.Samples = append(group.Samples, sample)
+		if len(group.Samples) > eventMaxSamples {
+			group.Samples = group.Samples[len(group.Samples)-eventMaxSamples:]
+		}
+	}
+
+	return out
+}
+
+// recordEvents stores the events in one bulk write, collapsing
+// repeated occurrences first. On error, it returns the collapsed
+// events that may not have been stored. Storing these events again
+// can count some occurrences twice; see db.RunBulk.
+func recordEvents(events []*Event) ([]*Event, error) {
+	groups := collapseEvents(events)
+
+	for attempt := 0; ; attempt++ {
+		updates := []db.BulkUpdate{}
+		// ends[i] is the number of updates through the ith event.
+		ends := make([]int, len(groups))
+		for idx, e := range groups {
+			updates = append(updates, e.recordUpdates()...)
+			ends[idx] = len(updates)
+		}
+
+		applied, err := db.RunBulk(eventCollection, updates)
+		if err == nil {
+			return nil, nil
+		}
+
+		stored := 0
+		for stored < len(ends) && ends[stored] <= applied {
+			stored++
+		}
+		groups = groups[stored:]
+
+		// concurrent upserts of a new event can collide on the
+		// unique index on the key, in which case the event now
+		// exists and the retry updates it.
+		if attempt > 0 || !mgo.IsDup(errors.Cause(err)) {
+			return groups, errors.WithStack(err)
+		}
+	}
+}
+
 func (e *Event) FindID(id string) error {
-	oid := bson.ObjectIdHex(id)
+	if !bson.IsObjectIdHex(id) {
+		return errors.Errorf("'%s' is not a valid event id", id)
+	}
 
 	query := db.Query(bson.M{
-		eventIDKey: oid,
+		eventIDKey: bson.ObjectIdHex(id),
 	})
 
 	e.populated = false
@@ -74,12 +296,6 @@ func (e *Event) FindID(id string) error {
 	return nil
 }
 
-func (e *Event) Acknowledge() error {
-	e.Acknowledged = true
-
-	return db.UpdateID(eventCollection, e.ID, e)
-}
-
 type Events struct {
 	slice     []*Event
 	populated bool
@@ -96,7 +312,7 @@ func (e *Events) FindLevel(level string, limit int) error {
 	}
 
 	e.populated = false
-	if err := query.FindAll(eventCollection, e.slice); err != nil {
+	if err := query.FindAll(eventCollection, &e.slice); err != nil {
 		return errors.WithStack(err)
 	}
 	e.populated = true
@@ -111,32 +327,3 @@ func (e *Events) CountLevel(level string) (int, error) {
 func (e *Events) Count() (int, error) {
 	return db.Query(bson.M{}).Count(eventCollection)
 }
-
-////////////////////////////////////////
-//
-// Implementation of send.Sender
-
-type mongoDBSender struct{ *send.Base }
-
-func MakeDBSender() (send.Sender, error) { return NewDBSender("") }
-
-func NewDBSender(name string) (send.Sender, error) {
-	s := &mongoDBSender{send.NewBase(name)}
-
-	err := s.SetErrorHandler(send.ErrorHandlerFromSender(grip.GetSender()))
-	if err != nil {
-		return nil, errors.Wrap(err, "problem getting default sender")
-	}
-
-	return s, nil
-}
-
-func (s *mongoDBSender) Send(m message.Composer) {
-	if s.Level().ShouldLog(m) {
-		e := NewEvent(m)
-		e.Component = s.Name()
-		if err := e.Insert(); err != nil {
-			s.ErrorHandler(err, m)
-		}
-	}
-}
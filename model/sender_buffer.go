@@ -0,0 +1,344 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/mongodb/grip/send"
+	"github.com/pkg/errors"
+)
+
+////////////////////////////////////////
+//
+// Implementation of send.Sender
+
+// DBSenderOptions configure how the DB sender buffers events. Zero
+// values use the defaults.
+type DBSenderOptions struct {
+	// BufferSize is the number of messages that may wait to be
+	// stored. When the buffer is full, the sender drops messages
+	// rather than block the caller.
+	BufferSize int
+
+	// BatchSize is the number of waiting messages that triggers a
+	// flush.
+	BatchSize int
+
+	// FlushInterval is the longest that a message waits to be
+	// stored.
+	FlushInterval time.Duration
+
+	// SpoolPath is a file that holds events that could not be
+	// stored, which later flushes retry. Spooled events are stored
+	// at least once: if a write fails partway, the events it stored
+	// may be counted again when they are replayed. Processes lock
+	// the spool while they change it, so several processes may
+	// share one file. When it is empty, events that could not be
+	// stored are dropped.
+	SpoolPath string
+}
+
+const (
+	defaultDBSenderBufferSize    = 10000
+	defaultDBSenderBatchSize     = 100
+	defaultDBSenderFlushInterval = time.Second
+)
+
+func (o *DBSenderOptions) setDefaults() {
+	if o.BufferSize <= 0 {
+		o.BufferSize = defaultDBSenderBufferSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = defaultDBSenderBatchSize
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = defaultDBSenderFlushInterval
+	}
+}
+
+// mongoDBSender records the messages it receives as events. Send
+// only queues messages; a background goroutine stores them in batches,
+// so logging never waits on the database.
+type mongoDBSender struct {
+	*send.Base
+	opts DBSenderOptions
+
+	mu      sync.RWMutex
+	closed  bool
+	pipe    chan *Event
+	done    chan struct{}
+	dropped int64
+}
+
+func MakeDBSender() (send.Sender, error) { return NewDBSender("") }
+
+func NewDBSender(name string) (send.Sender, error) {
+	return NewBufferedDBSender(name, DBSenderOptions{})
+}
+
+// NewBufferedDBSender returns a sender that records messages as
+// events, buffering and batching them as the options specify, and
+// creates the directory for the spool if it does not exist. Close the
+// sender to store the events that are still buffered.
+func NewBufferedDBSender(name string, opts DBSenderOptions) (send.Sender, error) {
+	opts.setDefaults()
+
+	if opts.SpoolPath != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.SpoolPath), 0700); err != nil {
+			return nil, errors.Wrap(err, "problem creating spool directory")
+		}
+	}
+
+	s := &mongoDBSender{
+		opts: opts,
+		pipe: make(chan *Event, opts.BufferSize),
+		done: make(chan struct{}),
+	}
+	s.Base = send.MakeBase(name, func() {}, s.close)
+
+	err := s.SetErrorHandler(send.ErrorHandlerFromSender(grip.GetSender()))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem getting default sender")
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *mongoDBSender) Send(m message.Composer) {
+	if !s.Level().ShouldLog(m) {
+		return
+	}
+
+	e := NewEvent(m)
+	e.Component = s.Name()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.ErrorHandler(errors.New("cannot record event on a closed sender"), m)
+		return
+	}
+
+	select {
+	case s.pipe <- e:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// close stops accepting messages and waits for the buffered events to
+// be stored or spooled.
+func (s *mongoDBSender) close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.pipe)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+
+	return nil
+}
+
+func (s *mongoDBSender) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, s.opts.BatchSize)
+	for {
+		select {
+		case e, ok := <-s.pipe:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+
+			batch = append(batch, e)
+			if len(batch) >= s.opts.BatchSize {
+				s.flush(batch)
+				batch = make([]*Event, 0, s.opts.BatchSize)
+			}
+		case <-ticker.C:
+			if s.flush(batch) {
+				s.replaySpool()
+			}
+			batch = make([]*Event, 0, s.opts.BatchSize)
+			s.reportDropped()
+		}
+	}
+}
+
+// flush stores the batch, spooling the events that could not be
+// stored, and returns false if the database is unavailable.
+func (s *mongoDBSender) flush(batch []*Event) bool {
+	if len(batch) == 0 {
+		return true
+	}
+
+	failed, err := recordEvents(batch)
+	if err != nil {
+		s.ErrorHandler(errors.Wrapf(err, "problem storing %d events", len(failed)),
+			message.NewString("flushing buffered events"))
+		s.spool(failed)
+		return false
+	}
+
+	return true
+}
+
+func (s *mongoDBSender) reportDropped() {
+	if dropped := atomic.SwapInt64(&s.dropped, 0); dropped > 0 {
+		s.ErrorHandler(errors.Errorf("event buffer is full; dropped %d events", dropped),
+			message.NewString("buffering events"))
+	}
+}
+
+// spool appends the events to the spool file, one JSON document per
+// line.
+func (s *mongoDBSender) spool(events []*Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	if s.opts.SpoolPath == "" {
+		s.ErrorHandler(errors.Errorf("no spool file configured; dropped %d events", len(events)),
+			message.NewString("spooling events"))
+		return
+	}
+
+	unlock, err := lockSpool(s.opts.SpoolPath)
+	if err != nil {
+		s.ErrorHandler(errors.Wrapf(err, "problem spooling %d events", len(events)),
+			message.NewString("spooling events"))
+		return
+	}
+
+	catcher := grip.NewCatcher()
+	catcher.Add(writeSpool(s.opts.SpoolPath, events, os.O_APPEND))
+	catcher.Add(unlock())
+	if catcher.HasErrors() {
+		s.ErrorHandler(errors.Wrapf(catcher.Resolve(), "problem spooling %d events", len(events)),
+			message.NewString("spooling events"))
+	}
+}
+
+// replaySpool stores the spooled events, and rewrites the spool with
+// the events that could not be stored. Event streams follow the time
+// that events are stored, so they receive the replayed events.
+func (s *mongoDBSender) replaySpool() {
+	if s.opts.SpoolPath == "" {
+		return
+	}
+
+	// there is nothing to replay until events are spooled, which
+	// creates the spool.
+	if _, err := os.Stat(s.opts.SpoolPath); os.IsNotExist(err) {
+		return
+	}
+
+	// another process could append to the spool between reading and
+	// rewriting it, losing the appended events.
+	unlock, err := lockSpool(s.opts.SpoolPath)
+	if err != nil {
+		s.ErrorHandler(errors.Wrap(err, "problem replaying spooled events"),
+			message.NewString("replaying spooled events"))
+		return
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			s.ErrorHandler(errors.Wrap(err, "problem unlocking spooled events"),
+				message.NewString("replaying spooled events"))
+		}
+	}()
+
+	events, err := readSpool(s.opts.SpoolPath)
+	if err != nil {
+		s.ErrorHandler(errors.Wrap(err, "problem reading spooled events"),
+			message.NewString("replaying spooled events"))
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	for len(events) > 0 {
+		n := s.opts.BatchSize
+		if n > len(events) {
+			n = len(events)
+		}
+
+		failed, err := recordEvents(events[:n])
+		if err != nil {
+			events = append(failed, events[n:]...)
+			if err = writeSpool(s.opts.SpoolPath, events, os.O_TRUNC); err != nil {
+				s.ErrorHandler(errors.Wrap(err, "problem rewriting spooled events"),
+					message.NewString("replaying spooled events"))
+			}
+			return
+		}
+
+		events = events[n:]
+	}
+
+	if err = os.Remove(s.opts.SpoolPath); err != nil && !os.IsNotExist(err) {
+		s.ErrorHandler(errors.Wrap(err, "problem removing spooled events"),
+			message.NewString("replaying spooled events"))
+	}
+}
+
+func writeSpool(path string, events []*Event, mode int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|mode, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	enc := json.NewEncoder(f)
+	catcher := grip.NewCatcher()
+	for _, e := range events {
+		catcher.Add(enc.Encode(e))
+	}
+	catcher.Add(f.Close())
+
+	return errors.WithStack(catcher.Resolve())
+}
+
+func readSpool(path string) ([]*Event, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	out := []*Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		e := &Event{}
+		if err = json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, errors.Wrap(err, "problem parsing spooled event")
+		}
+		out = append(out, e)
+	}
+
+	return out, errors.WithStack(scanner.Err())
+}
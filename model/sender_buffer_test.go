@@ -0,0 +1,169 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/mongodb/grip/send"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollapseEventsCombinesOccurrences(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	occurrence := func(msg string, offset time.Duration) *Event {
+		e := NewEvent(message.NewString(msg))
+		e.Component = "sink"
+		e.LastSeen = now.Add(offset)
+		return e
+	}
+
+	events := []*Event{
+		occurrence("job 1 failed", 0),
+		occurrence("disk full", time.Second),
+		occurrence("job 2 failed", 2*time.Second),
+	}
+	for i := 0; i < eventMaxSamples-1; i++ {
+		events = append(events, occurrence("job 3 failed", 3*time.Second))
+	}
+
+	out := collapseEvents(events)
+	assert.Len(out, 2)
+
+	job := out[0]
+	assert.Equal(eventMaxSamples+1, job.Count)
+	assert.Equal("job 3 failed", job.Message)
+	assert.Equal(events[0].ID, job.ID)
+	assert.Equal(now.Add(3*time.Second), job.LastSeen)
+	assert.Len(job.Samples, eventMaxSamples)
+	assert.Equal("job 2 failed", job.Samples[0].Message)
+
+	assert.Equal(1, out[1].Count)
+	assert.Equal("disk full", out[1].Message)
+
+	// the input events are unchanged.
+	assert.Equal("job 1 failed", events[0].Message)
+	assert.Empty(events[0].Samples)
+}
+
+func TestEventSpoolRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "sink-spool")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.spool")
+
+	out, err := readSpool(path)
+	assert.NoError(err)
+	assert.Empty(out)
+
+	one := NewEvent(message.NewString("one"))
+	two := NewEvent(message.NewString("two"))
+	require.NoError(writeSpool(path, []*Event{one}, os.O_APPEND))
+	require.NoError(writeSpool(path, []*Event{two}, os.O_APPEND))
+
+	out, err = readSpool(path)
+	require.NoError(err)
+	require.Len(out, 2)
+	assert.Equal(one.ID, out[0].ID)
+	assert.Equal("two", out[1].Message)
+	assert.True(two.Timestamp.Equal(out[1].Timestamp))
+
+	require.NoError(writeSpool(path, []*Event{two}, os.O_TRUNC))
+	out, err = readSpool(path)
+	require.NoError(err)
+	require.Len(out, 1)
+	assert.Equal(two.ID, out[0].ID)
+}
+
+func TestEventSpoolLockExcludesOtherHolders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("spools are not locked on windows")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "sink-spool")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.spool")
+
+	unlock, err := lockSpool(path)
+	require.NoError(err)
+
+	locked := make(chan func() error)
+	go func() {
+		second, err := lockSpool(path)
+		assert.NoError(err)
+		locked <- second
+	}()
+
+	select {
+	case <-locked:
+		assert.Fail("acquired a held spool lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(unlock())
+	second := <-locked
+	assert.NoError(second())
+}
+
+func TestBufferedDBSenderSpoolsWithoutDatabase(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "sink-spool")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// on a new host, the spool's directory does not exist yet.
+	path := filepath.Join(dir, "missing", "events.spool")
+
+	sender, err := NewBufferedDBSender("test", DBSenderOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		SpoolPath:     path,
+	})
+	require.NoError(err)
+	require.NoError(sender.SetLevel(send.LevelInfo{Default: level.Info, Threshold: level.Info}))
+
+	info, err := os.Stat(filepath.Dir(path))
+	require.NoError(err)
+	assert.True(info.IsDir())
+
+	// replaying before anything is spooled does nothing, and does not
+	// take the lock.
+	errs := 0
+	require.NoError(sender.SetErrorHandler(func(error, message.Composer) { errs++ }))
+	sender.(*mongoDBSender).replaySpool()
+	assert.Equal(0, errs)
+	_, err = os.Stat(path + ".lock")
+	assert.True(os.IsNotExist(err))
+
+	for _, msg := range []string{"one", "two", "three"} {
+		sender.Send(message.NewDefaultMessage(level.Alert, msg))
+	}
+
+	// the sender has no database session, so closing it spools the
+	// events rather than losing them.
+	require.NoError(sender.Close())
+	sender.Send(message.NewDefaultMessage(level.Alert, "after close"))
+
+	out, err := readSpool(path)
+	require.NoError(err)
+	require.Len(out, 3)
+	assert.Equal("test", out[0].Component)
+	assert.Equal("three", out[2].Message)
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockSpool takes an exclusive lock on the spool, which processes that
+// share a spool file hold while they change it, and returns a function
+// that releases the lock. It creates the spool's directory, which may
+// have been removed since the sender started.
+func lockSpool(path string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "problem creating spool directory")
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem opening spool lock")
+	}
+
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "problem locking spool")
+	}
+
+	return func() error {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		return errors.WithStack(err)
+	}, nil
+}
@@ -0,0 +1,7 @@
+package model
+
+// lockSpool does not lock the spool on Windows, so processes must not
+// share a spool file.
+func lockSpool(path string) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -3,20 +3,18 @@ package model
 import (
 	"time"
 
-	"github.com/evergreen-ci/sink/db"
 	"github.com/evergreen-ci/sink/db/bsonutil"
 	"github.com/mongodb/grip/message"
 	"github.com/pkg/errors"
 	"gopkg.in/mgo.v2/bson"
 )
 
-const sysInfoCollection = "sysinfo.stats"
-
 type SystemInformationRecord struct {
 	ID        bson.ObjectId      `bson:"_id" json:"id"`
 	Timestamp time.Time          `bson:"ts" json:"time"`
 	Data      message.SystemInfo `bson:"sysinfo" json:"sysinfo"`
 	Hostname  string             `bson:"hn" json:"hostname"`
+	Labels    map[string]string  `bson:"labels,omitempty" json:"labels,omitempty"`
 	populated bool
 }
 
@@ -25,6 +23,7 @@ var (
 	sysInfoTimestampKey = bsonutil.MustHaveTag(SystemInformationRecord{}, "Timestamp")
 	sysInfoDataKey      = bsonutil.MustHaveTag(SystemInformationRecord{}, "Data")
 	sysInfoHostKey      = bsonutil.MustHaveTag(SystemInformationRecord{}, "Hostname")
+	sysInfoLabelsKey    = bsonutil.MustHaveTag(SystemInformationRecord{}, "Labels")
 )
 
 func (i *SystemInformationRecord) Insert() error {
@@ -32,21 +31,37 @@ func (i *SystemInformationRecord) Insert() error {
 		i.ID = bson.NewObjectId()
 	}
 
-	return errors.WithStack(db.Insert(sysInfoCollection, i))
+	if err := ValidateSystemInfoLabels(i.Labels); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(addToBucket(i))
 }
 
 func (i *SystemInformationRecord) FindID(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return errors.Errorf("'%s' is not a valid system information record id", id)
+	}
 	oid := bson.ObjectIdHex(id)
 
-	query := db.Query(bson.M{
-		sysInfoIDKey: oid,
-	})
+	out := []*SystemInformationRecord{}
+	filter := bson.M{bsonutil.GetDottedKeyName(sysInfoBucketSamplesKey, sysInfoIDKey): oid}
+	pipeline := []bson.M{
+		{"$match": bson.M{sysInfoIDKey: oid}},
+		{"$limit": 1},
+	}
 
 	i.populated = false
-	if err := query.FindOne(sysInfoCollection, i); err != nil {
+	if err := aggregateSysInfo(filter, pipeline, &out); err != nil {
 		return errors.WithStack(err)
 	}
 
+	if len(out) == 0 {
+		return errors.Errorf("could not find system information record '%s'", id)
+	}
+	*i = *out[0]
+	i.populated = true
+
 	return nil
 }
 
@@ -58,9 +73,16 @@ type SystemInformationRecords struct {
 func (i *SystemInformationRecords) IsNil() bool                       { return i.populated }
 func (i *SystemInformationRecords) Slice() []*SystemInformationRecord { return i.slice }
 
-func (i *SystemInformationRecords) runQuery(query *db.Q) error {
+// runQuery populates the records with the samples, from the buckets
+// that match the bucket filter, that also match the query.
+func (i *SystemInformationRecords) runQuery(bucketFilter, query bson.M, limit int) error {
+	pipeline := []bson.M{{"$match": query}}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
+	}
+
 	i.populated = false
-	if err := query.FindAll(sysInfoCollection, i.slice); err != nil {
+	if err := aggregateSysInfo(bucketFilter, pipeline, &i.slice); err != nil {
 		return errors.WithStack(err)
 	}
 	i.populated = true
@@ -69,68 +91,104 @@ func (i *SystemInformationRecords) runQuery(query *db.Q) error {
 }
 
 func (i *SystemInformationRecords) FindHostname(host string, limit int) error {
-	query := db.Query(bson.M{
+	query := bson.M{
 		sysInfoHostKey: host,
-	})
-
-	if limit > 0 {
-		query.Limit(limit)
 	}
 
-	return errors.WithStack(i.runQuery(query))
+	return errors.WithStack(i.runQuery(sysInfoBucketFilter(host, time.Time{}, time.Time{}), query, limit))
 }
 
-func (i *SystemInformationRecords) FindHostnameBetween(host string, before, after time.Time, limit int) error {
-	query := db.Query(bson.M{
-		sysInfoHostKey: host,
+// sysInfoBetweenQuery selects the samples from the host, or from all
+// hosts if the host is empty, recorded at or after the start and
+// before the end.
+func sysInfoBetweenQuery(host string, start, end time.Time) bson.M {
+	query := bson.M{
 		sysInfoTimestampKey: bson.M{
-			"$lt": before,
-			"$gt": after,
+			"$gte": start,
+			"$lt":  end,
 		},
-	})
+	}
 
-	if limit > 0 {
-		query.Limit(limit)
+	if host != "" {
+		query[sysInfoHostKey] = host
 	}
 
-	return errors.WithStack(i.runQuery(query))
+	return query
 }
 
-func (i *SystemInformationRecords) FindBetween(before, after time.Time, limit int) error {
-	query := db.Query(bson.M{
-		sysInfoTimestampKey: bson.M{
-			"$lt": before,
-			"$gt": after,
-		},
-	})
+// FindHostnameBetween populates the records with the samples from the
+// host recorded at or after the start and before the end.
+func (i *SystemInformationRecords) FindHostnameBetween(host string, start, end time.Time, limit int) error {
+	return errors.WithStack(i.runQuery(sysInfoBucketFilter(host, start, end), sysInfoBetweenQuery(host, start, end), limit))
+}
 
-	if limit > 0 {
-		query.Limit(limit)
+// FindBetween populates the records with the samples from all hosts
+// recorded at or after the start and before the end.
+func (i *SystemInformationRecords) FindBetween(start, end time.Time, limit int) error {
+	return errors.WithStack(i.runQuery(sysInfoBucketFilter("", start, end), sysInfoBetweenQuery("", start, end), limit))
+}
+
+// FindLatestPerHost populates the records with the most recent
+// document for every host that has reported since the specified time.
+func (i *SystemInformationRecords) FindLatestPerHost(since time.Time) error {
+	pipeline := []bson.M{
+		{"$match": bson.M{sysInfoTimestampKey: bson.M{"$gt": since}}},
+		{"$sort": bson.M{sysInfoTimestampKey: -1}},
+		{"$group": bson.M{
+			"_id": "$" + sysInfoHostKey,
+			"doc": bson.M{"$first": "$$ROOT"},
+		}},
+		{"$replaceRoot": bson.M{"newRoot": "$doc"}},
+		{"$sort": bson.M{sysInfoHostKey: 1}},
+	}
+
+	i.populated = false
+	if err := aggregateSysInfo(sysInfoBucketFilter("", since, time.Time{}), pipeline, &i.slice); err != nil {
+		return errors.WithStack(err)
 	}
+	i.populated = true
 
-	return errors.WithStack(i.runQuery(query))
+	return nil
 }
 
-func (i *SystemInformationRecords) CountBetween(before, after time.Time) (int, error) {
-	query := db.Query(bson.M{
-		sysInfoTimestampKey: bson.M{
-			"$lt": before,
-			"$gt": after,
-		},
-	})
+// countSamples returns the number of samples, from the buckets that
+// match the bucket filter, that also match the query.
+func countSamples(bucketFilter, query bson.M) (int, error) {
+	out := []struct {
+		Count int `bson:"count"`
+	}{}
+
+	pipeline := []bson.M{
+		{"$match": query},
+		{"$group": bson.M{"_id": nil, "count": bson.M{"$sum": 1}}},
+	}
+
+	if err := aggregateSysInfo(bucketFilter, pipeline, &out); err != nil {
+		return 0, errors.WithStack(err)
+	}
 
-	c, err := query.Count(sysInfoCollection)
+	if len(out) == 0 {
+		return 0, nil
+	}
+
+	return out[0].Count, nil
+}
+
+// CountBetween returns the number of samples from all hosts recorded
+// at or after the start and before the end.
+func (i *SystemInformationRecords) CountBetween(start, end time.Time) (int, error) {
+	c, err := countSamples(sysInfoBucketFilter("", start, end), sysInfoBetweenQuery("", start, end))
 	err = errors.WithStack(err)
 
 	return c, err
 }
 
 func (i *SystemInformationRecords) CountHostname(host string) (int, error) {
-	query := db.Query(bson.M{
+	query := bson.M{
 		sysInfoHostKey: host,
-	})
+	}
 
-	c, err := query.Count(sysInfoCollection)
+	c, err := countSamples(sysInfoBucketFilter(host, time.Time{}, time.Time{}), query)
 	err = errors.WithStack(err)
 
 	return c, err
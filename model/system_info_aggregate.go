@@ -0,0 +1,250 @@
+package model
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Metrics used to rank hosts in aggregate system information queries.
+const (
+	// SystemInfoMetricMemory ranks hosts by their peak memory
+	// utilization, as a percentage of total memory.
+	SystemInfoMetricMemory = "memory"
+
+	// SystemInfoMetricCPU ranks hosts by their CPU utilization
+	// over the window, as a percentage of total CPU time.
+	SystemInfoMetricCPU = "cpu"
+
+	// SystemInfoMetricDisk ranks hosts by the lowest amount of
+	// free space, in bytes, on any of their partitions.
+	SystemInfoMetricDisk = "disk"
+)
+
+var (
+	sysInfoCPUKey           = bsonutil.MustHaveTag(message.SystemInfo{}, "CPU")
+	sysInfoVMStatKey        = bsonutil.MustHaveTag(message.SystemInfo{}, "VMStat")
+	cpuIdleKey              = bsonutil.MustHaveTag(message.SystemInfo{}.CPU, "Idle")
+	vmStatUsedPercentKey    = bsonutil.MustHaveTag(message.SystemInfo{}.VMStat, "UsedPercent")
+	diskUsagePathKey        = bsonutil.MustHaveTag(diskUsageElem(), "Path")
+	diskUsageFreeKey        = bsonutil.MustHaveTag(diskUsageElem(), "Free")
+	cpuTimesAccountedFields = []string{"User", "System", "Idle", "Nice", "Iowait", "Irq", "Softirq", "Steal"}
+)
+
+// diskUsageElem returns a zero value of the element type of the
+// system information usage slice, for looking up its bson tags.
+func diskUsageElem() interface{} {
+	return reflect.Zero(reflect.TypeOf(message.SystemInfo{}.Usage).Elem()).Interface()
+}
+
+// HostMetricSummary holds the result of an aggregate query for a
+// single host.
+type HostMetricSummary struct {
+	Hostname   string  `bson:"_id" json:"host"`
+	Value      float64 `bson:"value" json:"value"`
+	Samples    int     `bson:"samples,omitempty" json:"samples,omitempty"`
+	Mountpoint string  `bson:"path,omitempty" json:"mountpoint,omitempty"`
+}
+
+// HostMetricSummaries runs aggregations that rank all hosts that
+// reported system information within a time window.
+type HostMetricSummaries struct {
+	slice     []*HostMetricSummary
+	populated bool
+}
+
+func (s *HostMetricSummaries) Slice() []*HostMetricSummary { return s.slice }
+func (s *HostMetricSummaries) IsNil() bool                 { return !s.populated }
+
+// FindTop populates the summaries with at most limit hosts that rank
+// highest for the metric between the start and end times.
+func (s *HostMetricSummaries) FindTop(metric string, start, end time.Time, limit int) error {
+	var pipeline []bson.M
+
+	match := bson.M{"$match": bson.M{
+		sysInfoTimestampKey: bson.M{"$gte": start, "$lt": end},
+	}}
+
+	switch metric {
+	case SystemInfoMetricMemory:
+		pipeline = append([]bson.M{match}, memoryPeakStages("$"+sysInfoHostKey)...)
+	case SystemInfoMetricCPU:
+		pipeline = append([]bson.M{match}, cpuUtilizationStages("$"+sysInfoHostKey)...)
+	case SystemInfoMetricDisk:
+		pipeline = append([]bson.M{match}, diskFreeStages("$"+sysInfoHostKey)...)
+	default:
+		return errors.Errorf("'%s' is not a supported metric", metric)
+	}
+
+	if limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
+	}
+
+	s.populated = false
+	if err := aggregateSysInfo(sysInfoBucketFilter("", start, end), pipeline, &s.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	s.populated = true
+
+	return nil
+}
+
+func sysInfoField(keys ...string) string {
+	return "$" + bsonutil.GetDottedKeyName(append([]string{sysInfoDataKey}, keys...)...)
+}
+
+// memoryPeakStages groups samples by the group ID and ranks groups by
+// the highest memory utilization.
+func memoryPeakStages(groupID interface{}) []bson.M {
+	return []bson.M{
+		{"$group": bson.M{
+			"_id":     groupID,
+			"value":   bson.M{"$max": sysInfoField(sysInfoVMStatKey, vmStatUsedPercentKey)},
+			"samples": bson.M{"$sum": 1},
+		}},
+		{"$sort": bson.M{"value": -1}},
+	}
+}
+
+// cpuUtilizationStages groups samples by the group ID and ranks groups
+// by CPU utilization, computed from the difference between the
+// cumulative CPU times in the first and last samples of each group.
+func cpuUtilizationStages(groupID interface{}) []bson.M {
+	total := func(prefix string) bson.M {
+		fields := []interface{}{}
+		for _, f := range cpuTimesAccountedFields {
+			fields = append(fields, "$"+bsonutil.GetDottedKeyName(prefix,
+				bsonutil.MustHaveTag(message.SystemInfo{}.CPU, f)))
+		}
+
+		return bson.M{"$add": fields}
+	}
+
+	return []bson.M{
+		{"$sort": bson.M{sysInfoTimestampKey: 1}},
+		{"$group": bson.M{
+			"_id":     groupID,
+			"first":   bson.M{"$first": sysInfoField(sysInfoCPUKey)},
+			"last":    bson.M{"$last": sysInfoField(sysInfoCPUKey)},
+			"samples": bson.M{"$sum": 1},
+		}},
+		{"$project": bson.M{
+			"samples": 1,
+			"total":   bson.M{"$subtract": []interface{}{total("last"), total("first")}},
+			"idle": bson.M{"$subtract": []interface{}{
+				"$" + bsonutil.GetDottedKeyName("last", cpuIdleKey),
+				"$" + bsonutil.GetDottedKeyName("first", cpuIdleKey),
+			}},
+		}},
+		{"$project": bson.M{
+			"samples": 1,
+			"value": bson.M{"$cond": []interface{}{
+				bson.M{"$gt": []interface{}{"$total", 0}},
+				bson.M{"$multiply": []interface{}{100,
+					bson.M{"$divide": []interface{}{
+						bson.M{"$subtract": []interface{}{"$total", "$idle"}},
+						"$total",
+					}},
+				}},
+				0,
+			}},
+		}},
+		{"$sort": bson.M{"value": -1}},
+	}
+}
+
+// diskFreeStages groups samples by the group ID and ranks groups by
+// the least free space observed on any partition.
+func diskFreeStages(groupID interface{}) []bson.M {
+	return []bson.M{
+		{"$unwind": sysInfoField(sysInfoUsageKey)},
+		{"$sort": bson.M{bsonutil.GetDottedKeyName(sysInfoDataKey, sysInfoUsageKey, diskUsageFreeKey): 1}},
+		{"$group": bson.M{
+			"_id":   groupID,
+			"value": bson.M{"$first": sysInfoField(sysInfoUsageKey, diskUsageFreeKey)},
+			"path":  bson.M{"$first": sysInfoField(sysInfoUsageKey, diskUsagePathKey)},
+		}},
+		{"$sort": bson.M{"value": 1}},
+	}
+}
+
+// LabelMetricSummary holds the result of an aggregate query for all
+// hosts that share a value for a label. The metric is first computed
+// for each host, as for HostMetricSummary, and then summarized across
+// the hosts in the group.
+type LabelMetricSummary struct {
+	LabelValue string  `bson:"_id" json:"value"`
+	Hosts      int     `bson:"hosts" json:"hosts"`
+	Average    float64 `bson:"avg" json:"average"`
+	Minimum    float64 `bson:"min" json:"minimum"`
+	Maximum    float64 `bson:"max" json:"maximum"`
+}
+
+// LabelMetricSummaries runs aggregations that compare groups of hosts
+// that share a label value within a time window.
+type LabelMetricSummaries struct {
+	slice     []*LabelMetricSummary
+	populated bool
+}
+
+func (s *LabelMetricSummaries) Slice() []*LabelMetricSummary { return s.slice }
+func (s *LabelMetricSummaries) IsNil() bool                  { return !s.populated }
+
+// FindGrouped populates the summaries with one entry for each value of
+// the label reported between the start and end times, ordered by
+// value. Records without the label are ignored; records must also
+// match all of the labels in the filter, if specified.
+func (s *LabelMetricSummaries) FindGrouped(label, metric string, filter map[string]string, start, end time.Time) error {
+	if err := ValidateSystemInfoLabels(map[string]string{label: ""}); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ValidateSystemInfoLabels(filter); err != nil {
+		return errors.WithStack(err)
+	}
+
+	labelKey := bsonutil.GetDottedKeyName(sysInfoLabelsKey, label)
+	query := bson.M{
+		sysInfoTimestampKey: bson.M{"$gte": start, "$lt": end},
+		labelKey:            bson.M{"$exists": true},
+	}
+	for k, v := range filter {
+		query[bsonutil.GetDottedKeyName(sysInfoLabelsKey, k)] = v
+	}
+
+	groupID := bson.M{"host": "$" + sysInfoHostKey, "label": "$" + labelKey}
+	pipeline := []bson.M{{"$match": query}}
+
+	switch metric {
+	case SystemInfoMetricMemory:
+		pipeline = append(pipeline, memoryPeakStages(groupID)...)
+	case SystemInfoMetricCPU:
+		pipeline = append(pipeline, cpuUtilizationStages(groupID)...)
+	case SystemInfoMetricDisk:
+		pipeline = append(pipeline, diskFreeStages(groupID)...)
+	default:
+		return errors.Errorf("'%s' is not a supported metric", metric)
+	}
+
+	pipeline = append(pipeline,
+		bson.M{"$group": bson.M{
+			"_id":   "$_id.label",
+			"hosts": bson.M{"$sum": 1},
+			"avg":   bson.M{"$avg": "$value"},
+			"min":   bson.M{"$min": "$value"},
+			"max":   bson.M{"$max": "$value"},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	)
+
+	s.populated = false
+	if err := aggregateSysInfo(sysInfoBucketFilter("", start, end), pipeline, &s.slice); err != nil {
+		return errors.WithStack(err)
+	}
+	s.populated = true
+
+	return nil
+}
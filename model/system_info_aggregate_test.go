@@ -0,0 +1,34 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostMetricSummariesRejectsUnknownMetric(t *testing.T) {
+	assert := assert.New(t)
+
+	summaries := &HostMetricSummaries{}
+	assert.Error(summaries.FindTop("swap", time.Now().Add(-time.Hour), time.Now(), 10))
+	assert.True(summaries.IsNil())
+}
+
+func TestDiskFreeStagesUsePartitionFields(t *testing.T) {
+	assert := assert.New(t)
+
+	stages := diskFreeStages("$" + sysInfoHostKey)
+	assert.Equal(sysInfoField(sysInfoUsageKey), stages[0]["$unwind"])
+	assert.Equal("$sysinfo.usage.free", sysInfoField(sysInfoUsageKey, diskUsageFreeKey))
+}
+
+func TestLabelMetricSummariesRejectsInvalidLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	summaries := &LabelMetricSummaries{}
+	assert.Error(summaries.FindGrouped("distro.name", SystemInfoMetricCPU, nil, time.Now().Add(-time.Hour), time.Now()))
+	assert.Error(summaries.FindGrouped("distro", SystemInfoMetricCPU, map[string]string{"$where": "x"}, time.Now().Add(-time.Hour), time.Now()))
+	assert.Error(summaries.FindGrouped("distro", "swap", nil, time.Now().Add(-time.Hour), time.Now()))
+	assert.True(summaries.IsNil())
+}
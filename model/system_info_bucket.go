@@ -0,0 +1,244 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/evergreen-ci/sink/db"
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// System information samples are stored in buckets that hold the
+// samples from one host in one hour, which keeps the number of
+// documents, and the size of the indexes, small. A bucket holds at
+// most sysInfoBucketMaxSamples samples, so that hosts that report
+// often do not exceed the document size limit; further samples from
+// the hour go into overflow buckets. Queries unwind the
+// buckets so that aggregations operate on individual samples, in the
+// same shape as SystemInformationRecord documents.
+//
+// The legacy collection holds one document per sample; use
+// MigrateSystemInfoToBuckets to move its contents into buckets.
+const (
+	sysInfoBucketCollection = "sysinfo.buckets"
+	sysInfoLegacyCollection = "sysinfo.stats"
+
+	sysInfoBucketMaxSamples = 720
+)
+
+// The legacy collection needs no indexes, as migration reads it in
+// natural order.
+func init() {
+	registerIndexes(sysInfoBucketCollection,
+		mgo.Index{Key: []string{sysInfoBucketHostKey, sysInfoBucketHourKey}},
+		mgo.Index{Key: []string{sysInfoBucketHourKey}},
+		mgo.Index{Key: []string{bsonutil.GetDottedKeyName(sysInfoBucketSamplesKey, sysInfoIDKey)}})
+}
+
+type systemInfoBucket struct {
+	ID       string                     `bson:"_id"`
+	Hostname string                     `bson:"hn"`
+	Hour     time.Time                  `bson:"hour"`
+	Count    int                        `bson:"n"`
+	Samples  []*SystemInformationRecord `bson:"samples"`
+}
+
+var (
+	sysInfoBucketHostKey    = bsonutil.MustHaveTag(systemInfoBucket{}, "Hostname")
+	sysInfoBucketHourKey    = bsonutil.MustHaveTag(systemInfoBucket{}, "Hour")
+	sysInfoBucketCountKey   = bsonutil.MustHaveTag(systemInfoBucket{}, "Count")
+	sysInfoBucketSamplesKey = bsonutil.MustHaveTag(systemInfoBucket{}, "Samples")
+)
+
+func sysInfoBucketHour(ts time.Time) time.Time { return ts.UTC().Truncate(time.Hour) }
+
+// sysInfoBucketID returns the id of a bucket for the host and hour.
+// The first bucket has index 0, and overflow buckets count up from 1.
+func sysInfoBucketID(host string, ts time.Time, idx int) string {
+	id := fmt.Sprintf("%s@%s", host, sysInfoBucketHour(ts).Format(time.RFC3339))
+	if idx > 0 {
+		id = fmt.Sprintf("%s#%d", id, idx)
+	}
+
+	return id
+}
+
+// addToBucket appends the record to the first bucket for its host and
+// hour that has room, creating the bucket if needed. Records that are
+// already in a bucket are not added again.
+func addToBucket(rec *SystemInformationRecord) error {
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			sysInfoBucketHostKey: rec.Hostname,
+			sysInfoBucketHourKey: sysInfoBucketHour(rec.Timestamp),
+		},
+		"$push": bson.M{sysInfoBucketSamplesKey: rec},
+		"$inc":  bson.M{sysInfoBucketCountKey: 1},
+	}
+
+	for idx := 0; ; idx++ {
+		// buckets written before the count existed have no count,
+		// and still have room.
+		query := bson.M{
+			"_id": sysInfoBucketID(rec.Hostname, rec.Timestamp, idx),
+			bsonutil.GetDottedKeyName(sysInfoBucketSamplesKey, sysInfoIDKey): bson.M{"$ne": rec.ID},
+			sysInfoBucketCountKey: bson.M{"$not": bson.M{"$gte": sysInfoBucketMaxSamples}},
+		}
+
+		// the upsert collides with an existing bucket when the
+		// bucket already holds the record or is full, so the query
+		// doesn't match, or when another writer created the bucket
+		// concurrently. In the last case, retrying finds the bucket
+		// and adds the record.
+		err := db.Upsert(sysInfoBucketCollection, query, update)
+		if mgo.IsDup(errors.Cause(err)) {
+			err = db.Upsert(sysInfoBucketCollection, query, update)
+		}
+
+		if !mgo.IsDup(errors.Cause(err)) {
+			return errors.Wrapf(err, "problem adding sample '%s' to bucket", rec.ID.Hex())
+		}
+
+		num, err := db.Query(bson.M{
+			sysInfoBucketHostKey: rec.Hostname,
+			sysInfoBucketHourKey: sysInfoBucketHour(rec.Timestamp),
+			bsonutil.GetDottedKeyName(sysInfoBucketSamplesKey, sysInfoIDKey): rec.ID,
+		}).Count(sysInfoBucketCollection)
+		if err != nil {
+			return errors.Wrap(err, "problem checking for existing sample")
+		}
+
+		if num > 0 {
+			return nil
+		}
+	}
+}
+
+// sysInfoBucketFilter selects the buckets that may hold samples from
+// the host in the time range. Empty hosts and zero times are
+// unbounded.
+func sysInfoBucketFilter(host string, start, end time.Time) bson.M {
+	filter := bson.M{}
+	if host != "" {
+		filter[sysInfoBucketHostKey] = host
+	}
+
+	hour := bson.M{}
+	if !start.IsZero() {
+		hour["$gte"] = sysInfoBucketHour(start)
+	}
+	if !end.IsZero() {
+		hour["$lt"] = end
+	}
+	if len(hour) > 0 {
+		filter[sysInfoBucketHourKey] = hour
+	}
+
+	return filter
+}
+
+// sysInfoPipeline returns an aggregation pipeline that runs the stages
+// over the samples held by the buckets that match the filter. Samples
+// are unwound in bucket order.
+func sysInfoPipeline(bucketFilter bson.M, stages ...bson.M) []bson.M {
+	return append([]bson.M{
+		{"$match": bucketFilter},
+		{"$sort": bson.M{sysInfoBucketHourKey: 1}},
+		{"$unwind": "$" + sysInfoBucketSamplesKey},
+		{"$replaceRoot": bson.M{"newRoot": "$" + sysInfoBucketSamplesKey}},
+	}, stages...)
+}
+
+// aggregateSysInfo runs the pipeline over individual samples.
+func aggregateSysInfo(bucketFilter bson.M, pipeline []bson.M, out interface{}) error {
+	return errors.WithStack(db.Aggregate(sysInfoBucketCollection, sysInfoPipeline(bucketFilter, pipeline...), out))
+}
+
+// streamSysInfo calls the function for every sample from the host in
+// the time range, in time order, reading the buckets for one hour at
+// a time.
+func streamSysInfo(host string, start, end time.Time, fn func(*SystemInformationRecord) error) error {
+	iter := db.Query(sysInfoBucketFilter(host, start, end)).Sort(sysInfoBucketHourKey).Iter(sysInfoBucketCollection)
+	if iter == nil {
+		return errors.New("problem querying system information buckets")
+	}
+
+	var hour time.Time
+	samples := []*SystemInformationRecord{}
+	flush := func() error {
+		sort.Stable(recordsByTime(samples))
+		for _, rec := range samples {
+			if rec.Timestamp.Before(start) || !rec.Timestamp.Before(end) {
+				continue
+			}
+			rec.populated = true
+
+			if err := fn(rec); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		samples = samples[:0]
+
+		return nil
+	}
+
+	for {
+		bucket := &systemInfoBucket{}
+		if !iter.Next(bucket) {
+			break
+		}
+
+		if !bucket.Hour.Equal(hour) {
+			if err := flush(); err != nil {
+				grip.Warning(iter.Close())
+				return err
+			}
+			hour = bucket.Hour
+		}
+
+		samples = append(samples, bucket.Samples...)
+	}
+
+	if err := flush(); err != nil {
+		grip.Warning(iter.Close())
+		return err
+	}
+
+	return errors.WithStack(iter.Close())
+}
+
+type recordsByTime []*SystemInformationRecord
+
+func (s recordsByTime) Len() int           { return len(s) }
+func (s recordsByTime) Less(i, j int) bool { return s[i].Timestamp.Before(s[j].Timestamp) }
+func (s recordsByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// MigrateSystemInfoToBuckets moves up to batchSize documents from the
+// legacy one-document-per-sample collection into buckets, removing
+// each document once it is stored in its bucket, and returns the
+// number of documents moved. Migration is safe to interrupt and
+// resume: samples that were stored but not removed are not duplicated
+// when they are migrated again.
+func MigrateSystemInfoToBuckets(batchSize int) (int, error) {
+	legacy := []*SystemInformationRecord{}
+	if err := db.Query(bson.M{}).Limit(batchSize).FindAll(sysInfoLegacyCollection, &legacy); err != nil {
+		return 0, errors.Wrap(err, "problem reading legacy system information")
+	}
+
+	for idx, rec := range legacy {
+		if err := addToBucket(rec); err != nil {
+			return idx, errors.Wrapf(err, "problem storing sample '%s' in bucket", rec.ID.Hex())
+		}
+
+		if err := db.Query(bson.M{sysInfoIDKey: rec.ID}).RemoveOne(sysInfoLegacyCollection); err != nil {
+			return idx, errors.Wrapf(err, "problem removing migrated sample '%s'", rec.ID.Hex())
+		}
+	}
+
+	return len(legacy), nil
+}
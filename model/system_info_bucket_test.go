@@ -0,0 +1,87 @@
+package model
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSystemInfoBucketIDsGroupSamplesByHour(t *testing.T) {
+	assert := assert.New(t)
+
+	first := time.Date(2017, time.March, 1, 10, 0, 0, 0, time.UTC)
+	last := first.Add(59*time.Minute + 59*time.Second)
+	next := first.Add(time.Hour)
+	offset := last.In(time.FixedZone("EST", -5*60*60))
+
+	assert.Equal("host-a@2017-03-01T10:00:00Z", sysInfoBucketID("host-a", first, 0))
+	assert.Equal(sysInfoBucketID("host-a", first, 0), sysInfoBucketID("host-a", last, 0))
+	assert.Equal(sysInfoBucketID("host-a", first, 0), sysInfoBucketID("host-a", offset, 0))
+	assert.NotEqual(sysInfoBucketID("host-a", first, 0), sysInfoBucketID("host-a", next, 0))
+	assert.NotEqual(sysInfoBucketID("host-a", first, 0), sysInfoBucketID("host-b", first, 0))
+}
+
+func TestSystemInfoOverflowBucketIDsAreDistinct(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := time.Date(2017, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal("host-a@2017-03-01T10:00:00Z#1", sysInfoBucketID("host-a", ts, 1))
+	assert.NotEqual(sysInfoBucketID("host-a", ts, 0), sysInfoBucketID("host-a", ts, 1))
+	assert.NotEqual(sysInfoBucketID("host-a", ts, 1), sysInfoBucketID("host-a", ts, 2))
+}
+
+func TestSystemInfoBucketFilterIncludesPartialHours(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2017, time.March, 1, 10, 30, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	assert.Equal(bson.M{
+		sysInfoBucketHostKey: "host-a",
+		sysInfoBucketHourKey: bson.M{
+			"$gte": time.Date(2017, time.March, 1, 10, 0, 0, 0, time.UTC),
+			"$lt":  end,
+		},
+	}, sysInfoBucketFilter("host-a", start, end))
+
+	assert.Equal(bson.M{}, sysInfoBucketFilter("", time.Time{}, time.Time{}))
+}
+
+func TestRecordsByTimeSortsBucketSamples(t *testing.T) {
+	now := time.Now()
+	records := []*SystemInformationRecord{
+		{Timestamp: now.Add(time.Minute)},
+		{Timestamp: now},
+		{Timestamp: now.Add(-time.Minute)},
+	}
+
+	sort.Stable(recordsByTime(records))
+	assert.True(t, sort.IsSorted(recordsByTime(records)))
+	assert.Equal(t, now.Add(-time.Minute), records[0].Timestamp)
+}
+
+func TestSystemInfoBetweenQuerySelectsTheTimeRange(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2017, time.March, 1, 10, 30, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	assert.Equal(bson.M{
+		sysInfoHostKey: "host-a",
+		sysInfoTimestampKey: bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}, sysInfoBetweenQuery("host-a", start, end))
+
+	assert.Equal(bson.M{
+		sysInfoTimestampKey: bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}, sysInfoBetweenQuery("", start, end))
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Formats supported when exporting system information records.
+const (
+	SystemInfoExportCSV   = "csv"
+	SystemInfoExportJSONL = "jsonl"
+)
+
+var (
+	sysInfoUsageKey = bsonutil.MustHaveTag(message.SystemInfo{}, "Usage")
+)
+
+var systemInfoCSVColumns = []string{
+	"time", "hostname", "num_cpus",
+	"cpu_user", "cpu_system", "cpu_idle", "cpu_nice", "cpu_iowait",
+	"cpu_irq", "cpu_softirq", "cpu_steal",
+	"mem_total", "mem_available", "mem_used", "mem_used_percent", "mem_free",
+	"net_bytes_sent", "net_bytes_recv", "net_packets_sent", "net_packets_recv",
+	"net_errin", "net_errout", "net_dropin", "net_dropout",
+}
+
+var systemInfoCSVPartitionColumns = []string{
+	"path", "fstype", "total", "used", "free", "used_percent", "inodes_used_percent",
+}
+
+// SystemInfoCSVHeader returns the column names for flattened system
+// information records, with repeated columns for the specified number
+// of partitions.
+func SystemInfoCSVHeader(partitions int) []string {
+	out := append([]string{}, systemInfoCSVColumns...)
+
+	for i := 0; i < partitions; i++ {
+		prefix := "partition_" + strconv.Itoa(i) + "_"
+		for _, col := range systemInfoCSVPartitionColumns {
+			out = append(out, prefix+col)
+		}
+	}
+
+	return out
+}
+
+// CSVRow flattens the record into a row that matches the columns
+// returned by SystemInfoCSVHeader for the same number of
+// partitions. Records with fewer partitions have empty trailing
+// columns; partitions beyond the limit are omitted.
+func (i *SystemInformationRecord) CSVRow(partitions int) []string {
+	info := i.Data
+	float := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	count := func(v uint64) string { return strconv.FormatUint(v, 10) }
+
+	out := []string{
+		i.Timestamp.Format(time.RFC3339), i.Hostname, strconv.Itoa(info.NumCPU),
+		float(info.CPU.User), float(info.CPU.System), float(info.CPU.Idle), float(info.CPU.Nice),
+		float(info.CPU.Iowait), float(info.CPU.Irq), float(info.CPU.Softirq), float(info.CPU.Steal),
+		count(info.VMStat.Total), count(info.VMStat.Available), count(info.VMStat.Used),
+		float(info.VMStat.UsedPercent), count(info.VMStat.Free),
+		count(info.NetStat.BytesSent), count(info.NetStat.BytesRecv),
+		count(info.NetStat.PacketsSent), count(info.NetStat.PacketsRecv),
+		count(info.NetStat.Errin), count(info.NetStat.Errout),
+		count(info.NetStat.Dropin), count(info.NetStat.Dropout),
+	}
+
+	for idx := 0; idx < partitions; idx++ {
+		if idx >= len(info.Usage) {
+			out = append(out, make([]string, len(systemInfoCSVPartitionColumns))...)
+			continue
+		}
+
+		usage := info.Usage[idx]
+		out = append(out, usage.Path, usage.Fstype, count(usage.Total), count(usage.Used),
+			count(usage.Free), float(usage.UsedPercent), float(usage.InodesUsedPercent))
+	}
+
+	return out
+}
+
+func hostnameBetweenFilter(host string, start, end time.Time) bson.M {
+	return bson.M{
+		sysInfoHostKey: host,
+		sysInfoTimestampKey: bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	}
+}
+
+// MaxPartitionsBetween returns the largest number of partitions
+// reported in any sample from the host in the time range.
+func (i *SystemInformationRecords) MaxPartitionsBetween(host string, start, end time.Time) (int, error) {
+	usageKey := "$" + bsonutil.GetDottedKeyName(sysInfoDataKey, sysInfoUsageKey)
+	pipeline := []bson.M{
+		{"$match": hostnameBetweenFilter(host, start, end)},
+		{"$group": bson.M{
+			"_id": nil,
+			"max": bson.M{"$max": bson.M{"$size": bson.M{"$ifNull": []interface{}{usageKey, []interface{}{}}}}},
+		}},
+	}
+
+	out := []struct {
+		Max int `bson:"max"`
+	}{}
+
+	if err := aggregateSysInfo(sysInfoBucketFilter(host, start, end), pipeline, &out); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	if len(out) == 0 {
+		return 0, nil
+	}
+
+	return out[0].Max, nil
+}
+
+// StreamHostnameBetween iterates, in time order, over all records for
+// the host in the time range and calls the function for each
+// record. Iteration stops at the first error.
+func (i *SystemInformationRecords) StreamHostnameBetween(host string, start, end time.Time, fn func(*SystemInformationRecord) error) error {
+	return errors.WithStack(streamSysInfo(host, start, end, fn))
+}
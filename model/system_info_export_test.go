@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemInfoCSVRowsMatchHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	rec := &SystemInformationRecord{Hostname: "build-host", Timestamp: time.Now()}
+	assert.NoError(json.Unmarshal([]byte(`{"usage": [
+		{"path": "/", "fstype": "ext4", "total": 100, "used": 40, "free": 60, "usedPercent": 40}]}`), &rec.Data))
+
+	for _, partitions := range []int{0, 1, 3} {
+		header := SystemInfoCSVHeader(partitions)
+		row := rec.CSVRow(partitions)
+		assert.Len(row, len(header))
+		assert.Equal("build-host", row[1])
+	}
+
+	header := SystemInfoCSVHeader(2)
+	row := rec.CSVRow(2)
+	assert.Equal("partition_0_path", header[len(systemInfoCSVColumns)])
+	assert.Equal("/", row[len(systemInfoCSVColumns)])
+	assert.Equal("60", row[len(systemInfoCSVColumns)+4])
+	assert.Equal("", row[len(row)-1])
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/evergreen-ci/sink/db/bsonutil"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	diskUsageUsedKey  = bsonutil.MustHaveTag(diskUsageElem(), "Used")
+	diskUsageTotalKey = bsonutil.MustHaveTag(diskUsageElem(), "Total")
+)
+
+// DiskForecast predicts when a partition will fill, from a linear fit
+// of the partition's used space over time. Exhaustion is nil when
+// usage is flat or shrinking, or when there are too few samples to
+// fit a trend.
+type DiskForecast struct {
+	Hostname      string     `json:"host"`
+	Mountpoint    string     `json:"mountpoint"`
+	Samples       int        `json:"samples"`
+	LastSample    time.Time  `json:"last_sample"`
+	Total         uint64     `json:"total"`
+	Used          uint64     `json:"used"`
+	GrowthPerDay  float64    `json:"growth_per_day"`
+	Exhaustion    *time.Time `json:"exhaustion,omitempty"`
+	DaysRemaining *float64   `json:"days_remaining,omitempty"`
+}
+
+// Message returns a system event describing the forecast.
+func (f *DiskForecast) Message(p level.Priority) message.Composer {
+	fields := message.Fields{
+		"host":           f.Hostname,
+		"mountpoint":     f.Mountpoint,
+		"used":           f.Used,
+		"total":          f.Total,
+		"growth_per_day": f.GrowthPerDay,
+	}
+
+	msg := fmt.Sprintf("partition '%s' on '%s' is not predicted to fill", f.Mountpoint, f.Hostname)
+	if f.Exhaustion != nil {
+		fields["exhaustion"] = *f.Exhaustion
+		fields["days_remaining"] = *f.DaysRemaining
+		msg = fmt.Sprintf("partition '%s' on '%s' is predicted to fill in %.1f days (at %s)",
+			f.Mountpoint, f.Hostname, *f.DaysRemaining, f.Exhaustion.Format(time.RFC3339))
+	}
+
+	return message.NewFieldsMessage(p, msg, fields)
+}
+
+// diskTrend holds the sums needed for a least squares fit of used space
+// (y, in bytes) over time (x, in seconds since the start of the
+// window) for one partition.
+type diskTrend struct {
+	ID struct {
+		Hostname   string `bson:"host"`
+		Mountpoint string `bson:"path"`
+	} `bson:"_id"`
+	N     int       `bson:"n"`
+	SumX  float64   `bson:"sx"`
+	SumY  float64   `bson:"sy"`
+	SumXY float64   `bson:"sxy"`
+	SumXX float64   `bson:"sxx"`
+	Total uint64    `bson:"total"`
+	Used  uint64    `bson:"used"`
+	Last  time.Time `bson:"last"`
+}
+
+// forecast fits the trend and predicts when the partition will fill,
+// relative to now.
+func (t *diskTrend) forecast(start, now time.Time) *DiskForecast {
+	out := &DiskForecast{
+		Hostname:   t.ID.Hostname,
+		Mountpoint: t.ID.Mountpoint,
+		Samples:    t.N,
+		LastSample: t.Last,
+		Total:      t.Total,
+		Used:       t.Used,
+	}
+
+	n := float64(t.N)
+	denominator := n*t.SumXX - t.SumX*t.SumX
+	if t.N < 2 || denominator <= 0 {
+		return out
+	}
+
+	slope := (n*t.SumXY - t.SumX*t.SumY) / denominator
+	intercept := (t.SumY - slope*t.SumX) / n
+	out.GrowthPerDay = slope * (24 * time.Hour).Seconds()
+
+	if slope <= 0 {
+		return out
+	}
+
+	seconds := (float64(t.Total) - intercept) / slope
+	exhaustion := start.Add(time.Duration(seconds * float64(time.Second))).Round(time.Second)
+	days := exhaustion.Sub(now).Hours() / 24
+	out.Exhaustion = &exhaustion
+	out.DaysRemaining = &days
+
+	return out
+}
+
+// DiskForecasts computes capacity forecasts for every partition from
+// the system information history.
+type DiskForecasts struct {
+	slice     []*DiskForecast
+	populated bool
+}
+
+func (f *DiskForecasts) Slice() []*DiskForecast { return f.slice }
+func (f *DiskForecasts) IsNil() bool            { return !f.populated }
+
+// FindBetween populates the forecasts from the samples in the time
+// range, for all hosts or for one host if the host is not empty.
+// Forecasts are ordered by predicted exhaustion, soonest first, with
+// partitions that are not predicted to fill last.
+func (f *DiskForecasts) FindBetween(host string, start, end time.Time) error {
+	match := bson.M{sysInfoTimestampKey: bson.M{"$gte": start, "$lt": end}}
+	if host != "" {
+		match[sysInfoHostKey] = host
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$sort": bson.M{sysInfoTimestampKey: 1}},
+		{"$unwind": sysInfoField(sysInfoUsageKey)},
+		{"$project": bson.M{
+			sysInfoHostKey:      1,
+			sysInfoTimestampKey: 1,
+			"path":              sysInfoField(sysInfoUsageKey, diskUsagePathKey),
+			"total":             sysInfoField(sysInfoUsageKey, diskUsageTotalKey),
+			"y":                 sysInfoField(sysInfoUsageKey, diskUsageUsedKey),
+			"x": bson.M{"$divide": []interface{}{
+				bson.M{"$subtract": []interface{}{"$" + sysInfoTimestampKey, start}}, 1000,
+			}},
+		}},
+		{"$group": bson.M{
+			"_id":   bson.M{"host": "$" + sysInfoHostKey, "path": "$path"},
+			"n":     bson.M{"$sum": 1},
+			"sx":    bson.M{"$sum": "$x"},
+			"sy":    bson.M{"$sum": "$y"},
+			"sxy":   bson.M{"$sum": bson.M{"$multiply": []interface{}{"$x", "$y"}}},
+			"sxx":   bson.M{"$sum": bson.M{"$multiply": []interface{}{"$x", "$x"}}},
+			"total": bson.M{"$last": "$total"},
+			"used":  bson.M{"$last": "$y"},
+			"last":  bson.M{"$last": "$" + sysInfoTimestampKey},
+		}},
+	}
+
+	trends := []*diskTrend{}
+	f.populated = false
+	if err := aggregateSysInfo(sysInfoBucketFilter(host, start, end), pipeline, &trends); err != nil {
+		return errors.WithStack(err)
+	}
+
+	now := time.Now()
+	f.slice = make([]*DiskForecast, 0, len(trends))
+	for _, t := range trends {
+		f.slice = append(f.slice, t.forecast(start, now))
+	}
+	sort.Stable(byExhaustion(f.slice))
+	f.populated = true
+
+	return nil
+}
+
+// AtRisk returns the forecasts for partitions predicted to fill in
+// fewer than the specified number of days.
+func (f *DiskForecasts) AtRisk(days float64) []*DiskForecast {
+	out := []*DiskForecast{}
+	for _, forecast := range f.slice {
+		if forecast.DaysRemaining != nil && *forecast.DaysRemaining < days {
+			out = append(out, forecast)
+		}
+	}
+
+	return out
+}
+
+type byExhaustion []*DiskForecast
+
+func (s byExhaustion) Len() int      { return len(s) }
+func (s byExhaustion) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byExhaustion) Less(i, j int) bool {
+	if s[i].Exhaustion == nil {
+		return false
+	}
+	if s[j].Exhaustion == nil {
+		return true
+	}
+
+	return s[i].Exhaustion.Before(*s[j].Exhaustion)
+}
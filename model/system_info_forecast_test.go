@@ -0,0 +1,80 @@
+package model
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeDiskTrend(total uint64, points map[float64]float64) *diskTrend {
+	t := &diskTrend{Total: total}
+	for x, y := range points {
+		t.N++
+		t.SumX += x
+		t.SumY += y
+		t.SumXY += x * y
+		t.SumXX += x * x
+	}
+
+	return t
+}
+
+func TestDiskForecastPredictsExhaustion(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2017, time.March, 1, 0, 0, 0, 0, time.UTC)
+	day := (24 * time.Hour).Seconds()
+
+	// 100 bytes used at the start, growing by 10 bytes a day, on a
+	// 200 byte partition: full after ten days.
+	trend := makeDiskTrend(200, map[float64]float64{0: 100, day: 110, 2 * day: 120})
+	forecast := trend.forecast(start, start.Add(2*24*time.Hour))
+
+	assert.InDelta(10, forecast.GrowthPerDay, 0.0001)
+	if assert.NotNil(forecast.Exhaustion) {
+		assert.Equal(start.Add(10*24*time.Hour), *forecast.Exhaustion)
+		assert.InDelta(8, *forecast.DaysRemaining, 0.0001)
+	}
+}
+
+func TestDiskForecastWithoutGrowth(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now()
+
+	shrinking := makeDiskTrend(200, map[float64]float64{0: 120, 60: 110, 120: 100})
+	forecast := shrinking.forecast(start, start)
+	assert.True(forecast.GrowthPerDay < 0)
+	assert.Nil(forecast.Exhaustion)
+	assert.Nil(forecast.DaysRemaining)
+
+	single := makeDiskTrend(200, map[float64]float64{0: 120})
+	forecast = single.forecast(start, start)
+	assert.Equal(0.0, forecast.GrowthPerDay)
+	assert.Nil(forecast.Exhaustion)
+}
+
+func TestDiskForecastsOrderAndRisk(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	soon, later := now.Add(24*time.Hour), now.Add(30*24*time.Hour)
+	one, thirty := 1.0, 30.0
+
+	forecasts := &DiskForecasts{slice: []*DiskForecast{
+		{Mountpoint: "/flat"},
+		{Mountpoint: "/later", Exhaustion: &later, DaysRemaining: &thirty},
+		{Mountpoint: "/soon", Exhaustion: &soon, DaysRemaining: &one},
+	}}
+	sort.Stable(byExhaustion(forecasts.slice))
+
+	order := []string{}
+	for _, f := range forecasts.Slice() {
+		order = append(order, f.Mountpoint)
+	}
+	assert.Equal([]string{"/soon", "/later", "/flat"}, order)
+
+	atRisk := forecasts.AtRisk(7)
+	if assert.Len(atRisk, 1) {
+		assert.Equal("/soon", atRisk[0].Mountpoint)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateSystemInfoLabels returns an error if any of the label names
+// cannot be stored or queried as a document key.
+func ValidateSystemInfoLabels(labels map[string]string) error {
+	for k := range labels {
+		if k == "" {
+			return errors.New("label names must not be empty")
+		}
+
+		if strings.HasPrefix(k, "$") || strings.Contains(k, ".") {
+			return errors.Errorf("label name '%s' must not start with '$' or contain '.'", k)
+		}
+	}
+
+	return nil
+}
+
+// ParseSystemInfoLabels converts a list of "name=value" strings into a
+// label map.
+func ParseSystemInfoLabels(args []string) (map[string]string, error) {
+	out := make(map[string]string, len(args))
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("label '%s' is not of the form name=value", arg)
+		}
+		out[parts[0]] = parts[1]
+	}
+
+	if err := ValidateSystemInfoLabels(out); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSystemInfoLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	labels, err := ParseSystemInfoLabels([]string{"distro=rhel70", "role=", "pool=a=b"})
+	assert.NoError(err)
+	assert.Equal(map[string]string{"distro": "rhel70", "role": "", "pool": "a=b"}, labels)
+
+	labels, err = ParseSystemInfoLabels(nil)
+	assert.NoError(err)
+	assert.Len(labels, 0)
+
+	for _, bad := range []string{"distro", "=rhel70", "$distro=rhel70", "dist.ro=rhel70"} {
+		_, err = ParseSystemInfoLabels([]string{bad})
+		assert.Error(err, bad)
+	}
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidatePublicURL checks that the url is an http or https url for a
+// host outside of the service's own network, so that requests cannot
+// direct the service to contact itself or internal hosts. Host names
+// are not resolved, so names that resolve to internal addresses pass.
+func ValidatePublicURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("scheme '%s' is not http or https", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("url must have a host")
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.Errorf("host '%s' is local", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+			return errors.Errorf("address '%s' is not public", host)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/evergreen-ci/sink"
+	"github.com/evergreen-ci/sink/model"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// Admin returns the ./sink admin sub-command object, which hosts
+// maintenance operations that run directly against the database.
+func Admin() cli.Command {
+	return cli.Command{
+		Name:  "admin",
+		Usage: "database maintenance operations",
+		Subcommands: []cli.Command{
+			ensureIndexes(),
+		},
+	}
+}
+
+func ensureIndexes() cli.Command {
+	return cli.Command{
+		Name:  "ensure-indexes",
+		Usage: "reports differences between the declared and existing indexes, and creates missing indexes",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "dbUri",
+				Usage:  "specify a mongodb connection string",
+				Value:  "mongodb://localhost:27017",
+				EnvVar: "SINK_MONGODB_URL",
+			},
+			cli.StringFlag{
+				Name:   "dbName",
+				Usage:  "specify a database name to use",
+				Value:  "sink",
+				EnvVar: "SINK_DATABASE_NAME",
+			},
+			cli.BoolFlag{
+				Name:  "dryRun",
+				Usage: "only report differences, without creating indexes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			sink.SetConf(&sink.Configuration{DatabaseName: c.String("dbName")})
+
+			session, err := mgo.Dial(c.String("dbUri"))
+			if err != nil {
+				return errors.Wrapf(err, "could not connect to db %s", c.String("dbUri"))
+			}
+			if err = sink.SetMgoSession(session); err != nil {
+				return errors.Wrap(err, "problem caching DB session")
+			}
+
+			drift, err := model.CheckIndexes()
+			if err != nil {
+				return errors.Wrap(err, "problem checking indexes")
+			}
+
+			for _, d := range drift {
+				fmt.Println(d)
+			}
+			fmt.Printf("found %d index differences\n", len(drift))
+
+			if c.Bool("dryRun") {
+				return nil
+			}
+
+			if err = model.EnsureIndexes(); err != nil {
+				return errors.Wrap(err, "problem ensuring indexes")
+			}
+
+			fmt.Println("ensured declared indexes")
+			return nil
+		},
+	}
+}
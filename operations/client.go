@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/evergreen-ci/sink/cgroup"
+	"github.com/evergreen-ci/sink/model"
+	"github.com/evergreen-ci/sink/procinfo"
 	"github.com/evergreen-ci/sink/rest"
 	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/level"
 	"github.com/mongodb/grip/message"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
@@ -42,6 +47,8 @@ func Client() cli.Command {
 			getSimpleLog(),
 			getSystemStatusEvents(),
 			systemEvent(),
+			acknowledgeSystemEvents(),
+			watchSystemEvents(),
 			systemInfo(),
 		},
 	}
@@ -184,21 +191,115 @@ func getSimpleLog() cli.Command {
 
 }
 
+func eventFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "component",
+			Usage: "only match events from this component",
+		},
+		cli.StringFlag{
+			Name:  "level",
+			Usage: "only match events of this level",
+		},
+		cli.StringFlag{
+			Name:  "minLevel",
+			Usage: "only match events of this level or higher",
+		},
+		cli.StringFlag{
+			Name:  "maxLevel",
+			Usage: "only match events of this level or lower",
+		},
+		cli.StringFlag{
+			Name:  "start",
+			Usage: "RFC3339 formatted time. only match events first seen at or after this time",
+		},
+		cli.StringFlag{
+			Name:  "end",
+			Usage: "RFC3339 formatted time. only match events first seen before this time",
+		},
+		cli.StringFlag{
+			Name:  "acknowledged",
+			Usage: "only match events that are (true) or are not (false) acknowledged",
+		},
+	}
+}
+
+func eventFilterFromFlags(c *cli.Context) (*model.EventFilter, error) {
+	filter := &model.EventFilter{Component: c.String("component")}
+	catcher := grip.NewCatcher()
+
+	priority := func(name string) level.Priority {
+		arg := c.String(name)
+		if arg == "" {
+			return level.Invalid
+		}
+
+		p := level.FromString(arg)
+		if !level.IsValidPriority(p) {
+			catcher.Add(errors.Errorf("%s is not a valid level", arg))
+		}
+		return p
+	}
+
+	filter.MinLevel = priority("minLevel")
+	filter.MaxLevel = priority("maxLevel")
+	if c.String("level") != "" {
+		filter.MinLevel = priority("level")
+		filter.MaxLevel = filter.MinLevel
+	}
+
+	var err error
+	if arg := c.String("start"); arg != "" {
+		filter.Start, err = time.Parse(time.RFC3339, arg)
+		catcher.Add(err)
+	}
+	if arg := c.String("end"); arg != "" {
+		filter.End, err = time.Parse(time.RFC3339, arg)
+		catcher.Add(err)
+	}
+	if arg := c.String("acknowledged"); arg != "" {
+		ack, err := strconv.ParseBool(arg)
+		catcher.Add(err)
+		filter.Acknowledged = &ack
+	}
+
+	if catcher.HasErrors() {
+		return nil, errors.Wrap(catcher.Resolve(), "problem parsing event filter")
+	}
+
+	return filter, errors.WithStack(filter.Validate())
+}
+
+// eventTransitionFlags specify who makes a change to events' states,
+// and why.
+func eventTransitionFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "user",
+			Usage:  "the user making the change, recorded in the events' history",
+			EnvVar: "USER",
+		},
+		cli.StringFlag{
+			Name:  "note",
+			Usage: "an optional note about the change, recorded in the events' history",
+		},
+	}
+}
+
 func getSystemStatusEvents() cli.Command {
 	return cli.Command{
 		Name:  "get-system-events",
-		Usage: "prints json for all system events of a specified level",
-		Flags: []cli.Flag{
-			cli.StringFlag{
-				Name:  "level",
-				Usage: "specify a filter to a level for messages",
-			},
+		Usage: "prints json for the system events that match the filters, newest first",
+		Flags: append(eventFilterFlags(),
 			cli.IntFlag{
 				Name:  "limit",
 				Usage: "specify a number of messages to retrieve, defaults to no limit",
 				Value: -1,
 			},
-		},
+			cli.StringFlag{
+				Name:  "cursor",
+				Usage: "the 'next' value from a previous page of results",
+			}),
 		Action: func(c *cli.Context) error {
 			ctx := context.Background()
 
@@ -207,7 +308,12 @@ func getSystemStatusEvents() cli.Command {
 				return errors.Wrap(err, "problem creating REST client")
 			}
 
-			resp, err := client.GetSystemEvents(ctx, c.String("level"), c.Int("limit"))
+			filter, err := eventFilterFromFlags(c)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			resp, err := client.FindSystemEvents(ctx, filter, c.String("cursor"), c.Int("limit"))
 			if err != nil {
 				return errors.Wrap(err, "problem getting system event log")
 			}
@@ -224,11 +330,77 @@ func getSystemStatusEvents() cli.Command {
 	}
 }
 
+func acknowledgeSystemEvents() cli.Command {
+	return cli.Command{
+		Name:  "acknowledge-system-events",
+		Usage: "acknowledges system events by id, by filter, or both",
+		Flags: append(append(eventFilterFlags(), eventTransitionFlags()...),
+			cli.StringSliceFlag{
+				Name:  "id",
+				Usage: "specify the id of an event to acknowledge, may be repeated",
+			}),
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			filter, err := eventFilterFromFlags(c)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			num, err := client.AcknowledgeSystemEvents(ctx, c.StringSlice("id"), filter, c.String("user"), c.String("note"))
+			if err != nil {
+				return errors.Wrap(err, "problem acknowledging system events")
+			}
+
+			fmt.Printf("acknowledged %d events\n", num)
+			return nil
+		},
+	}
+}
+
+func watchSystemEvents() cli.Command {
+	return cli.Command{
+		Name:  "watch-events",
+		Usage: "prints json for each system event that matches the filters as the service stores it",
+		Flags: eventFilterFlags(),
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			filter, err := eventFilterFromFlags(c)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			err = client.WatchSystemEvents(ctx, filter, func(e *model.Event) error {
+				out, err := json.Marshal(e)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+
+				fmt.Println(string(out))
+				return nil
+			})
+
+			return errors.Wrap(err, "problem watching system events")
+		},
+	}
+}
+
 func systemEvent() cli.Command {
 	return cli.Command{
 		Name:  "system-event",
-		Usage: "prints json for a specific system event",
-		Flags: []cli.Flag{
+		Usage: "prints json for a specific system event, including its state history, optionally changing its state or assignee",
+		Flags: append(eventTransitionFlags(),
 			cli.StringFlag{
 				Name:  "id",
 				Usage: "specify the Id of a log message",
@@ -237,7 +409,26 @@ func systemEvent() cli.Command {
 				Name:  "acknowledge",
 				Usage: "acknowledge the alert when specified",
 			},
-		},
+			cli.DurationFlag{
+				Name:  "snooze",
+				Usage: "snooze the event for this long, after which it reopens",
+			},
+			cli.BoolFlag{
+				Name:  "resolve",
+				Usage: "resolve the event when specified",
+			},
+			cli.BoolFlag{
+				Name:  "reopen",
+				Usage: "reopen the event when specified",
+			},
+			cli.StringFlag{
+				Name:  "assign",
+				Usage: "assign the event to this user",
+			},
+			cli.BoolFlag{
+				Name:  "unassign",
+				Usage: "clear the event's assignee when specified",
+			}),
 		Action: func(c *cli.Context) error {
 			ctx := context.Background()
 
@@ -248,12 +439,41 @@ func systemEvent() cli.Command {
 
 			id := c.String("id")
 
+			var states []string
+			var until time.Time
+			if c.Bool("acknowledge") {
+				states = append(states, model.EventAcknowledged)
+			}
+			if c.Duration("snooze") > 0 {
+				states = append(states, model.EventSnoozed)
+				until = time.Now().Add(c.Duration("snooze"))
+			}
+			if c.Bool("resolve") {
+				states = append(states, model.EventResolved)
+			}
+			if c.Bool("reopen") {
+				states = append(states, model.EventReopened)
+			}
+			if c.String("assign") != "" || c.Bool("unassign") {
+				states = append(states, model.EventAssign)
+			}
+
 			var resp *rest.SystemEventResponse
 
-			if c.Bool("acknowledge") {
-				resp, err = client.AcknowledgeSystemEvent(ctx, id)
-			} else {
+			switch len(states) {
+			case 0:
 				resp, err = client.GetSystemEvent(ctx, id)
+			case 1:
+				if states[0] == model.EventAssign {
+					if c.String("assign") != "" && c.Bool("unassign") {
+						return errors.New("specify at most one of assign and unassign")
+					}
+					resp, err = client.AssignSystemEvent(ctx, id, c.String("assign"), c.String("user"), c.String("note"))
+					break
+				}
+				resp, err = client.TransitionSystemEvent(ctx, id, states[0], c.String("user"), c.String("note"), until)
+			default:
+				return errors.New("specify at most one of acknowledge, snooze, resolve, reopen, and assign")
 			}
 
 			if err != nil {
@@ -281,6 +501,13 @@ func systemInfo() cli.Command {
 			systemInfoSend(),
 			systemInfoImport(),
 			systemInfoGet(),
+			systemInfoExport(),
+			systemInfoTop(),
+			systemInfoGroup(),
+			systemInfoContainer(),
+			systemInfoProcesses(),
+			systemInfoForecast(),
+			systemInfoAnomalies(),
 		},
 	}
 }
@@ -316,8 +543,8 @@ func systemInfoGet() cli.Command {
 		Action: func(c *cli.Context) error {
 			ctx := context.Background()
 
-			client, err := rest.NewClient(c.Parent().String("host"),
-				c.Parent().Int("port"), "")
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
 			if err != nil {
 				return errors.Wrap(err, "problem creating REST client")
 			}
@@ -346,27 +573,191 @@ func systemInfoGet() cli.Command {
 	}
 }
 
-func systemInfoSend() cli.Command {
+func systemInfoExport() cli.Command {
+	host, _ := os.Hostname()
+
 	return cli.Command{
-		Name:  "send",
-		Usage: "collects and sends a system information document to the remote service",
+		Name:  "export",
+		Usage: "writes all system info documents for a host in a time range as csv or jsonl",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "specify name of host",
+				Value: host,
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "output format, either 'csv' or 'jsonl'",
+				Value: "csv",
+			},
+			cli.StringFlag{
+				Name:  "file",
+				Usage: "path to write the export to. defaults to standard output",
+			},
+		},
 		Action: func(c *cli.Context) error {
 			ctx := context.Background()
 
-			client, err := rest.NewClient(c.Parent().String("host"),
-				c.Parent().Int("port"), "")
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
 			if err != nil {
 				return errors.Wrap(err, "problem creating REST client")
 			}
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
 
-			msg := message.CollectSystemInfo().(*message.SystemInfo)
+			var out io.Writer = os.Stdout
+			if fn := c.String("file"); fn != "" {
+				f, err := os.Create(fn)
+				if err != nil {
+					return errors.Wrapf(err, "problem creating file '%s'", fn)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			return errors.WithStack(client.ExportSystemInformation(ctx, c.String("host"),
+				start, end, c.String("format"), out))
+		},
+	}
+}
+
+func systemInfoTop() cli.Command {
+	return cli.Command{
+		Name:  "top",
+		Usage: "ranks all hosts that reported system info in a time range by memory, cpu, or disk usage",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "metric",
+				Usage: "metric to rank hosts by: 'memory', 'cpu', or 'disk'",
+				Value: "memory",
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+			cli.IntFlag{
+				Name:  "limit",
+				Usage: "number of hosts to return",
+				Value: 10,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
 
-			resp, err := client.SendSystemInfo(ctx, msg)
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
 			if err != nil {
-				return errors.Wrap(err, "problem sending system info")
+				return errors.Wrap(err, "problem creating REST client")
+			}
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
+
+			resp, err := client.GetSystemInfoTop(ctx, c.String("metric"), start, end, c.Int("limit"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoGroup() cli.Command {
+	return cli.Command{
+		Name:  "group",
+		Usage: "compares groups of hosts that share a label value by memory, cpu, or disk usage",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "label",
+				Usage: "name of the label to group hosts by",
+			},
+			cli.StringFlag{
+				Name:  "metric",
+				Usage: "metric to compare groups by: 'memory', 'cpu', or 'disk'",
+				Value: "cpu",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "only consider documents with this name=value label, may be repeated",
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			if c.String("label") == "" {
+				return errors.New("must specify a label to group by")
+			}
+
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			filter, err := model.ParseSystemInfoLabels(c.StringSlice("filter"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
+
+			resp, err := client.GetSystemInfoGrouped(ctx, c.String("label"), c.String("metric"), filter, start, end)
+			if err != nil {
+				return errors.WithStack(err)
 			}
 
-			grip.Debug(resp)
 			out, err := pretyJSON(resp)
 			if err != nil {
 				return errors.WithStack(err)
@@ -376,29 +767,463 @@ func systemInfoSend() cli.Command {
 			return nil
 		},
 	}
+}
 
+func systemInfoContainer() cli.Command {
+	return cli.Command{
+		Name:  "container",
+		Usage: "save and access cgroup resource accounting for containers",
+		Subcommands: []cli.Command{
+			systemInfoContainerSend(),
+			systemInfoContainerGet(),
+		},
+	}
 }
 
-func systemInfoImport() cli.Command {
+func systemInfoContainerSend() cli.Command {
+	host, _ := os.Hostname()
+
 	return cli.Command{
-		Name:  "import",
-		Usage: "import system info data from a json file, one line per document",
+		Name:  "send",
+		Usage: "collects and sends the cgroup accounting of the current container to the remote service",
 		Flags: []cli.Flag{
 			cli.StringFlag{
-				Name:  "file",
-				Usage: "specify the file that holds sysinfo json",
-				Value: "sysinfo.json",
+				Name:  "container",
+				Usage: "name of the container, defaults to the hostname, which is the container id in docker",
+				Value: host,
+			},
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "name of the host running the container",
+			},
+			cli.StringFlag{
+				Name:  "root",
+				Usage: "mount point of the cgroup filesystem",
+				Value: cgroup.DefaultRoot,
+			},
+			cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "attach a name=value label to the document, may be repeated",
 			},
 		},
 		Action: func(c *cli.Context) error {
 			ctx := context.Background()
 
-			client, err := rest.NewClient(c.Parent().String("host"),
-				c.Parent().Int("port"), "")
+			client, err := rest.NewClient(c.Parent().Parent().Parent().String("host"),
+				c.Parent().Parent().Parent().Int("port"), "")
 			if err != nil {
 				return errors.Wrap(err, "problem creating REST client")
 			}
 
+			labels, err := model.ParseSystemInfoLabels(c.StringSlice("label"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			stats, err := cgroup.Collect(c.String("root"))
+			if err != nil {
+				return errors.Wrap(err, "problem collecting cgroup accounting")
+			}
+			if len(stats.Errors) > 0 {
+				grip.Warning(strings.Join(stats.Errors, "; "))
+			}
+
+			resp, err := client.SendContainerInfo(ctx, &model.ContainerInfoRecord{
+				Timestamp: time.Now(),
+				Hostname:  c.String("host"),
+				Container: c.String("container"),
+				Labels:    labels,
+				Data:      *stats,
+			})
+			if err != nil {
+				return errors.Wrap(err, "problem sending container info")
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoContainerGet() cli.Command {
+	return cli.Command{
+		Name:  "get",
+		Usage: "returns container accounting documents, optionally for one host or container",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "container",
+				Usage: "specify name of container",
+			},
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "specify name of host running the containers",
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+			cli.IntFlag{
+				Name:  "limit",
+				Usage: "number of results to return",
+				Value: 100,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().Parent().String("host"),
+				c.Parent().Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
+
+			records, err := client.GetContainerInfo(ctx, c.String("host"), c.String("container"),
+				start, end, c.Int("limit"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			out, err := pretyJSON(records)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoProcesses() cli.Command {
+	return cli.Command{
+		Name:  "processes",
+		Usage: "save and access snapshots of the heaviest processes on a host",
+		Subcommands: []cli.Command{
+			systemInfoProcessesSend(),
+			systemInfoProcessesGet(),
+		},
+	}
+}
+
+func systemInfoProcessesSend() cli.Command {
+	host, _ := os.Hostname()
+
+	return cli.Command{
+		Name:  "send",
+		Usage: "collects and sends a snapshot of the top processes by cpu and rss",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "specify name of host",
+				Value: host,
+			},
+			cli.IntFlag{
+				Name:  "top",
+				Usage: "number of processes to keep for each of cpu and rss",
+				Value: 10,
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "time to sample cpu usage over",
+				Value: time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().Parent().String("host"),
+				c.Parent().Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			snapshot, err := procinfo.Collect(c.Int("top"), c.Duration("interval"))
+			if err != nil {
+				return errors.Wrap(err, "problem collecting processes")
+			}
+
+			resp, err := client.SendProcessSnapshot(ctx, &model.ProcessSnapshotRecord{
+				Timestamp: time.Now(),
+				Hostname:  c.String("host"),
+				Data:      *snapshot,
+			})
+			if err != nil {
+				return errors.Wrap(err, "problem sending process snapshot")
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoProcessesGet() cli.Command {
+	host, _ := os.Hostname()
+
+	return cli.Command{
+		Name:  "get",
+		Usage: "returns process snapshots for a host",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "specify name of host",
+				Value: host,
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+			cli.IntFlag{
+				Name:  "limit",
+				Usage: "number of results to return",
+				Value: 100,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().Parent().String("host"),
+				c.Parent().Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
+
+			records, err := client.GetProcessSnapshots(ctx, c.String("host"), start, end, c.Int("limit"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			out, err := pretyJSON(records)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoForecast() cli.Command {
+	return cli.Command{
+		Name:  "forecast",
+		Usage: "predicts when each partition will fill from its recent usage",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "only return forecasts for this host",
+			},
+			cli.DurationFlag{
+				Name:  "window",
+				Usage: "amount of history to fit usage trends to",
+				Value: 7 * 24 * time.Hour,
+			},
+			cli.Float64Flag{
+				Name:  "days",
+				Usage: "only return partitions predicted to fill within this many days",
+				Value: -1,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			resp, err := client.GetDiskForecast(ctx, c.String("host"), c.Duration("window"), c.Float64("days"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoAnomalies() cli.Command {
+	return cli.Command{
+		Name:  "anomalies",
+		Usage: "lists samples that deviated from their host's baseline",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "only return anomalies for this host",
+			},
+			cli.StringFlag{
+				Name: "metric",
+				Usage: fmt.Sprintf("only return anomalies for this metric (%s, %s, %s, or %s)",
+					model.AnomalyMetricCPU, model.AnomalyMetricMemory,
+					model.AnomalyMetricNetworkSent, model.AnomalyMetricNetworkRecv),
+			},
+			cli.StringFlag{
+				Name:  "start",
+				Usage: "RFC3339 formatted time. defaults to 24 hours ago",
+				Value: time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+			},
+			cli.StringFlag{
+				Name:  "end",
+				Usage: "RFC3339 formatted time. defaults to current",
+				Value: time.Now().Format(time.RFC3339),
+			},
+			cli.IntFlag{
+				Name:  "limit",
+				Usage: "number of results to return",
+				Value: 100,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+			catcher := grip.NewCatcher()
+			start, err := time.Parse(time.RFC3339, c.String("start"))
+			catcher.Add(err)
+			end, err := time.Parse(time.RFC3339, c.String("end"))
+			catcher.Add(err)
+			if catcher.HasErrors() {
+				return errors.Wrap(catcher.Resolve(), "problem parsing dates")
+			}
+
+			resp, err := client.GetSystemInfoAnomalies(ctx, c.String("host"), c.String("metric"), start, end, c.Int("limit"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func systemInfoSend() cli.Command {
+	return cli.Command{
+		Name:  "send",
+		Usage: "collects and sends a system information document to the remote service",
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "attach a name=value label to the document, may be repeated",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			labels, err := model.ParseSystemInfoLabels(c.StringSlice("label"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			msg := message.CollectSystemInfo().(*message.SystemInfo)
+
+			resp, err := client.SendLabeledSystemInfo(ctx, msg, labels)
+			if err != nil {
+				return errors.Wrap(err, "problem sending system info")
+			}
+
+			grip.Debug(resp)
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+
+}
+
+func systemInfoImport() cli.Command {
+	return cli.Command{
+		Name:  "import",
+		Usage: "import system info data from a json file, one line per document",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file",
+				Usage: "specify the file that holds sysinfo json",
+				Value: "sysinfo.json",
+			},
+			cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "attach a name=value label to every document, may be repeated",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().Parent().String("host"),
+				c.Parent().Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			labels, err := model.ParseSystemInfoLabels(c.StringSlice("label"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
 			fn := c.String("file")
 			f, err := os.Open(fn)
 			if err != nil {
@@ -432,7 +1257,7 @@ func systemInfoImport() cli.Command {
 						continue
 					}
 
-					resp, err := client.SendSystemInfo(ctx, msg)
+					resp, err := client.SendLabeledSystemInfo(ctx, msg, labels)
 					grip.Debugf("%+v", resp)
 					if err != nil {
 						grip.Warning(err)
@@ -448,7 +1273,7 @@ func systemInfoImport() cli.Command {
 					continue
 				}
 
-				resp, err := client.SendSystemInfo(ctx, msg)
+				resp, err := client.SendLabeledSystemInfo(ctx, msg, labels)
 				grip.Debugf("%+v", resp)
 				if err != nil {
 					grip.Warning(err)
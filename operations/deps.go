@@ -1,20 +1,45 @@
 package operations
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/evergreen-ci/sink/rest"
 	"github.com/mongodb/grip"
 	"github.com/pkg/errors"
 	"github.com/tychoish/depgraph"
 	"github.com/urfave/cli"
+	"golang.org/x/net/context"
 )
 
+// Deps returns the entry point for the ./sink deps sub-command, which
+// loads a local dependency graph and summarizes it, and hosts commands
+// that work with the graphs stored by a sink service.
 func Deps() cli.Command {
 	return cli.Command{
-		Name: "deps",
+		Name:  "deps",
+		Usage: "work with build dependency graphs",
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "path",
+				Usage: "the path of a local graph to load and summarize",
 				Value: "deps.json",
 			},
+			cli.StringFlag{
+				Name:  "host",
+				Usage: "host for the remote sink instance.",
+				Value: "http://localhost",
+			},
+			cli.IntFlag{
+				Name:  "port",
+				Usage: "port for the remote sink service.",
+				Value: 3000,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			fn := c.String("path")
@@ -24,10 +49,423 @@ func Deps() cli.Command {
 				return errors.Wrap(err, "problem loading graph")
 			}
 
-			grip.Infof("first node: %+v", graph.Nodes[0])
-			grip.Infof("first edge: %+v", graph.Edges[0])
+			grip.Infof("graph has %d nodes and %d edges", len(graph.Nodes), len(graph.Edges))
+			if len(graph.Nodes) > 0 {
+				grip.Infof("first node: %+v", graph.Nodes[0])
+			}
+			if len(graph.Edges) > 0 {
+				grip.Infof("first edge: %+v", graph.Edges[0])
+			}
+			return nil
+		},
+		Subcommands: []cli.Command{
+			importDepGraph(),
+			depGraphRelatives("dependencies", "prints the nodes that a node depends on"),
+			depGraphRelatives("dependents", "prints the nodes that depend on a node"),
+			depGraphPath(),
+			diffDepGraphs(),
+			depGraphCycles(),
+			exportDepGraph(),
+			depGraphImpact(),
+		},
+	}
+}
+
+func importDepGraph() cli.Command {
+	return cli.Command{
+		Name:  "import",
+		Usage: "imports a graph into the service from a public url, or a path in the service's import directory",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "build",
+				Usage: "the id of the build that the graph describes",
+			},
+			cli.StringFlag{
+				Name:  "path",
+				Usage: "the url of the graph, or its path relative to the service's import directory",
+				Value: "deps.json",
+			},
+			cli.BoolFlag{
+				Name:  "wait",
+				Usage: "wait for the import to finish, printing its progress",
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to check the progress of the import when waiting",
+				Value: 2 * time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			build := c.String("build")
+			if build == "" {
+				return errors.New("must specify a build id")
+			}
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			resp, err := client.ImportDepGraph(ctx, build, c.String("path"))
+			if err != nil {
+				return errors.Wrap(err, "problem importing graph")
+			}
+
+			if !c.Bool("wait") {
+				out, err := pretyJSON(resp)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+
+				fmt.Println(out)
+				return nil
+			}
+
+			ticker := time.NewTicker(c.Duration("interval"))
+			defer ticker.Stop()
+
+			for resp.Import.IsImporting() {
+				<-ticker.C
+
+				resp, err = client.GetDepGraphImport(ctx, build)
+				if err != nil {
+					return errors.Wrap(err, "problem checking import progress")
+				}
+
+				fmt.Printf("%s: stored %d/%d nodes, %d/%d edges\n", resp.Import.State,
+					resp.Import.NodesStored, resp.Import.NodesTotal,
+					resp.Import.EdgesStored, resp.Import.EdgesTotal)
+			}
+
+			if resp.Import.State == model.GraphImportFailed {
+				return errors.Errorf("import of graph '%s' failed: %s", build, resp.Import.Error)
+			}
+
+			return nil
+		},
+	}
+}
+
+func depGraphQueryFlags(flags ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "build",
+			Usage: "the id of the build that the graph describes",
+		},
+		cli.StringSliceFlag{
+			Name:  "type",
+			Usage: "only follow edges of this type, such as LibraryToLibrary; may be repeated",
+		},
+	}, flags...)
+}
+
+func depGraphRelatives(name, usage string) cli.Command {
+	return cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: depGraphQueryFlags(
+			cli.StringFlag{
+				Name:  "node",
+				Usage: "the name or index of the node",
+			},
+			cli.IntFlag{
+				Name:  "depth",
+				Usage: "the number of edges to follow, defaults to no limit",
+			}),
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			query := client.GetDepGraphDependencies
+			if name == "dependents" {
+				query = client.GetDepGraphDependents
+			}
+
+			resp, err := query(ctx, c.String("build"), c.String("node"), c.Int("depth"), c.StringSlice("type"))
+			if err != nil {
+				return errors.Wrapf(err, "problem finding %s", name)
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func depGraphPath() cli.Command {
+	return cli.Command{
+		Name:  "path",
+		Usage: "prints the shortest chain of dependencies from one node to another",
+		Flags: depGraphQueryFlags(
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "the name or index of the node that the path starts from",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Usage: "the name or index of the node that the path ends at",
+			}),
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			resp, err := client.GetDepGraphPath(ctx, c.String("build"), c.String("from"), c.String("to"), c.StringSlice("type"))
+			if err != nil {
+				return errors.Wrap(err, "problem finding path")
+			}
+
+			out, err := pretyJSON(resp)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
+}
+
+func diffDepGraphs() cli.Command {
+	return cli.Command{
+		Name:  "diff",
+		Usage: "prints the nodes, edges, and library dependencies that changed between two builds",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "the id of the earlier build",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Usage: "the id of the later build",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			diff, err := client.DiffDepGraphs(ctx, c.String("from"), c.String("to"))
+			if err != nil {
+				return errors.Wrap(err, "problem comparing graphs")
+			}
+
+			out, err := pretyJSON(diff)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
 			return nil
 		},
 	}
+}
+
+func depGraphCycles() cli.Command {
+	return cli.Command{
+		Name:  "cycles",
+		Usage: "prints the dependency cycles among a build's libraries",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "build",
+				Usage: "the id of the build that the graph describes",
+			},
+			cli.StringFlag{
+				Name:  "baseline",
+				Usage: "the id of an earlier build; only report cycles that it does not have",
+			},
+			cli.BoolFlag{
+				Name:  "fail",
+				Usage: "exit with an error if there are cycles to report",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
 
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			build := c.String("build")
+			cycles, err := client.GetDepGraphCycles(ctx, build)
+			if err != nil {
+				return errors.Wrap(err, "problem finding cycles")
+			}
+
+			if baseline := c.String("baseline"); baseline != "" {
+				existing, err := client.GetDepGraphCycles(ctx, baseline)
+				if err != nil {
+					return errors.Wrap(err, "problem finding baseline cycles")
+				}
+
+				known := map[string]struct{}{}
+				for _, cycle := range existing {
+					known[cycle.Key()] = struct{}{}
+				}
+
+				introduced := []model.GraphCycle{}
+				for _, cycle := range cycles {
+					if _, ok := known[cycle.Key()]; !ok {
+						introduced = append(introduced, cycle)
+					}
+				}
+				cycles = introduced
+			}
+
+			out, err := pretyJSON(cycles)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+
+			if c.Bool("fail") && len(cycles) > 0 {
+				return errors.Errorf("build '%s' has %d library dependency cycles", build, len(cycles))
+			}
+
+			return nil
+		},
+	}
+}
+
+func exportDepGraph() cli.Command {
+	return cli.Command{
+		Name:  "export",
+		Usage: "writes all or part of a build's graph as graphviz dot or graphml",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "build",
+				Usage: "the id of the build that the graph describes",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "the export format, either 'dot' or 'graphml'",
+				Value: model.GraphExportDOT,
+			},
+			cli.StringSliceFlag{
+				Name:  "type",
+				Usage: "only export nodes of this type, such as Library; may be repeated",
+			},
+			cli.StringFlag{
+				Name:  "prefix",
+				Usage: "only export nodes whose names start with the prefix",
+			},
+			cli.StringFlag{
+				Name:  "node",
+				Usage: "only export the node with this name or index and the nodes near it",
+			},
+			cli.IntFlag{
+				Name:  "depth",
+				Usage: "the number of edges from the node to export",
+				Value: 1,
+			},
+			cli.StringFlag{
+				Name:  "output",
+				Usage: "the file to write the graph to, defaults to standard output",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			filter := model.GraphExportFilter{
+				Prefix: c.String("prefix"),
+				Node:   c.String("node"),
+				Depth:  c.Int("depth"),
+			}
+			for _, name := range c.StringSlice("type") {
+				t, err := model.ParseNodeType(name)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				filter.Types = append(filter.Types, t)
+			}
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			var out io.Writer = os.Stdout
+			if fn := c.String("output"); fn != "" {
+				f, err := os.Create(fn)
+				if err != nil {
+					return errors.Wrapf(err, "problem creating '%s'", fn)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			err = client.ExportDepGraph(ctx, c.String("build"), c.String("format"), filter, out)
+			return errors.Wrap(err, "problem exporting graph")
+		},
+	}
+}
+
+func depGraphImpact() cli.Command {
+	return cli.Command{
+		Name:  "impact",
+		Usage: "prints the libraries and artifacts that changes to files affect",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "build",
+				Usage: "the id of the build that the graph describes",
+			},
+			cli.StringSliceFlag{
+				Name:  "file",
+				Usage: "a changed file, may be repeated; defaults to reading one file per line from standard input",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			ctx := context.Background()
+
+			files := c.StringSlice("file")
+			if len(files) == 0 {
+				scanner := bufio.NewScanner(os.Stdin)
+				for scanner.Scan() {
+					if line := strings.TrimSpace(scanner.Text()); line != "" {
+						files = append(files, line)
+					}
+				}
+				if err := scanner.Err(); err != nil {
+					return errors.Wrap(err, "problem reading changed files")
+				}
+			}
+
+			client, err := rest.NewClient(c.Parent().String("host"), c.Parent().Int("port"), "")
+			if err != nil {
+				return errors.Wrap(err, "problem creating REST client")
+			}
+
+			impact, err := client.GetDepGraphImpact(ctx, c.String("build"), files)
+			if err != nil {
+				return errors.Wrap(err, "problem finding impact of changes")
+			}
+
+			out, err := pretyJSON(impact)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			fmt.Println(out)
+			return nil
+		},
+	}
 }
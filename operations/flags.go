@@ -1,6 +1,11 @@
 package operations
 
-import "github.com/urfave/cli"
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+)
 
 func baseFlags(flags ...cli.Flag) []cli.Flag {
 	return append(flags,
@@ -26,5 +31,33 @@ func baseFlags(flags ...cli.Flag) []cli.Flag {
 			Usage:  "specify a bucket name to use for storing data in s3",
 			EnvVar: "SINK_BUCKET_NAME",
 			Value:  "build-test-curator",
+		},
+		cli.Float64Flag{
+			Name:   "anomalyZScore",
+			Usage:  "specify the number of standard deviations from a host's baseline that flags a sample as anomalous",
+			EnvVar: "SINK_ANOMALY_ZSCORE",
+			Value:  3,
+		},
+		cli.Float64Flag{
+			Name:   "diskForecastDays",
+			Usage:  "specify the number of days before a partition's predicted exhaustion when sink records an event",
+			EnvVar: "SINK_DISK_FORECAST_DAYS",
+			Value:  14,
+		},
+		cli.StringFlag{
+			Name:   "eventSpool",
+			Usage:  "specify a file to hold system events while the database is unavailable; processes on a host may share it",
+			EnvVar: "SINK_EVENT_SPOOL",
+			Value:  defaultEventSpool(),
 		})
 }
+
+// defaultEventSpool returns a spool path outside of the temporary
+// directory, so that spooled events survive a reboot.
+func defaultEventSpool() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".sink", "events.spool")
+	}
+
+	return "sink-events.spool"
+}
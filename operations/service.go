@@ -24,6 +24,11 @@ func Service() cli.Command {
 				Usage:  "specify a port to run the service on",
 				Value:  3000,
 				EnvVar: "SINK_SERVICE_PORT",
+			},
+			cli.StringFlag{
+				Name:   "depGraphImportDir",
+				Usage:  "specify a directory that dependency graph imports may read files from; without it, imports must use public urls",
+				EnvVar: "SINK_DEPGRAPH_IMPORT_DIR",
 			}),
 		Action: func(c *cli.Context) error {
 			ctx, cancel := context.WithCancel(context.Background())
@@ -35,12 +40,14 @@ func Service() cli.Command {
 			bucket := c.String("bucket")
 			dbName := c.String("dbName")
 
-			if err := configure(workers, runLocal, mongodbURI, bucket, dbName); err != nil {
+			if err := configure(workers, runLocal, mongodbURI, bucket, dbName, c.String("eventSpool")); err != nil {
 				return errors.WithStack(err)
 			}
+			defer flushSystemEvents()
 
 			service := &rest.Service{
-				Port: c.Int("port"),
+				Port:              c.Int("port"),
+				DepGraphImportDir: c.String("depGraphImportDir"),
 			}
 
 			if err := service.Validate(); err != nil {
@@ -51,7 +58,7 @@ func Service() cli.Command {
 				return errors.Wrap(err, "problem starting services")
 			}
 
-			if err := backgroundJobs(ctx); err != nil {
+			if err := backgroundJobs(ctx, c.Float64("anomalyZScore"), c.Float64("diskForecastDays")); err != nil {
 				return errors.Wrap(err, "problem starting background jobs")
 			}
 
@@ -16,20 +16,18 @@ import (
 	mgo "gopkg.in/mgo.v2"
 )
 
-func configure(numWorkers int, localQueue bool, mongodbURI, bucket, dbName string) error {
+func configure(numWorkers int, localQueue bool, mongodbURI, bucket, dbName, eventSpool string) error {
 	sink.SetConf(&sink.Configuration{
 		BucketName:   bucket,
 		DatabaseName: dbName,
 	})
 
+	var q amboy.Queue
 	if localQueue {
-		q := queue.NewLocalLimitedSize(numWorkers, 1024)
+		q = queue.NewLocalLimitedSize(numWorkers, 1024)
 		grip.Infof("configured local queue with %d workers", numWorkers)
-		if err := sink.SetQueue(q); err != nil {
-			return errors.Wrap(err, "problem configuring queue")
-		}
 	} else {
-		q := queue.NewRemoteUnordered(numWorkers)
+		rq := queue.NewRemoteUnordered(numWorkers)
 		opts := driver.MongoDBOptions{
 			URI:      mongodbURI,
 			DB:       dbName,
@@ -37,18 +35,26 @@ func configure(numWorkers int, localQueue bool, mongodbURI, bucket, dbName strin
 		}
 
 		mongoDriver := driver.NewMongoDB(sink.QueueName, opts)
-		if err := q.SetDriver(mongoDriver); err != nil {
+		if err := rq.SetDriver(mongoDriver); err != nil {
 			return errors.Wrap(err, "problem configuring driver")
 		}
-
-		if err := sink.SetQueue(q); err != nil {
-			return errors.Wrap(err, "problem caching queue")
-		}
+		q = rq
 
 		grip.Info(message.MakeFieldsMessage("configured a remote mongodb-backed queue",
 			message.Fields{"db": dbName, "prefix": sink.QueueName, "priority": true}))
 	}
 
+	// record an event for each job that fails, so that processing
+	// errors are visible in the events API.
+	q, err := units.NewHookedQueue(q, units.RecordJobErrors)
+	if err != nil {
+		return errors.Wrap(err, "problem configuring queue hooks")
+	}
+
+	if err = sink.SetQueue(q); err != nil {
+		return errors.Wrap(err, "problem caching queue")
+	}
+
 	// create and cache a db session for use in tasks
 	session, err := mgo.Dial(mongodbURI)
 	if err != nil {
@@ -58,7 +64,17 @@ func configure(numWorkers int, localQueue bool, mongodbURI, bucket, dbName strin
 		return errors.Wrap(err, "problem caching DB session")
 	}
 
-	sender, err := model.NewDBSender("sink")
+	// without the unique indexes, concurrent writers can create
+	// duplicate records, but other missing indexes only make queries
+	// slow, so problems creating them should not prevent startup.
+	if err = model.EnsureUniqueIndexes(); err != nil {
+		return errors.Wrap(err, "problem ensuring unique indexes")
+	}
+	if err = model.EnsureIndexes(); err != nil {
+		grip.Warning(errors.Wrap(err, "problem ensuring indexes"))
+	}
+
+	sender, err := model.NewBufferedDBSender("sink", model.DBSenderOptions{SpoolPath: eventSpool})
 	if err != nil {
 		return errors.Wrapf(err, "problem setting system sender")
 	}
@@ -70,7 +86,30 @@ func configure(numWorkers int, localQueue bool, mongodbURI, bucket, dbName strin
 	return nil
 }
 
-func backgroundJobs(ctx context.Context) error {
+// flushSystemEvents stores the system events that are still buffered,
+// and should run before the process exits.
+func flushSystemEvents() {
+	if sender := sink.GetSystemSender(); sender != nil {
+		grip.Warning(errors.Wrap(sender.Close(), "problem flushing system events"))
+	}
+}
+
+const (
+	// diskForecastWindow is the amount of system information history
+	// used to fit disk usage trends.
+	diskForecastWindow = 7 * 24 * time.Hour
+
+	// anomalyDetectionInterval is how often sink checks new system
+	// information samples for anomalies.
+	anomalyDetectionInterval = 10 * time.Minute
+
+	// anomalyDetectionWindow is how recently a host must have reported
+	// to be checked for anomalies. It is longer than the interval so
+	// that a delayed job does not skip hosts.
+	anomalyDetectionWindow = 24 * time.Hour
+)
+
+func backgroundJobs(ctx context.Context, anomalyZScore, diskForecastDays float64) error {
 	// TODO: develop a specification format, either here or in
 	// amboy so that you can specify a list of amboy.QueueOperation
 	// functions + specific intervals
@@ -97,6 +136,41 @@ func backgroundJobs(ctx context.Context) error {
 		return err
 	}, time.Minute, true)
 
+	migration := units.MakeSystemInfoMigrationJob()
+	grip.Warning(message.NewErrorWrap(q.Put(migration), "problem scheduling job %s", migration.ID()))
+
+	amboy.PeriodicQueueOperation(ctx, q, func(cue amboy.Queue) error {
+		j := units.MakeDiskForecastJob(diskForecastWindow, diskForecastDays)
+		err := cue.Put(j)
+		grip.Error(message.NewErrorWrap(err, "problem scheduling job %s", j.ID()))
+
+		return err
+	}, time.Hour, true)
+
+	amboy.PeriodicQueueOperation(ctx, q, func(cue amboy.Queue) error {
+		j := units.MakeAnomalyDetectionJob(anomalyDetectionWindow, anomalyZScore)
+		err := cue.Put(j)
+		grip.Error(message.NewErrorWrap(err, "problem scheduling job %s", j.ID()))
+
+		return err
+	}, anomalyDetectionInterval, true)
+
+	amboy.PeriodicQueueOperation(ctx, q, func(cue amboy.Queue) error {
+		j := units.MakeEventSnoozeExpiryJob()
+		err := cue.Put(j)
+		grip.Error(message.NewErrorWrap(err, "problem scheduling job %s", j.ID()))
+
+		return err
+	}, time.Minute, true)
+
+	amboy.PeriodicQueueOperation(ctx, q, func(cue amboy.Queue) error {
+		j := units.MakeNotificationDispatchJob()
+		err := cue.Put(j)
+		grip.Error(message.NewErrorWrap(err, "problem scheduling job %s", j.ID()))
+
+		return err
+	}, time.Minute, true)
+
 	return nil
 }
 
@@ -31,9 +31,10 @@ func Worker() cli.Command {
 			bucket := c.String("bucket")
 			dbName := c.String("dbName")
 
-			if err := configure(workers, false, mongodbURI, bucket, dbName); err != nil {
+			if err := configure(workers, false, mongodbURI, bucket, dbName, c.String("eventSpool")); err != nil {
 				return errors.WithStack(err)
 			}
+			defer flushSystemEvents()
 
 			q, err := sink.GetQueue()
 			if err != nil {
@@ -44,7 +45,7 @@ func Worker() cli.Command {
 				return errors.Wrap(err, "problem starting queue")
 			}
 
-			if err = backgroundJobs(ctx); err != nil {
+			if err = backgroundJobs(ctx, c.Float64("anomalyZScore"), c.Float64("diskForecastDays")); err != nil {
 				return errors.Wrap(err, "problem starting background jobs")
 			}
 
@@ -0,0 +1,180 @@
+/*
+Package procinfo collects snapshots of the processes running on a
+host, using gopsutil, to make it possible to find the processes
+responsible for high cpu or memory usage.
+
+Snapshots only retain the processes that are among the heaviest users
+of either cpu or resident memory, to keep the documents small on
+hosts with many processes.
+*/
+package procinfo
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Process holds the resource usage and identity of a single process.
+type Process struct {
+	PID        int32     `json:"pid" bson:"pid"`
+	PPID       int32     `json:"ppid" bson:"ppid"`
+	Name       string    `json:"name" bson:"name"`
+	Cmdline    string    `json:"cmdline" bson:"cmdline"`
+	Username   string    `json:"user" bson:"user"`
+	CPUPercent float64   `json:"cpu_percent" bson:"cpu_percent"`
+	RSS        uint64    `json:"rss" bson:"rss"`
+	VMS        uint64    `json:"vms" bson:"vms"`
+	NumThreads int32     `json:"threads" bson:"threads"`
+	Created    time.Time `json:"created" bson:"created"`
+}
+
+// Snapshot holds the heaviest processes on a host at one time. The
+// processes are the union of the top processes by cpu and by rss,
+// ordered by cpu usage.
+type Snapshot struct {
+	Total     int        `json:"total" bson:"total"`
+	Interval  string     `json:"interval" bson:"interval"`
+	Processes []*Process `json:"processes" bson:"processes"`
+	Errors    []string   `json:"errors,omitempty" bson:"errors,omitempty"`
+}
+
+// Collect samples the cpu time of every process over the interval and
+// returns a snapshot with the top processes by cpu usage and the top
+// processes by rss. CPUPercent is relative to a single cpu, and may
+// exceed 100 for multi-threaded processes. Processes that exit during
+// the interval are omitted.
+func Collect(top int, interval time.Duration) (*Snapshot, error) {
+	if top <= 0 {
+		return nil, errors.New("must collect at least one process")
+	}
+
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, errors.Wrap(err, "problem listing processes")
+	}
+
+	type sample struct {
+		proc *process.Process
+		cpu  float64
+	}
+
+	samples := []sample{}
+	for _, pid := range pids {
+		proc, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+
+		times, err := proc.Times()
+		if err != nil {
+			continue
+		}
+
+		samples = append(samples, sample{proc: proc, cpu: times.Total()})
+	}
+
+	start := time.Now()
+	time.Sleep(interval)
+	elapsed := time.Since(start).Seconds()
+
+	snapshot := &Snapshot{Interval: interval.String()}
+	procs := []*Process{}
+	for _, s := range samples {
+		times, err := s.proc.Times()
+		if err != nil {
+			continue
+		}
+
+		info, err := describe(s.proc)
+		if err != nil {
+			snapshot.Errors = append(snapshot.Errors, err.Error())
+			continue
+		}
+
+		if elapsed > 0 {
+			info.CPUPercent = 100 * (times.Total() - s.cpu) / elapsed
+		}
+
+		procs = append(procs, info)
+	}
+
+	snapshot.Total = len(procs)
+	snapshot.Processes = SelectTop(procs, top)
+
+	return snapshot, nil
+}
+
+// describe gathers the identity and memory usage of a process. Only a
+// failure to read memory usage is an error; other fields are left
+// empty if they are not available, as is common for kernel threads or
+// for processes owned by other users.
+func describe(proc *process.Process) (*Process, error) {
+	out := &Process{PID: proc.Pid}
+
+	mem, err := proc.MemoryInfo()
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem reading memory usage for process %d", proc.Pid)
+	}
+	out.RSS = mem.RSS
+	out.VMS = mem.VMS
+
+	out.PPID, _ = proc.Ppid()
+	out.Name, _ = proc.Name()
+	out.Username, _ = proc.Username()
+	out.NumThreads, _ = proc.NumThreads()
+
+	if args, err := proc.CmdlineSlice(); err == nil {
+		out.Cmdline = strings.Join(args, " ")
+	}
+
+	if created, err := proc.CreateTime(); err == nil && created > 0 {
+		out.Created = time.Unix(0, created*int64(time.Millisecond))
+	}
+
+	return out, nil
+}
+
+type byCPU []*Process
+
+func (s byCPU) Len() int           { return len(s) }
+func (s byCPU) Less(i, j int) bool { return s[i].CPUPercent > s[j].CPUPercent }
+func (s byCPU) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type byRSS []*Process
+
+func (s byRSS) Len() int           { return len(s) }
+func (s byRSS) Less(i, j int) bool { return s[i].RSS > s[j].RSS }
+func (s byRSS) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// SelectTop returns the union of the n processes with the highest cpu
+// usage and the n processes with the highest rss, ordered by cpu
+// usage. The input slice is reordered.
+func SelectTop(procs []*Process, n int) []*Process {
+	if n >= len(procs) {
+		sort.Stable(byCPU(procs))
+		return procs
+	}
+
+	seen := map[int32]bool{}
+	out := []*Process{}
+
+	sort.Stable(byRSS(procs))
+	for _, proc := range procs[:n] {
+		seen[proc.PID] = true
+		out = append(out, proc)
+	}
+
+	sort.Stable(byCPU(procs))
+	for _, proc := range procs[:n] {
+		if !seen[proc.PID] {
+			out = append(out, proc)
+		}
+	}
+
+	sort.Stable(byCPU(out))
+	return out
+}
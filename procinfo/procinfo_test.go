@@ -0,0 +1,50 @@
+package procinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectTopMergesCPUAndRSS(t *testing.T) {
+	assert := assert.New(t)
+
+	procs := []*Process{
+		{PID: 1, CPUPercent: 90, RSS: 10},
+		{PID: 2, CPUPercent: 5, RSS: 9000},
+		{PID: 3, CPUPercent: 50, RSS: 8000},
+		{PID: 4, CPUPercent: 1, RSS: 1},
+		{PID: 5, CPUPercent: 2, RSS: 2},
+	}
+
+	top := SelectTop(procs, 2)
+	pids := []int32{}
+	for _, proc := range top {
+		pids = append(pids, proc.PID)
+	}
+	assert.Equal([]int32{1, 3, 2}, pids)
+
+	assert.Len(SelectTop(procs, 10), 5)
+	assert.Len(SelectTop(nil, 3), 0)
+}
+
+func TestCollectRequiresPositiveLimit(t *testing.T) {
+	_, err := Collect(0, time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestCollectSamplesProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshot, err := Collect(1000, 10*time.Millisecond)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.True(snapshot.Total > 0)
+	assert.True(len(snapshot.Processes) > 0)
+	for _, proc := range snapshot.Processes {
+		assert.NotZero(proc.PID)
+	}
+}
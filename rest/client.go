@@ -5,13 +5,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/evergreen-ci/sink/model"
 	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/level"
 	"github.com/mongodb/grip/message"
 	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
 	"github.com/tychoish/gimlet"
 	"golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
@@ -268,7 +275,30 @@ func (c *Client) GetSimpleLogText(ctx context.Context, logID string) ([]string,
 // System Events/Logging
 
 func (c *Client) GetSystemEvents(ctx context.Context, level string, limit int) (*SystemEventsResponse, error) {
-	url := c.getURL(fmt.Sprintf("/v1/status/events/%s?limit=%d", level, limit))
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if level != "" {
+		query.Set("level", level)
+	}
+
+	return c.getSystemEvents(ctx, query)
+}
+
+// FindSystemEvents returns up to limit events that match the filter,
+// newest first. Pass the Next value from the previous response as the
+// cursor to fetch the following page.
+func (c *Client) FindSystemEvents(ctx context.Context, filter *model.EventFilter, cursor string, limit int) (*SystemEventsResponse, error) {
+	query := eventFilterQuery(filter)
+	query.Set("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	return c.getSystemEvents(ctx, query)
+}
+
+func (c *Client) getSystemEvents(ctx context.Context, query url.Values) (*SystemEventsResponse, error) {
+	url := c.getURL("/v1/status/events?" + query.Encode())
 	out := &SystemEventsResponse{}
 
 	grip.Debugln("GET", url)
@@ -283,9 +313,96 @@ func (c *Client) GetSystemEvents(ctx context.Context, level string, limit int) (
 		return nil, errors.Wrap(err, "problem reading system status result")
 	}
 
+	if out.Err != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Err)
+	}
+
 	return out, nil
 }
 
+func eventFilterQuery(filter *model.EventFilter) url.Values {
+	out := url.Values{}
+	if filter == nil {
+		return out
+	}
+
+	if filter.Component != "" {
+		out.Set("component", filter.Component)
+	}
+	if filter.MinLevel != level.Invalid {
+		out.Set("min_level", filter.MinLevel.String())
+	}
+	if filter.MaxLevel != level.Invalid {
+		out.Set("max_level", filter.MaxLevel.String())
+	}
+	if !filter.Start.IsZero() {
+		out.Set("start", filter.Start.UTC().Format(time.RFC3339))
+	}
+	if !filter.End.IsZero() {
+		out.Set("end", filter.End.UTC().Format(time.RFC3339))
+	}
+	if filter.Acknowledged != nil {
+		out.Set("acknowledged", strconv.FormatBool(*filter.Acknowledged))
+	}
+
+	return out
+}
+
+// WatchSystemEvents streams the events that match the filter as the
+// service stores them, calling fn with each event. It returns when the
+// context is canceled, the service closes the stream, or fn returns an
+// error.
+func (c *Client) WatchSystemEvents(ctx context.Context, filter *model.EventFilter, fn func(*model.Event) error) error {
+	url := c.getURL("/v1/status/events/stream?" + eventFilterQuery(filter).Encode())
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "problem building request")
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return errors.Wrap(err, "problem with request")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		out := &SystemEventsResponse{}
+		if err = gimlet.GetJSON(resp.Body, out); err != nil {
+			return errors.Wrap(err, "problem reading event stream error")
+		}
+
+		return errors.Errorf("encountered problem server-side: %s", out.Err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		e := &model.Event{}
+		if err = json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), e); err != nil {
+			return errors.Wrap(err, "problem reading event")
+		}
+
+		if err = fn(e); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return errors.Wrap(scanner.Err(), "problem reading event stream")
+}
+
 func (c *Client) GetSystemEvent(ctx context.Context, id string) (*SystemEventResponse, error) {
 	url := c.getURL("/v1/status/events/" + id)
 	out := &SystemEventResponse{}
@@ -305,12 +422,40 @@ func (c *Client) GetSystemEvent(ctx context.Context, id string) (*SystemEventRes
 	return out, nil
 }
 
-func (c *Client) AcknowledgeSystemEvent(ctx context.Context, id string) (*SystemEventResponse, error) {
-	url := c.getURL(fmt.Sprintf("/v1/status/events/%s/acknowledge", id))
+// AcknowledgeSystemEvent moves the event to the acknowledged state,
+// recording the user and optional note in the event's history.
+func (c *Client) AcknowledgeSystemEvent(ctx context.Context, id, user, note string) (*SystemEventResponse, error) {
+	return c.transitionSystemEvent(ctx, fmt.Sprintf("/v1/status/events/%s/acknowledge", id),
+		&eventTransitionRequest{User: user, Note: note})
+}
+
+// TransitionSystemEvent moves the event to the state, recording the
+// user and optional note in the event's history. Until is the time a
+// snooze ends, and must be zero for other states.
+func (c *Client) TransitionSystemEvent(ctx context.Context, id, state, user, note string, until time.Time) (*SystemEventResponse, error) {
+	return c.transitionSystemEvent(ctx, fmt.Sprintf("/v1/status/events/%s/transition", id),
+		&eventTransitionRequest{State: state, User: user, Note: note, Until: until})
+}
+
+// AssignSystemEvent assigns the event to the assignee, or clears its
+// assignee if the assignee is empty, recording the user and optional
+// note in the event's history.
+func (c *Client) AssignSystemEvent(ctx context.Context, id, assignee, user, note string) (*SystemEventResponse, error) {
+	return c.transitionSystemEvent(ctx, fmt.Sprintf("/v1/status/events/%s/transition", id),
+		&eventTransitionRequest{State: model.EventAssign, Assignee: assignee, User: user, Note: note})
+}
+
+func (c *Client) transitionSystemEvent(ctx context.Context, path string, req *eventTransitionRequest) (*SystemEventResponse, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem building request")
+	}
+
+	url := c.getURL(path)
 	out := &SystemEventResponse{}
 	grip.Debugln("POST", url)
 
-	resp, err := ctxhttp.Post(ctx, c.client, url, "", nil)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, errors.Wrap(err, "problem with request")
 	}
@@ -318,23 +463,63 @@ func (c *Client) AcknowledgeSystemEvent(ctx context.Context, id string) (*System
 	defer resp.Body.Close()
 
 	if err = gimlet.GetJSON(resp.Body, out); err != nil {
-		return nil, errors.Wrap(err, "problem reading system status result")
+		return nil, errors.Wrap(err, "problem reading system event result")
 	}
 
 	return out, nil
 }
 
+// AcknowledgeSystemEvents acknowledges the open events with the
+// specified ids, the events that match the filter, or, if both are
+// specified, the events that satisfy both, and returns the number of
+// events acknowledged. The user and optional note are recorded in
+// each event's history.
+func (c *Client) AcknowledgeSystemEvents(ctx context.Context, ids []string, filter *model.EventFilter, user, note string) (int, error) {
+	payload, err := json.Marshal(&acknowledgeEventsRequest{IDs: ids, User: user, Note: note})
+	if err != nil {
+		return 0, errors.Wrap(err, "problem building request")
+	}
+
+	url := c.getURL("/v1/status/events/acknowledge?" + eventFilterQuery(filter).Encode())
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &AcknowledgeEventsResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return 0, errors.Wrap(err, "problem reading acknowledgment result")
+	}
+
+	if out.Error != "" {
+		return 0, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Acknowledged, nil
+}
+
 ///////////////////////////////////
 //
 // System Information
 
 func (c *Client) SendSystemInfo(ctx context.Context, info *message.SystemInfo) (*SystemInfoReceivedResponse, error) {
+	return c.SendLabeledSystemInfo(ctx, info, nil)
+}
+
+// SendLabeledSystemInfo sends a system information document, attaching
+// the labels to the record stored by the service.
+func (c *Client) SendLabeledSystemInfo(ctx context.Context, info *message.SystemInfo, labels map[string]string) (*SystemInfoReceivedResponse, error) {
 	payload, err := json.Marshal(info)
 	if err != nil {
 		return nil, errors.Wrap(err, "problem converting json")
 	}
 
 	url := c.getURL("/v1/system_info")
+	if len(labels) > 0 {
+		url += "?" + labelQuery("label", labels).Encode()
+	}
 	grip.Debugln("POST", url)
 	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
 	if err != nil {
@@ -379,6 +564,795 @@ func (c *Client) GetSystemInformation(ctx context.Context, host string, start, e
 	return out.Data, nil
 }
 
-///////////////////////////////////
-//
-// Dependency Graph Info
+func (c *Client) SendContainerInfo(ctx context.Context, info *model.ContainerInfoRecord) (*ContainerInfoReceivedResponse, error) {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL("/v1/system_info/containers")
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &ContainerInfoReceivedResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading container info result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// GetContainerInfo returns container samples in the time range. The
+// host and container filter the results if they are not empty.
+func (c *Client) GetContainerInfo(ctx context.Context, host, container string, start, end time.Time, limit int) ([]*model.ContainerInfoRecord, error) {
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	query.Set("limit", strconv.Itoa(limit))
+	if host != "" {
+		query.Set("host", host)
+	}
+	if container != "" {
+		query.Set("container", container)
+	}
+
+	url := c.getURL("/v1/system_info/containers?" + query.Encode())
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &ContainerInfoResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading container info result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Data, nil
+}
+
+func (c *Client) SendProcessSnapshot(ctx context.Context, snapshot *model.ProcessSnapshotRecord) (*ProcessSnapshotReceivedResponse, error) {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL("/v1/system_info/processes")
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &ProcessSnapshotReceivedResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading process snapshot result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+func (c *Client) GetProcessSnapshots(ctx context.Context, host string, start, end time.Time, limit int) ([]*model.ProcessSnapshotRecord, error) {
+	url := c.getURL(fmt.Sprintf("/v1/system_info/processes/%s?start=%s&end=%s&limit=%d",
+		host, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), limit))
+
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &ProcessSnapshotResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading process snapshot result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Data, nil
+}
+
+// ExportSystemInformation streams all system information documents
+// for the host in the time range to the writer, in either the "csv"
+// or "jsonl" format.
+func (c *Client) ExportSystemInformation(ctx context.Context, host string, start, end time.Time, format string, out io.Writer) error {
+	url := c.getURL(fmt.Sprintf("/v1/system_info/host/%s/export?format=%s&start=%s&end=%s",
+		host, format, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339)))
+
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("encountered problem server-side: %s", string(msg))
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return errors.Wrap(err, "problem reading exported system info")
+}
+
+func (c *Client) GetSystemInfoTop(ctx context.Context, metric string, start, end time.Time, limit int) (*SystemInfoTopResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/system_info/top/%s?start=%s&end=%s&limit=%d",
+		metric, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), limit))
+
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoTopResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading aggregate result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+func (c *Client) GetSystemInfoGrouped(ctx context.Context, label, metric string, filter map[string]string, start, end time.Time) (*SystemInfoGroupResponse, error) {
+	query := labelQuery("filter", filter)
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	url := c.getURL(fmt.Sprintf("/v1/system_info/group/%s/%s?%s", label, metric, query.Encode()))
+
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoGroupResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading grouped result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+func labelQuery(param string, labels map[string]string) url.Values {
+	out := url.Values{}
+	for k, v := range labels {
+		out.Add(param, k+"="+v)
+	}
+
+	return out
+}
+
+// GetDiskForecast returns disk capacity forecasts computed from the
+// window of history. The host limits the forecasts to one host if it
+// is not empty, and a non-negative number of days limits them to
+// partitions predicted to fill within that many days.
+func (c *Client) GetDiskForecast(ctx context.Context, host string, window time.Duration, days float64) (*DiskForecastResponse, error) {
+	query := url.Values{}
+	query.Set("window", window.String())
+	if host != "" {
+		query.Set("host", host)
+	}
+	if days >= 0 {
+		query.Set("days", strconv.FormatFloat(days, 'f', -1, 64))
+	}
+
+	url := c.getURL("/v1/system_info/forecast/disk?" + query.Encode())
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DiskForecastResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading disk forecast result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// GetSystemInfoAnomalies returns the samples flagged as anomalous in
+// the time range, most recent first. The host and metric filter the
+// results if they are not empty.
+func (c *Client) GetSystemInfoAnomalies(ctx context.Context, host, metric string, start, end time.Time, limit int) (*SystemInfoAnomaliesResponse, error) {
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	query.Set("limit", strconv.Itoa(limit))
+	if host != "" {
+		query.Set("host", host)
+	}
+	if metric != "" {
+		query.Set("metric", metric)
+	}
+
+	url := c.getURL("/v1/system_info/anomalies?" + query.Encode())
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoAnomaliesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading anomalies result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+func (c *Client) GetSystemInfoAlertRules(ctx context.Context) (*SystemInfoAlertsResponse, error) {
+	url := c.getURL("/v1/system_info/alerts")
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoAlertsResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading alert rules result")
+	}
+
+	return out, nil
+}
+
+func (c *Client) SetSystemInfoAlertRule(ctx context.Context, id, metric, op string, threshold float64, dur time.Duration, level, host string) (*SystemInfoAlertResponse, error) {
+	req := &alertRuleRequest{
+		Metric:    metric,
+		Operator:  op,
+		Threshold: threshold,
+		Duration:  dur.String(),
+		Level:     level,
+		Hostname:  host,
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/system_info/alerts/%s", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoAlertResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading alert rule result")
+	}
+
+	return out, nil
+}
+
+func (c *Client) RemoveSystemInfoAlertRule(ctx context.Context, id string) (*SystemInfoAlertResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/system_info/alerts/%s", id))
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem building request")
+	}
+
+	grip.Debugln("DELETE", url)
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &SystemInfoAlertResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading alert rule result")
+	}
+
+	return out, nil
+}
+
+///////////////////////////////////
+//
+// Notifications
+
+func (c *Client) GetNotificationRules(ctx context.Context) (*NotificationRulesResponse, error) {
+	url := c.getURL("/v1/notifications/rules")
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &NotificationRulesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading notification rules result")
+	}
+
+	return out, nil
+}
+
+func (c *Client) GetNotificationRule(ctx context.Context, id string) (*NotificationRuleResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/notifications/rules/%s", id))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &NotificationRuleResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading notification rule result")
+	}
+
+	return out, nil
+}
+
+// SetNotificationRule creates or replaces the notification rule with
+// the rule's id.
+func (c *Client) SetNotificationRule(ctx context.Context, rule *model.NotificationRule) (*NotificationRuleResponse, error) {
+	req := &notificationRuleRequest{
+		MinLevel:    rule.MinLevel,
+		Component:   rule.Component,
+		MessageType: rule.MessageType,
+		MaxAttempts: rule.MaxAttempts,
+		Target: notificationTargetRequest{
+			Type:        rule.Target.Type,
+			URL:         rule.Target.URL,
+			SMTPServer:  rule.Target.SMTPServer,
+			SMTPPort:    rule.Target.SMTPPort,
+			UseSSL:      rule.Target.UseSSL,
+			Username:    rule.Target.Username,
+			Password:    rule.Target.Password,
+			PasswordEnv: rule.Target.PasswordEnv,
+			From:        rule.Target.From,
+			To:          rule.Target.To,
+		},
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/notifications/rules/%s", rule.ID))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &NotificationRuleResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading notification rule result")
+	}
+
+	return out, nil
+}
+
+func (c *Client) RemoveNotificationRule(ctx context.Context, id string) (*NotificationRuleResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/notifications/rules/%s", id))
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem building request")
+	}
+
+	grip.Debugln("DELETE", url)
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &NotificationRuleResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading notification rule result")
+	}
+
+	return out, nil
+}
+
+// GetNotificationDeliveries returns the most recent entries of the
+// delivery log. The rule and status filter the results if they are not
+// empty.
+func (c *Client) GetNotificationDeliveries(ctx context.Context, rule, status string, limit int) (*NotificationDeliveriesResponse, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if rule != "" {
+		query.Set("rule", rule)
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+
+	url := c.getURL("/v1/notifications/deliveries?" + query.Encode())
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &NotificationDeliveriesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading notification deliveries result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+///////////////////////////////////
+//
+// Dependency Graph Info
+
+// CreateDepGraph creates the graph with the specified ID, if it does
+// not exist.
+func (c *Client) CreateDepGraph(ctx context.Context, id string) (*CreateDepGraphResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &CreateDepGraphResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph creation result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// AddDepGraphNodes adds the nodes to the graph, replacing the nodes
+// that the graph has at the same indexes.
+func (c *Client) AddDepGraphNodes(ctx context.Context, id string, nodes []*depgraph.Node) (*AddDepGraphNodesResponse, error) {
+	payload, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/nodes", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &AddDepGraphNodesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph nodes result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// AddDepGraphEdges adds the edges to the graph, replacing the edges
+// that the graph has of the same type from the same node. The graph
+// must already have the nodes that the edges connect.
+func (c *Client) AddDepGraphEdges(ctx context.Context, id string, edges []*depgraph.Edge) (*AddDepGraphEdgesResponse, error) {
+	payload, err := json.Marshal(edges)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/edges", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &AddDepGraphEdgesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph edges result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// ImportDepGraph queues a job on the service that loads the graph from
+// the source, which is a path on the service's host or a URL, and
+// stores its nodes and edges. The graph is created if it does not
+// exist.
+func (c *Client) ImportDepGraph(ctx context.Context, id, source string) (*DepGraphImportResponse, error) {
+	payload, err := json.Marshal(&depGraphImportRequest{Source: source})
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/import", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphImportResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph import result")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// GetDepGraphImport returns the status and progress of the graph's
+// most recent import.
+func (c *Client) GetDepGraphImport(ctx context.Context, id string) (*DepGraphImportResponse, error) {
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/import", id))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphImportResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph import status")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+func depGraphQuery(types []string) url.Values {
+	query := url.Values{}
+	for _, t := range types {
+		query.Add("type", t)
+	}
+
+	return query
+}
+
+// GetDepGraphDependencies returns the nodes that the node depends on,
+// directly or transitively, following edges of the types, or of any
+// type if there are none. A depth less than one does not limit the
+// search.
+func (c *Client) GetDepGraphDependencies(ctx context.Context, id, node string, depth int, types []string) (*DepGraphRelativesResponse, error) {
+	return c.getDepGraphRelatives(ctx, id, "dependencies", node, depth, types)
+}
+
+// GetDepGraphDependents returns the nodes that depend on the node, as
+// in GetDepGraphDependencies.
+func (c *Client) GetDepGraphDependents(ctx context.Context, id, node string, depth int, types []string) (*DepGraphRelativesResponse, error) {
+	return c.getDepGraphRelatives(ctx, id, "dependents", node, depth, types)
+}
+
+func (c *Client) getDepGraphRelatives(ctx context.Context, id, direction, node string, depth int, types []string) (*DepGraphRelativesResponse, error) {
+	query := depGraphQuery(types)
+	query.Set("node", node)
+	query.Set("depth", strconv.Itoa(depth))
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/%s?%s", id, direction, query.Encode()))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphRelativesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrapf(err, "problem reading graph %s", direction)
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// GetDepGraphPath returns the shortest chain of dependencies from one
+// node to the other, following edges of the types, or of any type if
+// there are none.
+func (c *Client) GetDepGraphPath(ctx context.Context, id, from, to string, types []string) (*DepGraphPathResponse, error) {
+	query := depGraphQuery(types)
+	query.Set("from", from)
+	query.Set("to", to)
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/path?%s", id, query.Encode()))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphPathResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph path")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out, nil
+}
+
+// DiffDepGraphs compares two graphs, returning the nodes and edges that
+// the second graph adds and removes.
+func (c *Client) DiffDepGraphs(ctx context.Context, from, to string) (*model.GraphDiff, error) {
+	query := url.Values{}
+	query.Set("from", from)
+	query.Set("to", to)
+
+	url := c.getURL("/v1/depgraph/diff?" + query.Encode())
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphDiffResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph diff")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Diff, nil
+}
+
+// GetDepGraphCycles returns the dependency cycles among the graph's
+// libraries.
+func (c *Client) GetDepGraphCycles(ctx context.Context, id string) ([]model.GraphCycle, error) {
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/cycles", id))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphCyclesResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph cycles")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Cycles, nil
+}
+
+// ExportDepGraph writes the part of the graph that the filter selects
+// to the writer, in either the model.GraphExportDOT or the
+// model.GraphExportGraphML format.
+func (c *Client) ExportDepGraph(ctx context.Context, id, format string, filter model.GraphExportFilter, w io.Writer) error {
+	query := url.Values{}
+	query.Set("format", format)
+	for _, t := range filter.Types {
+		query.Add("type", t.String())
+	}
+	if filter.Prefix != "" {
+		query.Set("prefix", filter.Prefix)
+	}
+	if filter.Node != "" {
+		query.Set("node", filter.Node)
+		query.Set("depth", strconv.Itoa(filter.Depth))
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/export?%s", id, query.Encode()))
+	grip.Debugln("GET", url)
+	resp, err := ctxhttp.Get(ctx, c.client, url)
+	if err != nil {
+		return errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		out := &depGraphExportErrorResponse{}
+		if err = gimlet.GetJSON(resp.Body, out); err != nil {
+			return errors.Wrap(err, "problem reading graph export error")
+		}
+
+		return errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return errors.Wrap(err, "problem reading graph export")
+}
+
+// GetDepGraphImpact returns the libraries and artifacts in the graph
+// that changes to the files affect.
+func (c *Client) GetDepGraphImpact(ctx context.Context, id string, files []string) (*model.GraphImpact, error) {
+	payload, err := json.Marshal(&depGraphImpactRequest{Files: files})
+	if err != nil {
+		return nil, errors.Wrap(err, "problem converting json")
+	}
+
+	url := c.getURL(fmt.Sprintf("/v1/depgraph/%s/impact", id))
+	grip.Debugln("POST", url)
+	resp, err := ctxhttp.Post(ctx, c.client, url, jsonMimeType, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with request")
+	}
+	defer resp.Body.Close()
+
+	out := &DepGraphImpactResponse{}
+	if err = gimlet.GetJSON(resp.Body, out); err != nil {
+		return nil, errors.Wrap(err, "problem reading graph impact")
+	}
+
+	if out.Error != "" {
+		return nil, errors.Errorf("encountered problem server-side: %s", out.Error)
+	}
+
+	return out.Impact, nil
+}
@@ -8,12 +8,15 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 
 	"github.com/evergreen-ci/sink"
 	"github.com/mongodb/amboy/queue"
 	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/net/context"
 )
@@ -255,3 +258,29 @@ func (s *ClientSuite) TestURLGenerationWithEmptyPrefix() {
 // Client/Service Interaction: Public Methods
 //
 ////////////////////////////////////////////////////////////////////////
+
+func (s *ClientSuite) TestGetSystemInformationFindsRecordsInTimeRange() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := NewClient(s.info.host, s.info.port, "")
+	s.Require().NoError(err)
+
+	host := "time-range-" + bson.NewObjectId().Hex()
+	ts := time.Now().UTC().Truncate(time.Second)
+
+	info := &message.SystemInfo{}
+	info.Hostname = host
+	info.Time = ts
+	resp, err := client.SendSystemInfo(ctx, info)
+	s.Require().NoError(err)
+	s.Equal("", resp.Error)
+
+	found, err := client.GetSystemInformation(ctx, host, ts.Add(-time.Minute), ts.Add(time.Minute), 10)
+	s.Require().NoError(err)
+	s.Len(found, 1)
+
+	found, err = client.GetSystemInformation(ctx, host, ts.Add(time.Minute), ts.Add(2*time.Minute), 10)
+	s.Require().NoError(err)
+	s.Len(found, 0)
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/phyber/negroni-gzip/gzip"
+	"github.com/tychoish/gimlet"
+	"github.com/urfave/negroni"
+)
+
+// uncompressedRoutes are the paths of routes that stream their
+// output. The gzip middleware buffers output until its writer fills,
+// so these routes are served without compression to reach clients as
+// they write.
+var uncompressedRoutes = map[string]bool{
+	"/v1/status/events/stream": true,
+}
+
+// setMiddleware installs the same middleware as gimlet's default
+// application, except that compression skips the streaming routes.
+func (s *Service) setMiddleware() {
+	s.app.ResetMiddleware()
+	s.app.AddMiddleware(negroni.NewRecovery())
+	s.app.AddMiddleware(gimlet.NewAppLogger())
+	s.app.AddMiddleware(&compression{
+		gzip: gzip.Gzip(gzip.DefaultCompression),
+		skip: uncompressedRoutes,
+	})
+}
+
+// compression passes requests for the skipped paths directly to the
+// next handler, and compresses the responses to all other requests.
+type compression struct {
+	gzip negroni.Handler
+	skip map[string]bool
+}
+
+func (c *compression) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if c.skip[strings.TrimSuffix(r.URL.Path, "/")] {
+		next(w, r)
+		return
+	}
+
+	c.gzip.ServeHTTP(w, r, next)
+}
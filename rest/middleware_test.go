@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/phyber/negroni-gzip/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionSkipsStreamingRoutes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	c := &compression{
+		gzip: gzip.Gzip(gzip.DefaultCompression),
+		skip: uncompressedRoutes,
+	}
+
+	stream := func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		require.True(ok)
+
+		_, err := w.Write([]byte("data: event\n\n"))
+		require.NoError(err)
+		flusher.Flush()
+	}
+
+	// clients that accept gzip without asking for an event stream
+	// still receive the stream as it is written.
+	req := httptest.NewRequest(http.MethodGet, "/v1/status/events/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req, stream)
+	assert.Equal("", rec.Header().Get("Content-Encoding"))
+	assert.True(rec.Flushed)
+	assert.Equal("data: event\n\n", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/status/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte("{}"))
+		require.NoError(err)
+	})
+	assert.Equal("gzip", rec.Header().Get("Content-Encoding"))
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evergreen-ci/sink/model"
+)
+
+const (
+	promGauge     = "gauge"
+	promCounter   = "counter"
+	promMimeType  = "text/plain; version=0.0.4; charset=utf-8"
+	promNamespace = "sink_host"
+)
+
+// promMetrics collects samples for metric families and renders them
+// in the prometheus text exposition format. Families are rendered in
+// the order that they were first added.
+type promMetrics struct {
+	families map[string]*promFamily
+	order    []string
+}
+
+type promFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []string
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{families: map[string]*promFamily{}}
+}
+
+// add records a sample for a metric. Labels are specified as
+// alternating names and values.
+func (m *promMetrics) add(name, kind, help string, value float64, labels ...string) {
+	name = promNamespace + "_" + name
+	family, ok := m.families[name]
+	if !ok {
+		family = &promFamily{name: name, kind: kind, help: help}
+		m.families[name] = family
+		m.order = append(m.order, name)
+	}
+
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapePromLabel(labels[i+1])))
+	}
+
+	family.samples = append(family.samples, fmt.Sprintf("%s{%s} %s",
+		name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'g', -1, 64)))
+}
+
+func (m *promMetrics) String() string {
+	buf := &bytes.Buffer{}
+
+	for _, name := range m.order {
+		family := m.families[name]
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.name, family.kind)
+		for _, sample := range family.samples {
+			buf.WriteString(sample)
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
+
+func escapePromLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// addSystemInfo adds the metrics from a single host's system
+// information record.
+func (m *promMetrics) addSystemInfo(rec *model.SystemInformationRecord) {
+	host := rec.Hostname
+	info := rec.Data
+
+	m.add("last_sample_timestamp_seconds", promGauge, "time of the most recent system information sample",
+		float64(rec.Timestamp.Unix()), "host", host)
+
+	m.add("cpu_count", promGauge, "number of logical cpus", float64(info.NumCPU), "host", host)
+	for _, cpu := range []struct {
+		mode  string
+		value float64
+	}{
+		{"user", info.CPU.User},
+		{"system", info.CPU.System},
+		{"idle", info.CPU.Idle},
+		{"nice", info.CPU.Nice},
+		{"iowait", info.CPU.Iowait},
+		{"irq", info.CPU.Irq},
+		{"softirq", info.CPU.Softirq},
+		{"steal", info.CPU.Steal},
+	} {
+		m.add("cpu_seconds_total", promCounter, "seconds the cpus spent in each mode",
+			cpu.value, "host", host, "mode", cpu.mode)
+	}
+
+	for _, mem := range []struct {
+		kind  string
+		value uint64
+	}{
+		{"total", info.VMStat.Total},
+		{"available", info.VMStat.Available},
+		{"used", info.VMStat.Used},
+		{"free", info.VMStat.Free},
+	} {
+		m.add("memory_bytes", promGauge, "memory usage in bytes",
+			float64(mem.value), "host", host, "type", mem.kind)
+	}
+	m.add("memory_used_percent", promGauge, "percentage of memory in use",
+		info.VMStat.UsedPercent, "host", host)
+
+	for _, usage := range info.Usage {
+		for _, disk := range []struct {
+			kind  string
+			value uint64
+		}{
+			{"total", usage.Total},
+			{"used", usage.Used},
+			{"free", usage.Free},
+		} {
+			m.add("disk_bytes", promGauge, "filesystem usage in bytes",
+				float64(disk.value), "host", host, "mountpoint", usage.Path, "fstype", usage.Fstype, "type", disk.kind)
+		}
+		m.add("disk_used_percent", promGauge, "percentage of the filesystem in use",
+			usage.UsedPercent, "host", host, "mountpoint", usage.Path, "fstype", usage.Fstype)
+		m.add("disk_inodes_used_percent", promGauge, "percentage of filesystem inodes in use",
+			usage.InodesUsedPercent, "host", host, "mountpoint", usage.Path, "fstype", usage.Fstype)
+	}
+
+	net := info.NetStat
+	for _, counter := range []struct {
+		name      string
+		help      string
+		direction string
+		value     uint64
+	}{
+		{"network_bytes_total", "bytes transferred on all interfaces", "sent", net.BytesSent},
+		{"network_bytes_total", "bytes transferred on all interfaces", "received", net.BytesRecv},
+		{"network_packets_total", "packets transferred on all interfaces", "sent", net.PacketsSent},
+		{"network_packets_total", "packets transferred on all interfaces", "received", net.PacketsRecv},
+		{"network_errors_total", "network errors on all interfaces", "sent", net.Errout},
+		{"network_errors_total", "network errors on all interfaces", "received", net.Errin},
+		{"network_dropped_total", "packets dropped on all interfaces", "sent", net.Dropout},
+		{"network_dropped_total", "packets dropped on all interfaces", "received", net.Dropin},
+	} {
+		m.add(counter.name, promCounter, counter.help,
+			float64(counter.value), "host", host, "direction", counter.direction)
+	}
+}
@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("plain", escapePromLabel("plain"))
+	assert.Equal(`a\"b`, escapePromLabel(`a"b`))
+	assert.Equal(`a\\b`, escapePromLabel(`a\b`))
+	assert.Equal(`a\nb`, escapePromLabel("a\nb"))
+}
+
+func TestPrometheusRendersFamiliesOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	metrics := newPromMetrics()
+	for _, host := range []string{"one", "two"} {
+		rec := &model.SystemInformationRecord{Hostname: host, Timestamp: time.Now()}
+		rec.Data.NumCPU = 4
+		metrics.addSystemInfo(rec)
+	}
+
+	out := metrics.String()
+	assert.Equal(1, strings.Count(out, "# TYPE sink_host_cpu_count gauge\n"))
+	assert.Equal(1, strings.Count(out, "# TYPE sink_host_cpu_seconds_total counter\n"))
+	assert.Contains(out, "sink_host_cpu_count{host=\"one\"} 4\n")
+	assert.Contains(out, "sink_host_cpu_count{host=\"two\"} 4\n")
+	assert.Contains(out, "sink_host_network_bytes_total{host=\"two\",direction=\"received\"} 0\n")
+}
@@ -1,9 +1,14 @@
 package rest
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/evergreen-ci/sink"
@@ -15,6 +20,8 @@ import (
 	"github.com/mongodb/grip"
 	"github.com/mongodb/grip/level"
 	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
 	"github.com/tychoish/gimlet"
 )
 
@@ -42,58 +49,149 @@ func (s *Service) statusHandler(w http.ResponseWriter, r *http.Request) {
 
 ////////////////////////////////////////////////////////////////////////
 //
-// GET /status/events/{level}?limit=<int>
+// GET /status/events?component=<name>&level=<level>&min_level=<level>&max_level=<level>&start=<timestamp>&end=<timestamp>&acknowledged=<bool>&limit=<int>&cursor=<id>
+//
+// Lists events that match all of the specified filters, most recently
+// first seen first. The start and end filters bound the time that
+// events were first seen, not their latest occurrence.
+// The level filter selects one level, and the min_level and max_level
+// filters select a range of levels. The limit defaults to 100 events;
+// when there may be more events, the response includes a cursor that
+// returns the next page.
 
 type SystemEventsResponse struct {
 	Level  string         `json:"level,omitempty"`
 	Total  int            `json:"total,omitempty"`
 	Count  int            `json:"count,omitempty"`
+	Next   string         `json:"next,omitempty"`
 	Events []*model.Event `json:"events"`
 	Err    string         `json:"error"`
 }
 
-func (s *Service) getSystemEvents(w http.ResponseWriter, r *http.Request) {
-	l := gimlet.GetVars(r)["level"]
-	resp := &SystemEventsResponse{}
+// parseEventFilter returns the event filter specified by the request's
+// form values.
+func parseEventFilter(r *http.Request) (*model.EventFilter, error) {
+	filter := &model.EventFilter{Component: r.FormValue("component")}
 
-	if l == "" {
-		resp.Err = "no level specified"
-		gimlet.WriteErrorJSON(w, resp)
-		return
+	priority := func(name string) (level.Priority, error) {
+		arg := r.FormValue(name)
+		if arg == "" {
+			return level.Invalid, nil
+		}
+
+		p := level.FromString(arg)
+		if !level.IsValidPriority(p) {
+			return p, errors.Errorf("%s is not a valid level", arg)
+		}
+
+		return p, nil
+	}
+
+	var err error
+	if filter.MinLevel, err = priority("min_level"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if filter.MaxLevel, err = priority("max_level"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if arg := r.FormValue("level"); arg != "" {
+		if filter.MinLevel != level.Invalid || filter.MaxLevel != level.Invalid {
+			return nil, errors.New("cannot specify both a level and a level range")
+		}
+
+		if filter.MinLevel, err = priority("level"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		filter.MaxLevel = filter.MinLevel
+	}
+
+	for name, ts := range map[string]*time.Time{"start": &filter.Start, "end": &filter.End} {
+		if arg := r.FormValue(name); arg != "" {
+			*ts, err = time.Parse(time.RFC3339, arg)
+			if err != nil {
+				return nil, errors.Errorf("could not parse time string '%s' in to RFC3339: %s", arg, err.Error())
+			}
+		}
+	}
+
+	if arg := r.FormValue("acknowledged"); arg != "" {
+		ack, err := strconv.ParseBool(arg)
+		if err != nil {
+			return nil, errors.Errorf("'%s' is not a valid acknowledgment state", arg)
+		}
+		filter.Acknowledged = &ack
 	}
 
-	if !level.IsValidPriority(level.FromString(l)) {
-		resp.Err = fmt.Sprintf("%s is not a valid level", l)
+	if err = filter.Validate(); err != nil {
+		return nil, errors.WithStack(err)
 	}
-	resp.Level = l
 
-	limitArg := r.URL.Query()["limit"][0]
-	limit, err := strconv.Atoi(limitArg)
+	return filter, nil
+}
+
+func (s *Service) getSystemEvents(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemEventsResponse{}
+
+	filter, err := parseEventFilter(r)
 	if err != nil {
-		resp.Err = fmt.Sprintf("%s is not a valid limit [%s]", limitArg, err.Error())
+		resp.Err = err.Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
 	}
 
+	s.listSystemEvents(w, r, filter, resp)
+}
+
+func (s *Service) listSystemEvents(w http.ResponseWriter, r *http.Request, filter *model.EventFilter, resp *SystemEventsResponse) {
+	limit := 100
+	if arg := r.FormValue("limit"); arg != "" {
+		var err error
+		limit, err = strconv.Atoi(arg)
+		if err != nil {
+			resp.Err = fmt.Sprintf("%s is not a valid limit [%s]", arg, err.Error())
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
 	e := &model.Events{}
-	err = e.FindLevel(l, limit)
-	if err != nil {
-		resp.Err = "problem running query for events"
+	if err := e.FindPage(filter, r.FormValue("cursor"), limit); err != nil {
+		resp.Err = fmt.Sprintf("problem running query for events: %s", err.Error())
 		gimlet.WriteErrorJSON(w, resp)
 		return
 	}
 
+	var err error
 	resp.Events = e.Slice()
-	resp.Total, err = e.CountLevel(l)
+	resp.Total, err = e.CountMatching(filter)
 	if err != nil {
 		resp.Err = fmt.Sprintf("problem fetching errors: %+v", err)
 		gimlet.WriteErrorJSON(w, resp)
 		return
 	}
 	resp.Count = len(resp.Events)
+
+	if limit > 0 && resp.Count == limit {
+		resp.Next = resp.Events[resp.Count-1].ID.Hex()
+	}
+
 	gimlet.WriteJSON(w, resp)
 }
 
+////////////////////////////////////////////////////////////////////////
+//
+// GET /status/events/{level}
+//
+// Deprecated: use /status/events?level=<level>. The route only
+// matches level names, and lists the events at that level.
+
+func (s *Service) getSystemEventsByLevel(w http.ResponseWriter, r *http.Request) {
+	p := level.FromString(gimlet.GetVars(r)["level"])
+	s.listSystemEvents(w, r, &model.EventFilter{MinLevel: p, MaxLevel: p},
+		&SystemEventsResponse{Level: p.String()})
+}
+
 ////////////////////////////////////////////////////////////////////////
 //
 // GET /status/events/{id}
@@ -129,9 +227,40 @@ func (s *Service) getSystembEvent(w http.ResponseWriter, r *http.Request) {
 //
 // POST /status/events/{id}/acknowledge
 //
-// (nothing is read from the body)
+// body: { "user": <str>, "note": <str> }
+
+type eventTransitionRequest struct {
+	State    string    `json:"state"`
+	User     string    `json:"user"`
+	Assignee string    `json:"assignee,omitempty"`
+	Note     string    `json:"note"`
+	Until    time.Time `json:"until"`
+}
 
 func (s *Service) acknowledgeSystemEvent(w http.ResponseWriter, r *http.Request) {
+	s.transitionSystemEventTo(w, r, model.EventAcknowledged)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /status/events/{id}/transition
+//
+// body: { "state": <str>, "user": <str>, "note": <str>, "until": <date>, "assignee": <str> }
+//
+// Moves the event to the acknowledged, snoozed, resolved, or reopened
+// state, recording the user and the optional note in the event's
+// history. Snoozing requires the time the snooze ends, after which
+// the event reopens. The "assign" state assigns the event to the
+// assignee, or clears its assignee if the assignee is empty, without
+// changing its state.
+
+func (s *Service) transitionSystemEvent(w http.ResponseWriter, r *http.Request) {
+	s.transitionSystemEventTo(w, r, "")
+}
+
+// transitionSystemEventTo moves the event to the state, or, if the
+// state is empty, to the state specified in the request body.
+func (s *Service) transitionSystemEventTo(w http.ResponseWriter, r *http.Request, state string) {
 	id := gimlet.GetVars(r)["id"]
 	resp := &SystemEventResponse{}
 	if id == "" {
@@ -141,6 +270,18 @@ func (s *Service) acknowledgeSystemEvent(w http.ResponseWriter, r *http.Request)
 	}
 	resp.ID = id
 
+	req := &eventTransitionRequest{}
+	if r.ContentLength != 0 {
+		if err := gimlet.GetJSON(r.Body, req); err != nil {
+			resp.Error = fmt.Sprintf("problem parsing request: %s", err.Error())
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+	if state != "" {
+		req.State = state
+	}
+
 	event := &model.Event{}
 	if err := event.FindID(id); err != nil {
 		resp.Error = err.Error()
@@ -149,7 +290,68 @@ func (s *Service) acknowledgeSystemEvent(w http.ResponseWriter, r *http.Request)
 	}
 	resp.Event = event
 
-	if err := event.Acknowledge(); err != nil {
+	var err error
+	if req.State == model.EventAssign {
+		if !req.Until.IsZero() {
+			err = errors.New("only snoozed events have an end time")
+		} else {
+			err = event.Assign(req.Assignee, req.User, req.Note)
+		}
+	} else if req.Assignee != "" {
+		err = errors.New("only assign transitions have an assignee")
+	} else {
+		err = event.Transition(req.State, req.User, req.Note, req.Until)
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /status/events/acknowledge?<filters>
+//
+// body: { "ids": [ <id>, ... ], "user": <str>, "note": <str> }
+//
+// Acknowledges the open events with the specified ids, the events
+// that match the filters (as for GET /status/events), or, if both are
+// specified, the events that satisfy both.
+
+type acknowledgeEventsRequest struct {
+	IDs  []string `json:"ids"`
+	User string   `json:"user"`
+	Note string   `json:"note"`
+}
+
+type AcknowledgeEventsResponse struct {
+	Acknowledged int    `json:"acknowledged"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (s *Service) acknowledgeSystemEvents(w http.ResponseWriter, r *http.Request) {
+	resp := &AcknowledgeEventsResponse{}
+
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	req := &acknowledgeEventsRequest{}
+	if err = gimlet.GetJSON(r.Body, req); err != nil {
+		resp.Error = fmt.Sprintf("problem parsing request: %s", err.Error())
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Acknowledged, err = model.AcknowledgeEvents(req.IDs, filter, req.User, req.Note)
+	if err != nil {
 		resp.Error = err.Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
@@ -158,6 +360,82 @@ func (s *Service) acknowledgeSystemEvent(w http.ResponseWriter, r *http.Request)
 	gimlet.WriteJSON(w, resp)
 }
 
+////////////////////////////////////////////////////////////////////////
+//
+// GET /status/events/stream?component=<name>&level=<level>&min_level=<level>&max_level=<level>
+//
+// Streams events as server-sent events as any sink process stores
+// them, using the same filters as the event listing. Each message's
+// data is one event as JSON, sent again each time the event recurs;
+// comment lines keep idle connections open.
+
+const (
+	eventStreamInterval  = time.Second
+	eventStreamHeartbeat = 30 * time.Second
+)
+
+func (s *Service) streamSystemEvents(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemEventsResponse{}
+
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		resp.Err = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		resp.Err = "streaming is not supported"
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	follower := model.NewEventFollower(filter, time.Now())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	poll := time.NewTicker(eventStreamInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-poll.C:
+			events, err := follower.Next()
+			if err != nil {
+				grip.Warning(errors.Wrap(err, "problem following events for stream"))
+				continue
+			}
+
+			for _, e := range events {
+				data, err := json.Marshal(e)
+				if err != nil {
+					grip.Warning(errors.Wrap(err, "problem encoding event for stream"))
+					continue
+				}
+
+				if _, err = fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", e.ID.Hex(), data); err != nil {
+					return
+				}
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 //
 // POST /simple_log/{id}
@@ -275,9 +553,12 @@ func (s *Service) simpleLogGetText(w http.ResponseWriter, r *http.Request) {
 
 ////////////////////////////////////////////////////////////////////////
 //
-// POST /system_info/
+// POST /system_info/?label=<name=value>
 //
 // body: json produced by grip/message.SystemInfo documents
+//
+// The label may be repeated to attach labels (e.g. role, distro) to
+// the stored record.
 
 type SystemInfoReceivedResponse struct {
 	ID        string    `json:"id,omitempty"`
@@ -298,10 +579,18 @@ func (s *Service) recieveSystemInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	labels, err := model.ParseSystemInfoLabels(r.URL.Query()["label"])
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
 	data := &model.SystemInformationRecord{
 		Data:      req,
 		Hostname:  req.Hostname,
 		Timestamp: req.Time,
+		Labels:    labels,
 	}
 
 	if data.Timestamp.IsZero() {
@@ -316,6 +605,194 @@ func (s *Service) recieveSystemInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp.ID = string(data.ID)
+
+	rules := &model.AlertRules{}
+	if err := rules.FindHostname(data.Hostname); err != nil {
+		grip.Warning(errors.Wrap(err, "problem finding alert rules"))
+	} else {
+		sender := sink.GetSystemSender()
+		for _, rule := range rules.Slice() {
+			grip.Warning(errors.Wrapf(rule.Evaluate(data, sender),
+				"problem evaluating alert rule '%s' for '%s'", rule.ID, data.Hostname))
+		}
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /system_info/containers
+//
+// body: json of a model.ContainerInfoRecord, which holds the cgroup
+// accounting collected from inside of a container.
+
+type ContainerInfoReceivedResponse struct {
+	ID        string    `json:"id,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Timestamp time.Time `json:"time,omitempty"`
+	Error     string    `json:"err,omitempty"`
+}
+
+func (s *Service) recieveContainerInfo(w http.ResponseWriter, r *http.Request) {
+	resp := &ContainerInfoReceivedResponse{}
+	data := &model.ContainerInfoRecord{}
+	defer r.Body.Close()
+
+	if err := gimlet.GetJSON(r.Body, data); err != nil {
+		grip.Error(err)
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	data.ID = ""
+	if data.Timestamp.IsZero() {
+		data.Timestamp = time.Now()
+	}
+
+	if err := data.Insert(); err != nil {
+		grip.Error(err)
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.ID = data.ID.Hex()
+	resp.Container = data.Container
+	resp.Timestamp = data.Timestamp
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/containers?host=[host]&container=[name]&start=[timestamp]&end=[timestamp]&limit=[num]
+//
+// Returns container samples in time order. The window defaults to the
+// last day, and the limit to 100 samples.
+
+type ContainerInfoResponse struct {
+	Error string                       `json:"error,omitempty"`
+	Limit int                          `json:"limit,omitempty"`
+	Data  []*model.ContainerInfoRecord `json:"data"`
+}
+
+func (s *Service) fetchContainerInfo(w http.ResponseWriter, r *http.Request) {
+	resp := &ContainerInfoResponse{Limit: 100}
+
+	start, end, err := parseSystemInfoWindow(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if arg := r.FormValue("limit"); arg != "" {
+		resp.Limit, err = strconv.Atoi(arg)
+		if err != nil {
+			resp.Error = err.Error()
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	out := &model.ContainerInfoRecords{}
+	if err = out.FindBetween(r.FormValue("host"), r.FormValue("container"), start, end, resp.Limit); err != nil {
+		resp.Error = fmt.Sprintf("could not retrieve results, %s", err.Error())
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Data = out.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /system_info/processes
+//
+// body: json of a model.ProcessSnapshotRecord
+
+type ProcessSnapshotReceivedResponse struct {
+	ID        string    `json:"id,omitempty"`
+	Hostname  string    `json:"host,omitempty"`
+	Timestamp time.Time `json:"time,omitempty"`
+	Error     string    `json:"err,omitempty"`
+}
+
+func (s *Service) recieveProcessSnapshot(w http.ResponseWriter, r *http.Request) {
+	resp := &ProcessSnapshotReceivedResponse{}
+	data := &model.ProcessSnapshotRecord{}
+	defer r.Body.Close()
+
+	if err := gimlet.GetJSON(r.Body, data); err != nil {
+		grip.Error(err)
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	data.ID = ""
+	if data.Timestamp.IsZero() {
+		data.Timestamp = time.Now()
+	}
+
+	if err := data.Insert(); err != nil {
+		grip.Error(err)
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.ID = data.ID.Hex()
+	resp.Hostname = data.Hostname
+	resp.Timestamp = data.Timestamp
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/processes/{host}?start=[timestamp]&end=[timestamp]&limit=[num]
+//
+// Returns the host's process snapshots in time order. The window
+// defaults to the last day, and the limit to 100 snapshots.
+
+type ProcessSnapshotResponse struct {
+	Error string                         `json:"error,omitempty"`
+	Limit int                            `json:"limit,omitempty"`
+	Data  []*model.ProcessSnapshotRecord `json:"data"`
+}
+
+func (s *Service) fetchProcessSnapshots(w http.ResponseWriter, r *http.Request) {
+	resp := &ProcessSnapshotResponse{Limit: 100}
+	host := gimlet.GetVars(r)["host"]
+
+	start, end, err := parseSystemInfoWindow(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if arg := r.FormValue("limit"); arg != "" {
+		resp.Limit, err = strconv.Atoi(arg)
+		if err != nil {
+			resp.Error = err.Error()
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	out := &model.ProcessSnapshotRecords{}
+	if err = out.FindHostnameBetween(host, start, end, resp.Limit); err != nil {
+		resp.Error = fmt.Sprintf("could not retrieve results, %s", err.Error())
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Data = out.Slice()
 	gimlet.WriteJSON(w, resp)
 }
 
@@ -402,19 +879,832 @@ func (s *Service) fetchSystemInfo(w http.ResponseWriter, r *http.Request) {
 
 ////////////////////////////////////////////////////////////////////////
 //
-// POST /depgraph/{id}
+// GET /system_info/host/{host}/export?start=[timestamp]<,end=[timestamp],format=[csv|jsonl]>
+//
+// Streams all system information documents for the host in the time
+// range. The csv format flattens each document into one row, with
+// repeated columns for every partition; the jsonl format writes one
+// message.SystemInfo document per line, which is the same format
+// that the sysinfo import command reads.
 
-type createDepGraphResponse struct {
+func (s *Service) exportSystemInfo(w http.ResponseWriter, r *http.Request) {
+	host := gimlet.GetVars(r)["host"]
+	if host == "" {
+		gimlet.WriteErrorText(w, "no host specified")
+		return
+	}
+
+	startArg := r.FormValue("start")
+	if startArg == "" {
+		gimlet.WriteErrorText(w, "no start time argument")
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, startArg)
+	if err != nil {
+		gimlet.WriteErrorText(w, fmt.Sprintf("could not parse time string '%s' in to RFC3339: %s",
+			startArg, err.Error()))
+		return
+	}
+
+	end := time.Now()
+	if endArg := r.FormValue("end"); endArg != "" {
+		end, err = time.Parse(time.RFC3339, endArg)
+		if err != nil {
+			gimlet.WriteErrorText(w, err.Error())
+			return
+		}
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = model.SystemInfoExportCSV
+	}
+
+	records := &model.SystemInformationRecords{}
+	flusher, canFlush := w.(http.Flusher)
+	var count int
+
+	switch format {
+	case model.SystemInfoExportCSV:
+		partitions, err := records.MaxPartitionsBetween(host, start, end)
+		if err != nil {
+			gimlet.WriteInternalErrorText(w, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		writer := csv.NewWriter(w)
+		if err = writer.Write(model.SystemInfoCSVHeader(partitions)); err != nil {
+			grip.Warning(err)
+			return
+		}
+
+		err = records.StreamHostnameBetween(host, start, end, func(rec *model.SystemInformationRecord) error {
+			if err := writer.Write(rec.CSVRow(partitions)); err != nil {
+				return err
+			}
+
+			count++
+			if count%100 == 0 {
+				writer.Flush()
+				if canFlush {
+					flusher.Flush()
+				}
+			}
+
+			return writer.Error()
+		})
+		writer.Flush()
+		grip.Warning(errors.Wrapf(err, "problem exporting system info for '%s'", host))
+	case model.SystemInfoExportJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+
+		err = records.StreamHostnameBetween(host, start, end, func(rec *model.SystemInformationRecord) error {
+			if err := encoder.Encode(rec.Data); err != nil {
+				return err
+			}
+
+			count++
+			if count%100 == 0 && canFlush {
+				flusher.Flush()
+			}
+
+			return nil
+		})
+		grip.Warning(errors.Wrapf(err, "problem exporting system info for '%s'", host))
+	default:
+		gimlet.WriteErrorText(w, fmt.Sprintf("'%s' is not a supported export format", format))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /metrics/hosts?window=<duration>
+//
+// Renders the most recent system information sample for every host
+// that reported within the window (default: one hour) in the
+// prometheus text exposition format.
+
+func (s *Service) hostMetrics(w http.ResponseWriter, r *http.Request) {
+	window := time.Hour
+	if arg := r.FormValue("window"); arg != "" {
+		var err error
+		window, err = time.ParseDuration(arg)
+		if err != nil {
+			gimlet.WriteErrorText(w, fmt.Sprintf("could not parse window '%s': %s", arg, err.Error()))
+			return
+		}
+	}
+
+	records := &model.SystemInformationRecords{}
+	if err := records.FindLatestPerHost(time.Now().Add(-window)); err != nil {
+		gimlet.WriteInternalErrorText(w, err.Error())
+		return
+	}
+
+	metrics := newPromMetrics()
+	for _, rec := range records.Slice() {
+		metrics.addSystemInfo(rec)
+	}
+
+	w.Header().Set("Content-Type", promMimeType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(metrics.String())); err != nil {
+		grip.Warning(errors.Wrap(err, "problem writing host metrics"))
+	}
+}
+
+// parseSystemInfoWindow returns the time window specified by the start
+// and end RFC3339 form values, which default to the last day.
+func parseSystemInfoWindow(r *http.Request) (time.Time, time.Time, error) {
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+
+	var err error
+	if arg := r.FormValue("start"); arg != "" {
+		start, err = time.Parse(time.RFC3339, arg)
+		if err != nil {
+			return start, end, errors.Errorf("could not parse time string '%s' in to RFC3339: %s", arg, err.Error())
+		}
+	}
+
+	if arg := r.FormValue("end"); arg != "" {
+		end, err = time.Parse(time.RFC3339, arg)
+		if err != nil {
+			return start, end, errors.Errorf("could not parse time string '%s' in to RFC3339: %s", arg, err.Error())
+		}
+	}
+
+	return start, end, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/top/{metric}?start=<timestamp>&end=<timestamp>&limit=<n>
+//
+// Ranks all hosts that reported system information in the window by
+// the metric (memory, cpu, or disk). The window defaults to the last
+// day, and the limit to ten hosts.
+
+type SystemInfoTopResponse struct {
+	Metric string                     `json:"metric"`
+	Start  time.Time                  `json:"start"`
+	End    time.Time                  `json:"end"`
+	Error  string                     `json:"error,omitempty"`
+	Hosts  []*model.HostMetricSummary `json:"hosts"`
+}
+
+func (s *Service) getSystemInfoTop(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoTopResponse{Metric: gimlet.GetVars(r)["metric"]}
+
+	var err error
+	resp.Start, resp.End, err = parseSystemInfoWindow(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	limit := 10
+	if arg := r.FormValue("limit"); arg != "" {
+		limit, err = strconv.Atoi(arg)
+		if err != nil || limit < 1 {
+			resp.Error = fmt.Sprintf("'%s' is not a valid limit", arg)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	switch resp.Metric {
+	case model.SystemInfoMetricMemory, model.SystemInfoMetricCPU, model.SystemInfoMetricDisk:
+	default:
+		resp.Error = fmt.Sprintf("'%s' is not a supported metric", resp.Metric)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	summaries := &model.HostMetricSummaries{}
+	if err = summaries.FindTop(resp.Metric, resp.Start, resp.End, limit); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Hosts = summaries.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/group/{label}/{metric}?start=<timestamp>&end=<timestamp>&filter=<name=value>
+//
+// Compares groups of hosts that share a value for the label by the
+// metric (memory, cpu, or disk). The filter may be repeated to only
+// consider records with all of the specified labels.
+
+type SystemInfoGroupResponse struct {
+	Label  string                      `json:"label"`
+	Metric string                      `json:"metric"`
+	Filter map[string]string           `json:"filter,omitempty"`
+	Start  time.Time                   `json:"start"`
+	End    time.Time                   `json:"end"`
+	Error  string                      `json:"error,omitempty"`
+	Groups []*model.LabelMetricSummary `json:"groups"`
+}
+
+func (s *Service) getSystemInfoGrouped(w http.ResponseWriter, r *http.Request) {
+	vars := gimlet.GetVars(r)
+	resp := &SystemInfoGroupResponse{
+		Label:  vars["label"],
+		Metric: vars["metric"],
+	}
+
+	var err error
+	resp.Start, resp.End, err = parseSystemInfoWindow(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Filter, err = model.ParseSystemInfoLabels(r.URL.Query()["filter"])
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	switch resp.Metric {
+	case model.SystemInfoMetricMemory, model.SystemInfoMetricCPU, model.SystemInfoMetricDisk:
+	default:
+		resp.Error = fmt.Sprintf("'%s' is not a supported metric", resp.Metric)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err = model.ValidateSystemInfoLabels(map[string]string{resp.Label: ""}); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	summaries := &model.LabelMetricSummaries{}
+	if err = summaries.FindGrouped(resp.Label, resp.Metric, resp.Filter, resp.Start, resp.End); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Groups = summaries.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/forecast/disk?host=<host>&window=<duration>&days=<n>
+//
+// Predicts when each partition will fill from a linear fit of its used
+// space over the window (default: one week). If days is specified,
+// only partitions predicted to fill within that many days are
+// returned.
+
+type DiskForecastResponse struct {
+	Window    string                `json:"window"`
+	Error     string                `json:"error,omitempty"`
+	Forecasts []*model.DiskForecast `json:"forecasts"`
+}
+
+func (s *Service) getDiskForecast(w http.ResponseWriter, r *http.Request) {
+	window := 7 * 24 * time.Hour
+	resp := &DiskForecastResponse{}
+
+	var err error
+	if arg := r.FormValue("window"); arg != "" {
+		window, err = time.ParseDuration(arg)
+		if err != nil || window <= 0 {
+			resp.Error = fmt.Sprintf("'%s' is not a valid window", arg)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+	resp.Window = window.String()
+
+	days := -1.0
+	if arg := r.FormValue("days"); arg != "" {
+		days, err = strconv.ParseFloat(arg, 64)
+		if err != nil {
+			resp.Error = fmt.Sprintf("'%s' is not a valid number of days", arg)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	now := time.Now()
+	forecasts := &model.DiskForecasts{}
+	if err = forecasts.FindBetween(r.FormValue("host"), now.Add(-window), now); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if days >= 0 {
+		resp.Forecasts = forecasts.AtRisk(days)
+	} else {
+		resp.Forecasts = forecasts.Slice()
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/anomalies?host=<host>&metric=<metric>&start=<timestamp>&end=<timestamp>&limit=<n>
+//
+// Lists the samples flagged as anomalous in the window, most recent
+// first. The window defaults to the last day, and the limit to 100
+// anomalies.
+
+type SystemInfoAnomaliesResponse struct {
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Error     string           `json:"error,omitempty"`
+	Anomalies []*model.Anomaly `json:"anomalies"`
+}
+
+func (s *Service) getSystemInfoAnomalies(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoAnomaliesResponse{}
+
+	var err error
+	resp.Start, resp.End, err = parseSystemInfoWindow(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	limit := 100
+	if arg := r.FormValue("limit"); arg != "" {
+		limit, err = strconv.Atoi(arg)
+		if err != nil || limit < 1 {
+			resp.Error = fmt.Sprintf("'%s' is not a valid limit", arg)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	metric := r.FormValue("metric")
+	switch metric {
+	case "", model.AnomalyMetricCPU, model.AnomalyMetricMemory, model.AnomalyMetricNetworkSent, model.AnomalyMetricNetworkRecv:
+	default:
+		resp.Error = fmt.Sprintf("'%s' is not a supported metric", metric)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	anomalies := &model.Anomalies{}
+	if err = anomalies.FindBetween(r.FormValue("host"), metric, resp.Start, resp.End, limit); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Anomalies = anomalies.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/alerts
+
+type SystemInfoAlertsResponse struct {
+	Error  string              `json:"error,omitempty"`
+	Rules  []*model.AlertRule  `json:"rules"`
+	Firing []*model.AlertState `json:"firing"`
+}
+
+func (s *Service) getSystemInfoAlertRules(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoAlertsResponse{}
+
+	rules := &model.AlertRules{}
+	if err := rules.FindAll(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+	resp.Rules = rules.Slice()
+
+	states := &model.AlertStates{}
+	if err := states.FindFiring(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+	resp.Firing = states.Slice()
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /system_info/alerts/{id}
+//
+// body: { "metric": <str>, "op": "gt|lt", "threshold": <num>, "duration": <duration str>,
+//         "level": <str>, "host": <str> }
+
+type alertRuleRequest struct {
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	Duration  string  `json:"duration"`
+	Level     string  `json:"level"`
+	Hostname  string  `json:"host"`
+}
+
+type SystemInfoAlertResponse struct {
+	ID    string           `json:"id"`
+	Error string           `json:"error,omitempty"`
+	Rule  *model.AlertRule `json:"rule,omitempty"`
+}
+
+func (s *Service) setSystemInfoAlertRule(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoAlertResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+	req := &alertRuleRequest{}
+
+	if err := gimlet.GetJSON(r.Body, req); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	rule := &model.AlertRule{
+		ID:        resp.ID,
+		Metric:    req.Metric,
+		Operator:  req.Operator,
+		Threshold: req.Threshold,
+		Level:     req.Level,
+		Hostname:  req.Hostname,
+	}
+
+	if req.Duration != "" {
+		dur, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			resp.Error = fmt.Sprintf("could not parse duration '%s': %s", req.Duration, err.Error())
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+		rule.Duration = dur
+	}
+
+	if err := rule.Validate(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err := rule.Save(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /system_info/alerts/{id}
+
+func (s *Service) getSystemInfoAlertRule(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoAlertResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+
+	rule := &model.AlertRule{}
+	if err := rule.Find(resp.ID); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if rule.IsNil() {
+		resp.Error = fmt.Sprintf("alert rule '%s' does not exist", resp.ID)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// DELETE /system_info/alerts/{id}
+
+func (s *Service) removeSystemInfoAlertRule(w http.ResponseWriter, r *http.Request) {
+	resp := &SystemInfoAlertResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+
+	rule := &model.AlertRule{}
+	if err := rule.Find(resp.ID); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if rule.IsNil() {
+		resp.Error = fmt.Sprintf("alert rule '%s' does not exist", resp.ID)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err := rule.Remove(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /notifications/rules
+
+type NotificationRulesResponse struct {
+	Error string                    `json:"error,omitempty"`
+	Rules []*model.NotificationRule `json:"rules"`
+}
+
+func (s *Service) getNotificationRules(w http.ResponseWriter, r *http.Request) {
+	resp := &NotificationRulesResponse{}
+
+	rules := &model.NotificationRules{}
+	if err := rules.FindAll(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rules = rules.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /notifications/rules/{id}
+//
+// body: { "level": <str>, "component": <str>, "type": <str>, "max_attempts": <int>,
+//         "target": { "type": "webhook|email|slack", "url": <str>,
+//                     "smtp_server": <str>, "smtp_port": <int>, "ssl": <bool>,
+//                     "username": <str>, "password": <str>, "password_env": <str>,
+//                     "from": <str>, "to": [<str>] } }
+
+type notificationTargetRequest struct {
+	Type        string   `json:"type"`
+	URL         string   `json:"url"`
+	SMTPServer  string   `json:"smtp_server"`
+	SMTPPort    int      `json:"smtp_port"`
+	UseSSL      bool     `json:"ssl"`
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	PasswordEnv string   `json:"password_env"`
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+}
+
+type notificationRuleRequest struct {
+	MinLevel    string                    `json:"level"`
+	Component   string                    `json:"component"`
+	MessageType string                    `json:"type"`
+	MaxAttempts int                       `json:"max_attempts"`
+	Target      notificationTargetRequest `json:"target"`
+}
+
+type NotificationRuleResponse struct {
+	ID    string                  `json:"id"`
+	Error string                  `json:"error,omitempty"`
+	Rule  *model.NotificationRule `json:"rule,omitempty"`
+}
+
+func (s *Service) setNotificationRule(w http.ResponseWriter, r *http.Request) {
+	resp := &NotificationRuleResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+	req := &notificationRuleRequest{}
+
+	if err := gimlet.GetJSON(r.Body, req); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	rule := &model.NotificationRule{
+		ID:          resp.ID,
+		MinLevel:    req.MinLevel,
+		Component:   req.Component,
+		MessageType: req.MessageType,
+		MaxAttempts: req.MaxAttempts,
+		Target: model.NotificationTarget{
+			Type:        req.Target.Type,
+			URL:         req.Target.URL,
+			SMTPServer:  req.Target.SMTPServer,
+			SMTPPort:    req.Target.SMTPPort,
+			UseSSL:      req.Target.UseSSL,
+			Username:    req.Target.Username,
+			Password:    req.Target.Password,
+			PasswordEnv: req.Target.PasswordEnv,
+			From:        req.Target.From,
+			To:          req.Target.To,
+		},
+	}
+
+	if err := rule.Validate(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err := rule.Save(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /notifications/rules/{id}
+
+func (s *Service) getNotificationRule(w http.ResponseWriter, r *http.Request) {
+	resp := &NotificationRuleResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+
+	rule := &model.NotificationRule{}
+	if err := rule.Find(resp.ID); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if rule.IsNil() {
+		resp.Error = fmt.Sprintf("notification rule '%s' does not exist", resp.ID)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// DELETE /notifications/rules/{id}
+
+func (s *Service) removeNotificationRule(w http.ResponseWriter, r *http.Request) {
+	resp := &NotificationRuleResponse{}
+	resp.ID = gimlet.GetVars(r)["id"]
+
+	rule := &model.NotificationRule{}
+	if err := rule.Find(resp.ID); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if rule.IsNil() {
+		resp.Error = fmt.Sprintf("notification rule '%s' does not exist", resp.ID)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err := rule.Remove(); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Rule = rule
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /notifications/deliveries?rule=<id>&status=<pending|delivered|failed>&limit=<n>
+//
+// Lists the delivery log, newest first. The limit defaults to 100
+// deliveries.
+
+type NotificationDeliveriesResponse struct {
+	Error      string                        `json:"error,omitempty"`
+	Deliveries []*model.NotificationDelivery `json:"deliveries"`
+}
+
+func (s *Service) getNotificationDeliveries(w http.ResponseWriter, r *http.Request) {
+	resp := &NotificationDeliveriesResponse{}
+
+	limit := 100
+	if arg := r.FormValue("limit"); arg != "" {
+		var err error
+		limit, err = strconv.Atoi(arg)
+		if err != nil || limit < 1 {
+			resp.Error = fmt.Sprintf("'%s' is not a valid limit", arg)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	status := r.FormValue("status")
+	switch status {
+	case "", model.NotificationPending, model.NotificationDelivered, model.NotificationFailed:
+	default:
+		resp.Error = fmt.Sprintf("'%s' is not a valid delivery status", status)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	deliveries := &model.NotificationDeliveries{}
+	if err := deliveries.Find(r.FormValue("rule"), status, limit); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Deliveries = deliveries.Slice()
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/diff
+
+type DepGraphDiffResponse struct {
+	Error string           `json:"error,omitempty"`
+	Diff  *model.GraphDiff `json:"diff,omitempty"`
+}
+
+func (s *Service) diffDepGraphs(w http.ResponseWriter, r *http.Request) {
+	resp := DepGraphDiffResponse{}
+	fromID := r.FormValue("from")
+	toID := r.FormValue("to")
+
+	if fromID == "" || toID == "" {
+		resp.Error = "must specify the graphs to compare"
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	from, err := findDepGraph(fromID)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	to, err := findDepGraph(toID)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Diff, err = from.Diff(to)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /depgraph/{id}
+
+type CreateDepGraphResponse struct {
 	Error   string `json:"error,omitempty"`
 	ID      string `json:"id,omitempty"`
 	Created bool   `json:"created"`
 }
 
 func (s *Service) createDepGraph(w http.ResponseWriter, r *http.Request) {
-	resp := createDepGraphResponse{}
+	resp := CreateDepGraphResponse{}
 	id := mux.Vars(r)["id"]
 	g := &model.GraphMetadata{}
-	g.Find(id)
+	if err := g.Find(id); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
 	if g.IsNil() {
 		g.BuildID = id
 		if err := g.Insert(); err != nil {
@@ -425,59 +1715,234 @@ func (s *Service) createDepGraph(w http.ResponseWriter, r *http.Request) {
 		resp.Created = true
 	}
 
-	resp.ID = g.BuildID
+	resp.ID = g.BuildID
+	gimlet.WriteJSON(w, resp)
+}
+
+// findDepGraph returns the graph with the specified ID, or an error if
+// it does not exist.
+func findDepGraph(id string) (*model.GraphMetadata, error) {
+	g := &model.GraphMetadata{}
+	if err := g.Find(id); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if g.IsNil() {
+		return nil, errors.Errorf("graph '%s' does not exist", id)
+	}
+
+	return g, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}
+
+type depGraphResolvedRespose struct {
+	Nodes []*model.GraphNode `json:"nodes"`
+	Edges []*model.GraphEdge `json:"edges"`
+	Error string             `json:"error,omitempty"`
+	ID    string             `json:"id"`
+}
+
+func (s *Service) resolveDepGraph(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := depGraphResolvedRespose{ID: id}
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	catcher := grip.NewCatcher()
+
+	nodes, err := g.AllNodes()
+	catcher.Add(err)
+
+	edges, err := g.AllEdges()
+	catcher.Add(err)
+
+	if catcher.HasErrors() {
+		resp.Error = catcher.Resolve().Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Edges = edges
+	resp.Nodes = nodes
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /depgraph/{id}/import
+//
+// body: { "source": <path or url> }
+//
+// Queues a job that replaces the graph's nodes and edges with the
+// graph at the source, creating the graph if it does not exist. The
+// source is either a public http or https url, or a path within the
+// service's import directory, relative to that directory.
+
+type depGraphImportRequest struct {
+	Source string `json:"source"`
+}
+
+type DepGraphImportResponse struct {
+	Error  string            `json:"error,omitempty"`
+	ID     string            `json:"id"`
+	Import model.GraphImport `json:"import"`
+}
+
+func (s *Service) importDepGraph(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphImportResponse{ID: id}
+
+	req := &depGraphImportRequest{}
+	if err := gimlet.GetJSON(r.Body, req); err != nil {
+		resp.Error = errors.Wrap(err, "problem parsing import request").Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if req.Source == "" {
+		resp.Error = "must specify a path or url to import the graph from"
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	source, err := resolveDepGraphSource(s.DepGraphImportDir, req.Source)
+	if err != nil {
+		resp.Error = errors.Wrap(err, "invalid import source").Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	g := &model.GraphMetadata{}
+	if err := g.Find(id); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	if g.IsNil() {
+		g.BuildID = id
+		if err := g.Insert(); err != nil {
+			resp.Error = err.Error()
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	j := units.MakeDepGraphImportJob(id, source)
+	if err := g.QueueImport(req.Source, j.ID()); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if err := s.queue.Put(j); err != nil {
+		err = errors.Wrap(err, "problem queuing graph import")
+		grip.Error(g.FinishImport(err))
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	resp.Import = g.Import
+	gimlet.WriteJSON(w, resp)
+}
+
+// resolveDepGraphSource returns the url or path that the import job
+// reads the graph from. Urls must be public, and paths must be within
+// the import directory, after following symbolic links, so that
+// requests cannot read other files on the service's host.
+func resolveDepGraphSource(dir, source string) (string, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return source, errors.WithStack(model.ValidatePublicURL(source))
+	}
+
+	if dir == "" {
+		return "", errors.New("imports must use an http or https url")
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "problem finding import directory")
+	}
+
+	path, err := filepath.EvalSymlinks(filepath.Join(root, source))
+	if err != nil {
+		return "", errors.Wrapf(err, "problem finding '%s'", source)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("'%s' is not within the import directory", source)
+	}
+
+	return path, nil
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}/import
+
+func (s *Service) getDepGraphImport(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphImportResponse{ID: id}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Import = g.Import
 	gimlet.WriteJSON(w, resp)
 }
 
 ////////////////////////////////////////////////////////////////////////
 //
-// GET /depgraph/{id}
+// POST /depgraph/{id}/nodes
 
-type depGraphResolvedRespose struct {
-	Nodes []*model.GraphNode `json:"nodes"`
-	Edges []*model.GraphEdge `json:"edges"`
-	Error string             `json:"error,omitempty"`
-	ID    string             `json:"id"`
+type AddDepGraphNodesResponse struct {
+	Error string `json:"error,omitempty"`
+	ID    string `json:"id"`
+	Count int    `json:"count"`
 }
 
-func (s *Service) resolveDepGraph(w http.ResponseWriter, r *http.Request) {
+func (s *Service) addDepGraphNodes(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	resp := depGraphResolvedRespose{ID: id}
-	g := &model.GraphMetadata{}
+	resp := AddDepGraphNodesResponse{ID: id}
 
-	if err := g.Find(id); err != nil {
+	g, err := findDepGraph(id)
+	if err != nil {
 		resp.Error = err.Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
 	}
 
-	catcher := grip.NewCatcher()
-
-	nodes, err := g.AllNodes()
-	catcher.Add(err)
-
-	edges, err := g.AllEdges()
-	catcher.Add(err)
-
-	if catcher.HasErrors() {
-		resp.Error = catcher.Resolve().Error()
+	nodes := []*depgraph.Node{}
+	if err = gimlet.GetJSON(r.Body, &nodes); err != nil {
+		resp.Error = errors.Wrap(err, "problem parsing nodes").Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
 	}
 
-	resp.Edges = edges
-	resp.Nodes = nodes
+	resp.Count, err = g.AddNodes(nodes)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
 
 	gimlet.WriteJSON(w, resp)
 }
 
-////////////////////////////////////////////////////////////////////////
-//
-// POST /depgraph/{id}/nodes
-
-func (s *Service) addDepGraphNodes(w http.ResponseWriter, r *http.Request) {
-}
-
 ////////////////////////////////////////////////////////////////////////
 //
 // GET /depgraph/{id}/nodes
@@ -491,9 +1956,8 @@ type depGraphNodesRespose struct {
 func (s *Service) getDepGraphNodes(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	resp := depGraphNodesRespose{ID: id}
-	g := &model.GraphMetadata{}
-
-	if err := g.Find(id); err != nil {
+	g, err := findDepGraph(id)
+	if err != nil {
 		resp.Error = err.Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
@@ -514,8 +1978,38 @@ func (s *Service) getDepGraphNodes(w http.ResponseWriter, r *http.Request) {
 //
 // POST /depgraph/{id}/edges
 
+type AddDepGraphEdgesResponse struct {
+	Error string `json:"error,omitempty"`
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
+
 func (s *Service) addDepGraphEdges(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := AddDepGraphEdgesResponse{ID: id}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	edges := []*depgraph.Edge{}
+	if err = gimlet.GetJSON(r.Body, &edges); err != nil {
+		resp.Error = errors.Wrap(err, "problem parsing edges").Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
 
+	resp.Count, err = g.AddEdges(edges)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -531,9 +2025,8 @@ type depGraphEdgesRespose struct {
 func (s *Service) getDepGraphEdges(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	resp := depGraphEdgesRespose{ID: id}
-	g := &model.GraphMetadata{}
-
-	if err := g.Find(id); err != nil {
+	g, err := findDepGraph(id)
+	if err != nil {
 		resp.Error = err.Error()
 		gimlet.WriteErrorJSON(w, resp)
 		return
@@ -549,3 +2042,291 @@ func (s *Service) getDepGraphEdges(w http.ResponseWriter, r *http.Request) {
 	resp.Edges = edges
 	gimlet.WriteJSON(w, resp)
 }
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}/dependencies
+// GET /depgraph/{id}/dependents
+
+type DepGraphRelativesResponse struct {
+	Error     string                `json:"error,omitempty"`
+	ID        string                `json:"id"`
+	Node      string                `json:"node"`
+	Relatives []model.GraphRelative `json:"relatives"`
+}
+
+// parseEdgeTypes returns the edge types in the request's type
+// parameters, which may be repeated.
+func parseEdgeTypes(r *http.Request) ([]depgraph.EdgeType, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.Wrap(err, "problem parsing query")
+	}
+
+	out := []depgraph.EdgeType{}
+	for _, name := range r.Form["type"] {
+		t, err := model.ParseEdgeType(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+func (s *Service) getDepGraphDependencies(w http.ResponseWriter, r *http.Request) {
+	s.getDepGraphRelatives(w, r, (*model.GraphMetadata).Dependencies)
+}
+
+func (s *Service) getDepGraphDependents(w http.ResponseWriter, r *http.Request) {
+	s.getDepGraphRelatives(w, r, (*model.GraphMetadata).Dependents)
+}
+
+type depGraphRelativesQuery func(*model.GraphMetadata, string, int, []depgraph.EdgeType) ([]model.GraphRelative, error)
+
+func (s *Service) getDepGraphRelatives(w http.ResponseWriter, r *http.Request, query depGraphRelativesQuery) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphRelativesResponse{ID: id, Node: r.FormValue("node")}
+
+	if resp.Node == "" {
+		resp.Error = "must specify a node"
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	depth := 0
+	if val := r.FormValue("depth"); val != "" {
+		var err error
+		depth, err = strconv.Atoi(val)
+		if err != nil {
+			resp.Error = fmt.Sprintf("invalid depth '%s'", val)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	types, err := parseEdgeTypes(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Relatives, err = query(g, resp.Node, depth, types)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}/path
+
+type DepGraphPathResponse struct {
+	Error string                `json:"error,omitempty"`
+	ID    string                `json:"id"`
+	From  string                `json:"from"`
+	To    string                `json:"to"`
+	Path  []model.GraphPathStep `json:"path"`
+}
+
+func (s *Service) getDepGraphPath(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphPathResponse{ID: id, From: r.FormValue("from"), To: r.FormValue("to")}
+
+	if resp.From == "" || resp.To == "" {
+		resp.Error = "must specify the nodes to find a path from and to"
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	types, err := parseEdgeTypes(r)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Path, err = g.ShortestPath(resp.From, resp.To, types)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}/cycles
+
+type DepGraphCyclesResponse struct {
+	Error  string             `json:"error,omitempty"`
+	ID     string             `json:"id"`
+	Cycles []model.GraphCycle `json:"cycles"`
+}
+
+func (s *Service) getDepGraphCycles(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphCyclesResponse{ID: id}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Cycles, err = g.LibraryCycles()
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// GET /depgraph/{id}/export
+
+type depGraphExportErrorResponse struct {
+	Error string `json:"error"`
+	ID    string `json:"id"`
+}
+
+var depGraphExportContentTypes = map[string]string{
+	model.GraphExportDOT:     "text/vnd.graphviz; charset=utf-8",
+	model.GraphExportGraphML: "application/graphml+xml; charset=utf-8",
+}
+
+func (s *Service) exportDepGraph(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := depGraphExportErrorResponse{ID: id}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = model.GraphExportDOT
+	}
+
+	contentType, ok := depGraphExportContentTypes[format]
+	if !ok {
+		resp.Error = fmt.Sprintf("'%s' is not a supported export format", format)
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	filter := model.GraphExportFilter{
+		Prefix: r.FormValue("prefix"),
+		Node:   r.FormValue("node"),
+	}
+
+	if val := r.FormValue("depth"); val != "" {
+		var err error
+		filter.Depth, err = strconv.Atoi(val)
+		if err != nil {
+			resp.Error = fmt.Sprintf("invalid depth '%s'", val)
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+	}
+
+	for _, name := range r.Form["type"] {
+		t, err := model.ParseNodeType(name)
+		if err != nil {
+			resp.Error = err.Error()
+			gimlet.WriteErrorJSON(w, resp)
+			return
+		}
+		filter.Types = append(filter.Types, t)
+	}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	// render the whole graph before writing, so that errors can
+	// still be reported as json.
+	out := &bytes.Buffer{}
+	if err = g.Export(out, format, filter); err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err = out.WriteTo(w)
+	grip.Warning(errors.Wrap(err, "problem writing graph export"))
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// POST /depgraph/{id}/impact
+
+type depGraphImpactRequest struct {
+	Files []string `json:"files"`
+}
+
+type DepGraphImpactResponse struct {
+	Error  string             `json:"error,omitempty"`
+	ID     string             `json:"id"`
+	Impact *model.GraphImpact `json:"impact,omitempty"`
+}
+
+func (s *Service) getDepGraphImpact(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp := DepGraphImpactResponse{ID: id}
+
+	req := &depGraphImpactRequest{}
+	if err := gimlet.GetJSON(r.Body, req); err != nil {
+		resp.Error = errors.Wrap(err, "problem parsing impact request").Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	if len(req.Files) == 0 {
+		resp.Error = "must specify the changed files"
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	g, err := findDepGraph(id)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteErrorJSON(w, resp)
+		return
+	}
+
+	resp.Impact, err = g.Impact(req.Files)
+	if err != nil {
+		resp.Error = err.Error()
+		gimlet.WriteInternalErrorJSON(w, resp)
+		return
+	}
+
+	gimlet.WriteJSON(w, resp)
+}
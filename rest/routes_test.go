@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDepGraphSourceRestrictsImports(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// without an import directory, only public urls are allowed.
+	source, err := resolveDepGraphSource("", "https://builds.example.com/deps.json")
+	assert.NoError(err)
+	assert.Equal("https://builds.example.com/deps.json", source)
+
+	for _, bad := range []string{
+		"deps.json",
+		"/etc/passwd",
+		"file:///etc/passwd",
+		"http://localhost:3000/v1/status",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		_, err = resolveDepGraphSource("", bad)
+		assert.Error(err, bad)
+	}
+
+	dir, err := ioutil.TempDir("", "sink-depgraph")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	root, err := filepath.EvalSymlinks(dir)
+	require.NoError(err)
+
+	imports := filepath.Join(root, "imports")
+	require.NoError(os.Mkdir(imports, 0700))
+	require.NoError(ioutil.WriteFile(filepath.Join(imports, "deps.json"), []byte("{}"), 0600))
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "secret.json"), []byte("{}"), 0600))
+
+	source, err = resolveDepGraphSource(imports, "deps.json")
+	assert.NoError(err)
+	assert.Equal(filepath.Join(imports, "deps.json"), source)
+
+	// absolute paths are relative to the import directory too.
+	source, err = resolveDepGraphSource(imports, "/deps.json")
+	assert.NoError(err)
+	assert.Equal(filepath.Join(imports, "deps.json"), source)
+
+	_, err = resolveDepGraphSource(imports, "../secret.json")
+	assert.Error(err)
+	_, err = resolveDepGraphSource(imports, "missing.json")
+	assert.Error(err)
+
+	if runtime.GOOS != "windows" {
+		require.NoError(os.Symlink(filepath.Join(root, "secret.json"), filepath.Join(imports, "link.json")))
+		_, err = resolveDepGraphSource(imports, "link.json")
+		assert.Error(err)
+	}
+}
@@ -12,6 +12,11 @@ import (
 type Service struct {
 	Port int
 
+	// DepGraphImportDir is the directory that dependency graph
+	// imports may read local files from. When it is empty, imports
+	// must download graphs from public http or https urls.
+	DepGraphImportDir string
+
 	// internal settings
 	queue amboy.Queue
 	app   *gimlet.APIApp
@@ -33,6 +38,7 @@ func (s *Service) Validate() error {
 	if s.app == nil {
 		s.app = gimlet.NewApp()
 		s.app.SetDefaultVersion(1)
+		s.setMiddleware()
 	}
 
 	if s.Port == 0 {
@@ -73,17 +79,51 @@ func (s *Service) Run() {
 
 func (s *Service) addRoutes() {
 	s.app.AddRoute("/status").Version(1).Get().Handler(s.statusHandler)
-	s.app.AddRoute("/status/events/{level}").Version(1).Get().Handler(s.getSystemEvents)
+	s.app.AddRoute("/status/events").Version(1).Get().Handler(s.getSystemEvents)
+	s.app.AddRoute("/status/events/stream").Version(1).Get().Handler(s.streamSystemEvents)
+	s.app.AddRoute("/status/events/acknowledge").Version(1).Post().Handler(s.acknowledgeSystemEvents)
+	s.app.AddRoute("/status/events/{level:emergency|alert|critical|error|warning|notice|info|debug|trace}").Version(1).Get().Handler(s.getSystemEventsByLevel)
+	s.app.AddRoute("/status/events/{id}").Version(1).Get().Handler(s.getSystembEvent)
+	s.app.AddRoute("/status/events/{id}/acknowledge").Version(1).Post().Handler(s.acknowledgeSystemEvent)
+	s.app.AddRoute("/status/events/{id}/transition").Version(1).Post().Handler(s.transitionSystemEvent)
 	s.app.AddRoute("/simple_log/{id}").Version(1).Post().Handler(s.simpleLogInjestion)
 	s.app.AddRoute("/simple_log/{id}").Version(1).Get().Handler(s.simpleLogRetrieval)
 	s.app.AddRoute("/simple_log/{id}/text").Version(1).Get().Handler(s.simpleLogGetText)
 	s.app.AddRoute("/system_info").Version(1).Post().Handler(s.recieveSystemInfo)
+	s.app.AddRoute("/system_info/containers").Version(1).Post().Handler(s.recieveContainerInfo)
+	s.app.AddRoute("/system_info/containers").Version(1).Get().Handler(s.fetchContainerInfo)
+	s.app.AddRoute("/system_info/processes").Version(1).Post().Handler(s.recieveProcessSnapshot)
+	s.app.AddRoute("/system_info/processes/{host}").Version(1).Get().Handler(s.fetchProcessSnapshots)
 	s.app.AddRoute("/system_info/host/{host}").Version(1).Post().Handler(s.fetchSystemInfo)
-
+	s.app.AddRoute("/system_info/host/{host}/export").Version(1).Get().Handler(s.exportSystemInfo)
+	s.app.AddRoute("/system_info/top/{metric}").Version(1).Get().Handler(s.getSystemInfoTop)
+	s.app.AddRoute("/system_info/group/{label}/{metric}").Version(1).Get().Handler(s.getSystemInfoGrouped)
+	s.app.AddRoute("/system_info/forecast/disk").Version(1).Get().Handler(s.getDiskForecast)
+	s.app.AddRoute("/system_info/anomalies").Version(1).Get().Handler(s.getSystemInfoAnomalies)
+	s.app.AddRoute("/metrics/hosts").Version(1).Get().Handler(s.hostMetrics)
+	s.app.AddRoute("/system_info/alerts").Version(1).Get().Handler(s.getSystemInfoAlertRules)
+	s.app.AddRoute("/system_info/alerts/{id}").Version(1).Get().Handler(s.getSystemInfoAlertRule)
+	s.app.AddRoute("/system_info/alerts/{id}").Version(1).Post().Handler(s.setSystemInfoAlertRule)
+	s.app.AddRoute("/system_info/alerts/{id}").Version(1).Delete().Handler(s.removeSystemInfoAlertRule)
+	s.app.AddRoute("/notifications/rules").Version(1).Get().Handler(s.getNotificationRules)
+	s.app.AddRoute("/notifications/rules/{id}").Version(1).Get().Handler(s.getNotificationRule)
+	s.app.AddRoute("/notifications/rules/{id}").Version(1).Post().Handler(s.setNotificationRule)
+	s.app.AddRoute("/notifications/rules/{id}").Version(1).Delete().Handler(s.removeNotificationRule)
+	s.app.AddRoute("/notifications/deliveries").Version(1).Get().Handler(s.getNotificationDeliveries)
+
+	s.app.AddRoute("/depgraph/diff").Version(1).Get().Handler(s.diffDepGraphs)
 	s.app.AddRoute("/depgraph/{id}").Version(1).Post().Handler(s.createDepGraph)
 	s.app.AddRoute("/depgraph/{id}").Version(1).Get().Handler(s.resolveDepGraph)
+	s.app.AddRoute("/depgraph/{id}/import").Version(1).Post().Handler(s.importDepGraph)
+	s.app.AddRoute("/depgraph/{id}/import").Version(1).Get().Handler(s.getDepGraphImport)
 	s.app.AddRoute("/depgraph/{id}/nodes").Version(1).Post().Handler(s.addDepGraphNodes)
 	s.app.AddRoute("/depgraph/{id}/nodes").Version(1).Get().Handler(s.getDepGraphNodes)
 	s.app.AddRoute("/depgraph/{id}/edges").Version(1).Post().Handler(s.addDepGraphEdges)
 	s.app.AddRoute("/depgraph/{id}/edges").Version(1).Get().Handler(s.getDepGraphEdges)
+	s.app.AddRoute("/depgraph/{id}/dependencies").Version(1).Get().Handler(s.getDepGraphDependencies)
+	s.app.AddRoute("/depgraph/{id}/dependents").Version(1).Get().Handler(s.getDepGraphDependents)
+	s.app.AddRoute("/depgraph/{id}/path").Version(1).Get().Handler(s.getDepGraphPath)
+	s.app.AddRoute("/depgraph/{id}/cycles").Version(1).Get().Handler(s.getDepGraphCycles)
+	s.app.AddRoute("/depgraph/{id}/export").Version(1).Get().Handler(s.exportDepGraph)
+	s.app.AddRoute("/depgraph/{id}/impact").Version(1).Post().Handler(s.getDepGraphImpact)
 }
@@ -0,0 +1,80 @@
+package units
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink"
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/pkg/errors"
+)
+
+const (
+	anomalyDetectionJobName = "anomaly-detection"
+)
+
+func init() {
+	registry.AddJobType(anomalyDetectionJobName, func() amboy.Job {
+		return anomalyDetectionJobFactory()
+	})
+}
+
+// anomalyDetectionJob compares new system information samples to each
+// host's baselines, and records a system event for each sample that
+// deviates from them.
+type anomalyDetectionJob struct {
+	Window    time.Duration `bson:"window" json:"window" yaml:"window"`
+	ZScore    float64       `bson:"zscore" json:"zscore" yaml:"zscore"`
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func anomalyDetectionJobFactory() amboy.Job {
+	j := &anomalyDetectionJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    anomalyDetectionJobName,
+				Version: 1,
+			},
+		},
+	}
+
+	j.SetDependency(dependency.NewAlways())
+	return j
+}
+
+// MakeAnomalyDetectionJob creates a job that checks the samples from
+// every host that reported within the window, and flags samples more
+// than the specified number of standard deviations from the host's
+// baseline. Jobs are unique per minute.
+func MakeAnomalyDetectionJob(window time.Duration, zScore float64) amboy.Job {
+	j := anomalyDetectionJobFactory().(*anomalyDetectionJob)
+	j.SetID(fmt.Sprintf("%s-%s", j.Type().Name, time.Now().Format("2006-01-02.15-04")))
+
+	j.Window = window
+	j.ZScore = zScore
+
+	return j
+}
+
+func (j *anomalyDetectionJob) Run() {
+	defer j.MarkComplete()
+
+	detector := &model.AnomalyDetector{
+		ZScore: j.ZScore,
+		Sender: sink.GetSystemSender(),
+	}
+
+	count, err := detector.Process(j.Window, time.Now())
+	if err != nil {
+		err = errors.Wrap(err, "problem detecting anomalies")
+		grip.Warning(err)
+		j.AddError(err)
+	}
+
+	grip.InfoWhen(count > 0, fmt.Sprintf("found %d anomalies in system information", count))
+}
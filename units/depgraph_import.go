@@ -0,0 +1,228 @@
+package units
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"github.com/tychoish/depgraph"
+)
+
+const (
+	depGraphImportJobName = "depgraph-import"
+
+	// depGraphImportBatchSize is the number of nodes or edges that
+	// the import stores in each write, and so how often it records
+	// its progress.
+	depGraphImportBatchSize = 1000
+
+	// depGraphDownloadTimeout bounds how long the import waits to
+	// download a graph from a URL.
+	depGraphDownloadTimeout = 10 * time.Minute
+)
+
+func init() {
+	registry.AddJobType(depGraphImportJobName, func() amboy.Job {
+		return depGraphImportJobFactory()
+	})
+}
+
+type depGraphImportJob struct {
+	BuildID   string `bson:"build_id" json:"build_id" yaml:"build_id"`
+	Source    string `bson:"source" json:"source" yaml:"source"`
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func depGraphImportJobFactory() amboy.Job {
+	j := &depGraphImportJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    depGraphImportJobName,
+				Version: 1,
+			},
+		},
+	}
+	j.SetDependency(dependency.NewAlways())
+
+	return j
+}
+
+// MakeDepGraphImportJob creates a job that parses the graph at the
+// source, which is either a local path or a URL, and stores its nodes
+// and edges in the graph with the build ID. The job reads any source
+// it is given, so callers must check that the source is one that the
+// requester may read. Record the job's ID with the graph's
+// QueueImport before running it.
+func MakeDepGraphImportJob(buildID, source string) amboy.Job {
+	j := depGraphImportJobFactory().(*depGraphImportJob)
+	j.SetID(fmt.Sprintf("%s-%s-%d", j.Type().Name, buildID, time.Now().UnixNano()))
+
+	j.BuildID = buildID
+	j.Source = source
+
+	return j
+}
+
+func (j *depGraphImportJob) Run() {
+	defer j.MarkComplete()
+
+	g := &model.GraphMetadata{}
+	if err := g.Find(j.BuildID); err != nil {
+		j.AddError(errors.Wrap(err, "problem finding graph"))
+		return
+	}
+
+	if g.IsNil() {
+		j.AddError(errors.Errorf("graph '%s' does not exist", j.BuildID))
+		return
+	}
+
+	if g.Import.JobID != j.ID() {
+		j.AddError(errors.Errorf("import of graph '%s' by job '%s' is no longer current", j.BuildID, j.ID()))
+		return
+	}
+
+	err := j.importGraph(g)
+	if err != nil {
+		j.AddError(err)
+	}
+
+	if err = g.FinishImport(err); err != nil {
+		j.AddError(errors.Wrap(err, "problem recording import result"))
+	}
+}
+
+func (j *depGraphImportJob) importGraph(g *model.GraphMetadata) error {
+	graph, err := j.loadGraph()
+	if err != nil {
+		return errors.Wrapf(err, "problem loading graph from '%s'", j.Source)
+	}
+
+	if err = g.StartImport(len(graph.Nodes), len(graph.Edges)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// nodes and edges that the new graph does not have would
+	// otherwise remain from earlier imports.
+	if err = g.Clear(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	nodes := 0
+	for start := 0; start < len(graph.Nodes); start += depGraphImportBatchSize {
+		end := start + depGraphImportBatchSize
+		if end > len(graph.Nodes) {
+			end = len(graph.Nodes)
+		}
+
+		num, err := g.AddNodes(graph.Nodes[start:end])
+		if err != nil {
+			return errors.Wrapf(err, "problem storing nodes %d through %d", start, end-1)
+		}
+		nodes += num
+
+		if err = g.SetImportProgress(nodes, 0); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	edges := 0
+	for start := 0; start < len(graph.Edges); start += depGraphImportBatchSize {
+		end := start + depGraphImportBatchSize
+		if end > len(graph.Edges) {
+			end = len(graph.Edges)
+		}
+
+		num, err := g.AddEdges(graph.Edges[start:end])
+		if err != nil {
+			return errors.Wrapf(err, "problem storing edges %d through %d", start, end-1)
+		}
+		edges += num
+
+		if err = g.SetImportProgress(nodes, edges); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	grip.Info(message.Fields{
+		"message": "imported dependency graph",
+		"graph":   j.BuildID,
+		"source":  j.Source,
+		"nodes":   nodes,
+		"edges":   edges,
+	})
+
+	return nil
+}
+
+// loadGraph parses the graph at the source. The import downloads
+// graphs from URLs itself, to a file that only this job uses, rather
+// than use depgraph's shared download cache.
+func (j *depGraphImportJob) loadGraph() (*depgraph.Graph, error) {
+	if !strings.HasPrefix(j.Source, "http://") && !strings.HasPrefix(j.Source, "https://") {
+		graph, err := depgraph.New(j.BuildID, j.Source)
+		return graph, errors.WithStack(err)
+	}
+
+	path, err := downloadDepGraph(j.Source)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() { grip.Warning(os.Remove(path)) }()
+
+	graph, err := depgraph.New(j.BuildID, path)
+	return graph, errors.WithStack(err)
+}
+
+// downloadDepGraph downloads the graph at the URL to a temporary file,
+// and returns the file's path. It does not follow redirects to
+// internal hosts.
+func downloadDepGraph(url string) (string, error) {
+	client := &http.Client{
+		Timeout: depGraphDownloadTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			return errors.Wrap(model.ValidatePublicURL(req.URL.String()), "problem following redirect")
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", errors.Wrap(err, "problem downloading graph")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", errors.Errorf("downloading graph failed with '%s'", resp.Status)
+	}
+
+	f, err := ioutil.TempFile("", "sink-depgraph-")
+	if err != nil {
+		return "", errors.Wrap(err, "problem creating file for graph")
+	}
+
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		grip.Warning(os.Remove(f.Name()))
+		return "", errors.Wrap(err, "problem downloading graph")
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,66 @@
+package units
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mongodb/amboy/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepGraphImportJobConstruction(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	j, ok := MakeDepGraphImportJob("build", "http://example.net/deps.json").(*depGraphImportJob)
+	require.True(ok)
+	assert.Equal("build", j.BuildID)
+	assert.Equal("http://example.net/deps.json", j.Source)
+	assert.Equal(depGraphImportJobName, j.Type().Name)
+	assert.True(strings.HasPrefix(j.ID(), "depgraph-import-build-"))
+
+	factory, err := registry.GetJobFactory(depGraphImportJobName)
+	require.NoError(err)
+	assert.IsType(&depGraphImportJob{}, factory())
+}
+
+func TestDownloadDepGraph(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/deps.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"graph": []}`)
+	}))
+	defer srv.Close()
+
+	path, err := downloadDepGraph(srv.URL + "/deps.json")
+	require.NoError(err)
+	defer os.Remove(path)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+	assert.Equal(`{"graph": []}`, string(data))
+
+	other, err := downloadDepGraph(srv.URL + "/deps.json")
+	require.NoError(err)
+	defer os.Remove(other)
+	assert.NotEqual(path, other)
+
+	_, err = downloadDepGraph(srv.URL + "/missing.json")
+	assert.Error(err)
+
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL+"/deps.json", http.StatusFound))
+	defer redirect.Close()
+	_, err = downloadDepGraph(redirect.URL)
+	assert.Error(err)
+}
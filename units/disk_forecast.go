@@ -0,0 +1,81 @@
+package units
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink"
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	diskForecastJobName = "disk-forecast"
+)
+
+func init() {
+	registry.AddJobType(diskForecastJobName, func() amboy.Job {
+		return diskForecastJobFactory()
+	})
+}
+
+// diskForecastJob forecasts disk capacity for every partition and
+// records a system event for each partition predicted to fill within
+// the threshold.
+type diskForecastJob struct {
+	Window    time.Duration `bson:"window" json:"window" yaml:"window"`
+	Days      float64       `bson:"days" json:"days" yaml:"days"`
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func diskForecastJobFactory() amboy.Job {
+	j := &diskForecastJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    diskForecastJobName,
+				Version: 1,
+			},
+		},
+	}
+
+	j.SetDependency(dependency.NewAlways())
+	return j
+}
+
+// MakeDiskForecastJob creates a job that fits disk usage trends over
+// the window of history, and raises events for partitions predicted
+// to fill in fewer than the specified number of days. Jobs are unique
+// per hour.
+func MakeDiskForecastJob(window time.Duration, days float64) amboy.Job {
+	j := diskForecastJobFactory().(*diskForecastJob)
+	j.SetID(fmt.Sprintf("%s-%s", j.Type().Name, time.Now().Format("2006-01-02.15")))
+
+	j.Window = window
+	j.Days = days
+
+	return j
+}
+
+func (j *diskForecastJob) Run() {
+	defer j.MarkComplete()
+
+	now := time.Now()
+	forecasts := &model.DiskForecasts{}
+	if err := forecasts.FindBetween("", now.Add(-j.Window), now); err != nil {
+		err = errors.Wrap(err, "problem computing disk forecasts")
+		grip.Warning(err)
+		j.AddError(err)
+		return
+	}
+
+	sender := sink.GetSystemSender()
+	for _, forecast := range forecasts.AtRisk(j.Days) {
+		sender.Send(forecast.Message(level.Warning))
+	}
+}
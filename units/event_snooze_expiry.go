@@ -0,0 +1,69 @@
+package units
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+)
+
+const eventSnoozeExpiryJobName = "event-snooze-expiry"
+
+func init() {
+	registry.AddJobType(eventSnoozeExpiryJobName, func() amboy.Job {
+		return eventSnoozeExpiryJobFactory()
+	})
+}
+
+// eventSnoozeExpiryJob reopens the events whose snooze has ended.
+type eventSnoozeExpiryJob struct {
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func eventSnoozeExpiryJobFactory() amboy.Job {
+	j := &eventSnoozeExpiryJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    eventSnoozeExpiryJobName,
+				Version: 1,
+			},
+		},
+	}
+
+	j.SetDependency(dependency.NewAlways())
+	return j
+}
+
+// MakeEventSnoozeExpiryJob creates a job that reopens the events
+// whose snooze has ended. Reopened events are new to the notification
+// rules. Jobs are unique per minute.
+func MakeEventSnoozeExpiryJob() amboy.Job {
+	j := eventSnoozeExpiryJobFactory().(*eventSnoozeExpiryJob)
+	j.SetID(fmt.Sprintf("%s-%s", j.Type().Name, time.Now().Format("2006-01-02.15-04")))
+
+	return j
+}
+
+func (j *eventSnoozeExpiryJob) Run() {
+	defer j.MarkComplete()
+
+	reopened, err := model.ReopenExpiredSnoozes(time.Now())
+	if err != nil {
+		err = errors.Wrap(err, "problem reopening snoozed events")
+		grip.Warning(err)
+		j.AddError(err)
+		return
+	}
+
+	grip.Debug(message.Fields{
+		"job":      j.ID(),
+		"reopened": reopened,
+	})
+}
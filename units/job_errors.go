@@ -0,0 +1,186 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evergreen-ci/sink"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/grip/level"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// hookedQueueStartedTTL is how long the hooked queue tracks a
+// dispatched job that the runner has not completed, as runners can
+// abandon jobs, for example when their context is canceled.
+const hookedQueueStartedTTL = 24 * time.Hour
+
+// JobCompletionHook is called with each job that a queue completes,
+// and how long the job ran, which is zero if the queue did not
+// dispatch the job.
+type JobCompletionHook func(j amboy.Job, runtime time.Duration)
+
+// hookedQueue wraps a queue to call hooks as its runner completes
+// jobs. The runner dispatches and completes jobs through the wrapper,
+// which tracks when each job was dispatched.
+type hookedQueue struct {
+	amboy.Queue
+	hooks []JobCompletionHook
+
+	mu       sync.Mutex
+	started  map[string]time.Time
+	prunedAt time.Time
+}
+
+// NewHookedQueue wraps the queue so that the hooks run for each job
+// that the queue's runner completes. Wrap queues before starting them,
+// and use the wrapper in place of the queue.
+func NewHookedQueue(q amboy.Queue, hooks ...JobCompletionHook) (amboy.Queue, error) {
+	out := &hookedQueue{
+		Queue:   q,
+		hooks:   hooks,
+		started: map[string]time.Time{},
+	}
+
+	if err := out.SetRunner(q.Runner()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return out, nil
+}
+
+// SetRunner attaches the runner to the wrapper rather than the
+// wrapped queue, so that the runner completes jobs through the hooks.
+func (q *hookedQueue) SetRunner(r amboy.Runner) error {
+	if r == nil {
+		return errors.New("cannot hook a queue without a runner")
+	}
+
+	if err := r.SetQueue(q); err != nil {
+		return errors.Wrap(err, "problem attaching runner to hooked queue")
+	}
+
+	return errors.WithStack(q.Queue.SetRunner(r))
+}
+
+func (q *hookedQueue) Next(ctx context.Context) amboy.Job {
+	j := q.Queue.Next(ctx)
+	if j != nil {
+		now := time.Now()
+
+		q.mu.Lock()
+		q.started[j.ID()] = now
+		q.prune(now)
+		q.mu.Unlock()
+	}
+
+	return j
+}
+
+// prune forgets the jobs dispatched longer than the TTL ago, at most
+// once an hour. The caller must hold the lock.
+func (q *hookedQueue) prune(now time.Time) {
+	if now.Sub(q.prunedAt) < time.Hour {
+		return
+	}
+	q.prunedAt = now
+
+	for id, start := range q.started {
+		if now.Sub(start) > hookedQueueStartedTTL {
+			delete(q.started, id)
+		}
+	}
+}
+
+func (q *hookedQueue) Complete(ctx context.Context, j amboy.Job) {
+	q.Queue.Complete(ctx, j)
+
+	var runtime time.Duration
+	q.mu.Lock()
+	if start, ok := q.started[j.ID()]; ok {
+		runtime = time.Since(start)
+		delete(q.started, j.ID())
+	}
+	q.mu.Unlock()
+
+	for _, hook := range q.hooks {
+		hook(j, runtime)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Recording Job Errors
+
+// RecordJobErrors is a JobCompletionHook that records a system event
+// for each job that completes with errors, so that processing
+// failures appear in the events API.
+func RecordJobErrors(j amboy.Job, runtime time.Duration) {
+	if j.Error() == nil {
+		return
+	}
+
+	sender := sink.GetSystemSender()
+	if sender == nil {
+		return
+	}
+
+	sender.Send(newJobErrorMessage(j, runtime))
+}
+
+// JobErrorInfo describes a job that completed with errors, and is the
+// payload of the event that records the failure.
+type JobErrorInfo struct {
+	ID             string   `bson:"job_id" json:"job_id" yaml:"job_id"`
+	Type           string   `bson:"job_type" json:"job_type" yaml:"job_type"`
+	Errors         []string `bson:"errors" json:"errors" yaml:"errors"`
+	Runtime        string   `bson:"runtime" json:"runtime" yaml:"runtime"`
+	RuntimeSeconds float64  `bson:"runtime_secs" json:"runtime_secs" yaml:"runtime_secs"`
+	message.Base   `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func newJobErrorMessage(j amboy.Job, runtime time.Duration) message.Composer {
+	m := &JobErrorInfo{
+		ID:             j.ID(),
+		Type:           j.Type().Name,
+		Errors:         jobErrors(j),
+		Runtime:        runtime.String(),
+		RuntimeSeconds: runtime.Seconds(),
+	}
+	_ = m.SetPriority(level.Error)
+
+	return m
+}
+
+// jobErrors returns the errors that the job recorded. Jobs only
+// report their errors combined, as one error, so the list has at most
+// one entry.
+func jobErrors(j amboy.Job) []string {
+	if err := j.Error(); err != nil {
+		return []string{err.Error()}
+	}
+
+	return []string{}
+}
+
+// String summarizes the failure with the first line of the error, as
+// errors may include stack traces.
+func (m *JobErrorInfo) String() string {
+	first := ""
+	if len(m.Errors) > 0 {
+		first = strings.SplitN(m.Errors[0], "\n", 2)[0]
+	}
+
+	return fmt.Sprintf("job '%s' of type '%s' failed: %s", m.ID, m.Type, first)
+}
+
+func (m *JobErrorInfo) Loggable() bool { return m.ID != "" }
+
+func (m *JobErrorInfo) Raw() interface{} {
+	_ = m.Collect()
+	return m
+}
@@ -0,0 +1,99 @@
+package units
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/queue"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+type failingJob struct {
+	*job.Base
+}
+
+func newFailingJob(id string, errs ...error) *failingJob {
+	j := &failingJob{Base: &job.Base{JobType: amboy.JobType{Name: "failing"}}}
+	j.SetID(id)
+	for _, err := range errs {
+		j.AddError(err)
+	}
+
+	return j
+}
+
+func (j *failingJob) Run() {
+	time.Sleep(10 * time.Millisecond)
+	j.MarkComplete()
+}
+
+func TestHookedQueueCallsHooksOnCompletion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mu := &sync.Mutex{}
+	runtimes := map[string]time.Duration{}
+	q, err := NewHookedQueue(queue.NewLocalLimitedSize(2, 16), func(j amboy.Job, runtime time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		runtimes[j.ID()] = runtime
+	})
+	require.NoError(err)
+	require.NoError(q.Start(ctx))
+
+	require.NoError(q.Put(newFailingJob("one", errors.New("broken"))))
+	require.NoError(q.Put(newFailingJob("two")))
+	amboy.WaitCtxInterval(ctx, q, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(runtimes, 2)
+	assert.True(runtimes["one"] >= 10*time.Millisecond)
+	assert.True(runtimes["two"] >= 10*time.Millisecond)
+}
+
+func TestHookedQueuePrunesAbandonedJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	q := &hookedQueue{started: map[string]time.Time{
+		"abandoned": now.Add(-2 * hookedQueueStartedTTL),
+		"running":   now.Add(-time.Minute),
+	}}
+
+	q.prune(now)
+	assert.Len(q.started, 1)
+	assert.Contains(q.started, "running")
+
+	// pruning happens at most once an hour.
+	q.started["abandoned"] = now.Add(-2 * hookedQueueStartedTTL)
+	q.prune(now.Add(time.Minute))
+	assert.Len(q.started, 2)
+}
+
+func TestJobErrorMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	j := newFailingJob("save-log", errors.New("first problem"), errors.New("second problem"))
+	m := newJobErrorMessage(j, 2*time.Second).(*JobErrorInfo)
+
+	assert.Equal("save-log", m.ID)
+	assert.Equal("failing", m.Type)
+	require.Len(t, m.Errors, 1)
+	assert.True(strings.HasPrefix(m.Errors[0], "first problem"))
+	assert.Contains(m.Errors[0], "second problem")
+	assert.Equal(2.0, m.RuntimeSeconds)
+	assert.Equal("job 'save-log' of type 'failing' failed: first problem", m.String())
+	assert.True(m.Loggable())
+	assert.Equal(m, m.Raw())
+}
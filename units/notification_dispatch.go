@@ -0,0 +1,84 @@
+package units
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+)
+
+const (
+	notificationDispatchJobName = "notification-dispatch"
+
+	// notificationBatchSize is the maximum number of new events that
+	// one job matches against the notification rules.
+	notificationBatchSize = 1000
+)
+
+func init() {
+	registry.AddJobType(notificationDispatchJobName, func() amboy.Job {
+		return notificationDispatchJobFactory()
+	})
+}
+
+// notificationDispatchJob matches new events against the notification
+// rules, and attempts the deliveries that are due, including retries
+// of earlier failures.
+type notificationDispatchJob struct {
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func notificationDispatchJobFactory() amboy.Job {
+	j := &notificationDispatchJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    notificationDispatchJobName,
+				Version: 1,
+			},
+		},
+	}
+
+	j.SetDependency(dependency.NewAlways())
+	return j
+}
+
+// MakeNotificationDispatchJob creates a job that forwards new events
+// to the targets of matching notification rules. Jobs are unique per
+// minute.
+func MakeNotificationDispatchJob() amboy.Job {
+	j := notificationDispatchJobFactory().(*notificationDispatchJob)
+	j.SetID(fmt.Sprintf("%s-%s", j.Type().Name, time.Now().Format("2006-01-02.15-04")))
+
+	return j
+}
+
+func (j *notificationDispatchJob) Run() {
+	defer j.MarkComplete()
+
+	queued, err := model.QueueNotifications(notificationBatchSize)
+	if err != nil {
+		err = errors.Wrap(err, "problem queuing notifications")
+		grip.Warning(err)
+		j.AddError(err)
+	}
+
+	delivered, err := model.DeliverNotifications(time.Now())
+	if err != nil {
+		err = errors.Wrap(err, "problem delivering notifications")
+		grip.Warning(err)
+		j.AddError(err)
+	}
+
+	grip.Debug(message.Fields{
+		"job":       j.ID(),
+		"queued":    queued,
+		"delivered": delivered,
+	})
+}
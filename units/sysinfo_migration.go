@@ -0,0 +1,82 @@
+package units
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evergreen-ci/sink/model"
+	"github.com/mongodb/amboy"
+	"github.com/mongodb/amboy/dependency"
+	"github.com/mongodb/amboy/job"
+	"github.com/mongodb/amboy/registry"
+	"github.com/mongodb/grip"
+	"github.com/mongodb/grip/message"
+	"github.com/pkg/errors"
+)
+
+const (
+	sysInfoMigrationJobName   = "sysinfo-bucket-migration"
+	sysInfoMigrationBatchSize = 1000
+)
+
+func init() {
+	registry.AddJobType(sysInfoMigrationJobName, func() amboy.Job {
+		return sysInfoMigrationJobFactory()
+	})
+}
+
+// sysInfoMigrationJob moves system information samples from the legacy
+// one-document-per-sample collection into hourly buckets.
+type sysInfoMigrationJob struct {
+	Migrated  int `bson:"migrated" json:"migrated" yaml:"migrated"`
+	*job.Base `bson:"metadata" json:"metadata" yaml:"metadata"`
+}
+
+func sysInfoMigrationJobFactory() amboy.Job {
+	j := &sysInfoMigrationJob{
+		Base: &job.Base{
+			JobType: amboy.JobType{
+				Name:    sysInfoMigrationJobName,
+				Version: 1,
+			},
+		},
+	}
+
+	j.SetDependency(dependency.NewAlways())
+	return j
+}
+
+// MakeSystemInfoMigrationJob creates a job that migrates all legacy
+// system information documents into buckets. The job is a no-op once
+// the legacy collection is empty, and is unique per day.
+func MakeSystemInfoMigrationJob() amboy.Job {
+	j := sysInfoMigrationJobFactory().(*sysInfoMigrationJob)
+	j.SetID(fmt.Sprintf("%s-%s", j.Type().Name, time.Now().Format("2006-01-02")))
+
+	return j
+}
+
+func (j *sysInfoMigrationJob) Run() {
+	defer j.MarkComplete()
+
+	for {
+		count, err := model.MigrateSystemInfoToBuckets(sysInfoMigrationBatchSize)
+		j.Migrated += count
+		if err != nil {
+			err = errors.Wrap(err, "problem migrating system information to buckets")
+			grip.Warning(err)
+			j.AddError(err)
+			return
+		}
+
+		if count == 0 {
+			break
+		}
+	}
+
+	grip.InfoWhen(j.Migrated > 0, message.Fields{
+		"message":  "migrated system information to buckets",
+		"job":      j.ID(),
+		"migrated": j.Migrated,
+	})
+}